@@ -5,6 +5,7 @@ package chalker
 
 import (
 	"errors"
+	"io"
 
 	"github.com/fatih/color"
 )
@@ -27,18 +28,25 @@ func Error(body string) error {
 
 // Log writes chalks to console
 func Log(level string, body string) {
+	LogTo(color.Output, level, body)
+}
+
+// LogTo writes chalks to the given writer (IE: os.Stderr)
+func LogTo(w io.Writer, level string, body string) {
+	var attribute color.Attribute
 	switch level {
 	case INFO:
-		color.Cyan(body)
+		attribute = color.FgCyan
 	case WARN:
-		color.Yellow(body)
+		attribute = color.FgYellow
 	case ERROR:
-		color.Magenta(body)
+		attribute = color.FgMagenta
 	case SUCCESS:
-		color.Green(body)
+		attribute = color.FgGreen
 	case DEFAULT:
 		fallthrough
 	default:
-		color.White(body)
+		attribute = color.FgWhite
 	}
+	_, _ = color.New(attribute).Fprintln(w, body)
 }
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/fatih/color"
+	"github.com/mrz1836/go-datastore"
+	"github.com/spf13/cobra"
+)
+
+// commands for access key
+const accessKeyCommandGet = "get"
+const accessKeyCommandList = "list"
+const accessKeyCommandName = "accesskey"
+const accessKeyCommandNew = "new"
+const accessKeyCommandRevoke = "revoke"
+
+// access key statuses (filters for listing)
+const accessKeyStatusActive = "active"
+const accessKeyStatusRevoked = "revoked"
+
+// returnAccessKeyCmd returns the access key command
+func returnAccessKeyCmd(app *App) (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
+		Use:   accessKeyCommandName,
+		Short: "manage and interact with access keys in BUX",
+		Long: color.GreenString(`
+   _____    _________   _________   ___________   _________   _________  ____  __. ___________ _____.___.
+  /  _  \   \_   ___ \  \_   ___ \  \_   _____/  /   _____/  /   _____/ |    |/ _| \_   _____/ \__  |   |
+ /  /_\  \  /    \  \/  /    \  \/   |    __)_   \_____  \   \_____  \  |      <    |    __)_   /   |   |
+/    |    \ \     \____ \     \____  |        \  /        \  /        \ |    |  \   |        \  \____   |
+\____|__  /  \______  /  \______  / /_______  / /_______  / /_______  / |____|__ \ /_______  /  / ______|
+        \/          \/          \/          \/          \/          \/          \/         \/   \/`) + `
+` + color.YellowString(`
+This command is for access key related commands.
+Access keys are used to authenticate with BUX without exposing the xpub.
+
+new: creates a new access key in BUX, the private key is only shown once (`+accessKeyCommandName+` `+accessKeyCommandNew+` <xpub>)
+list: returns the access keys for an xpub (`+accessKeyCommandName+` `+accessKeyCommandList+` <xpub_id> --status=active)
+get: gets an existing access key in BUX (`+accessKeyCommandName+` `+accessKeyCommandGet+` <access_key_id> -x=<xpub_id>)
+revoke: revokes an access key in BUX (`+accessKeyCommandName+` `+accessKeyCommandRevoke+` <access_key_id> --xpub=<xpub>)
+`),
+		Aliases: []string{"accesskeys", "access-key"},
+		Example: applicationName + " " + accessKeyCommandName + " " + accessKeyCommandNew + " <xpub>",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("%s %w, IE: %s, etc.", accessKeyCommandName, ErrSubcommandIsRequired, accessKeyCommandNew)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Initialize the BUX client
+			deferFunc, err := app.InitializeBUX()
+			if err != nil {
+				return err
+			}
+			defer deferFunc()
+
+			// Parse Metadata
+			var metaData bux.Metadata
+			if metaData, err = getMetadataFlag(cmd); err != nil {
+				return err
+			}
+
+			// Switch on the subcommand
+			if args[0] == accessKeyCommandNew { // Create a new access key
+
+				// Check if xpub is provided
+				if len(args) < 2 {
+					return ErrXpubIsRequired
+				}
+
+				// Create the access key
+				var accessKey *bux.AccessKey
+				if accessKey, err = app.backend.NewAccessKey(context.Background(), args[1], metaData); err != nil {
+					return fmt.Errorf("error creating access key: %w", err)
+				}
+
+				// Display the access key (the private key cannot be retrieved again)
+				chalker.LogTo(os.Stderr, chalker.WARN,
+					"The private key is only shown once, store it somewhere safe before continuing")
+				return displayModel(newAccessKey(accessKey))
+
+			} else if args[0] == accessKeyCommandList { // List the access keys
+
+				// Check if xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubIDIsRequired
+				}
+
+				// Build the filters and the pagination
+				var conditions map[string]interface{}
+				if conditions, err = accessKeyConditions(accessKeyStatus); err != nil {
+					return err
+				}
+				var queryParams *datastore.QueryParams
+				if queryParams, err = newQueryParams(page, pageSize, orderBy, sortDirection); err != nil {
+					return err
+				}
+
+				// Get the access keys
+				var accessKeys []*AccessKey
+				var total int64
+				if accessKeys, total, err = listAccessKeys(
+					context.Background(), app, args[1], metaData, conditions, queryParams,
+				); err != nil {
+					return fmt.Errorf("error getting access keys: %w", err)
+				}
+
+				// Display the access keys
+				displayPage(queryParams, len(accessKeys), total)
+				return displayModel(accessKeys)
+
+			} else if args[0] == accessKeyCommandGet { // Get an access key
+
+				// Check if access key id is provided
+				if len(args) < 2 {
+					return ErrAccessKeyIsRequired
+				}
+
+				// Get the access key
+				var accessKey *AccessKey
+				if accessKey, err = getAccessKey(context.Background(), app, xpubID, args[1]); err != nil {
+					return fmt.Errorf("error getting access key: %w", err)
+				}
+
+				// Display the access key
+				return displayModel(accessKey)
+
+			} else if args[0] == accessKeyCommandRevoke { // Revoke an access key
+
+				// Check if access key id is provided
+				if len(args) < 2 {
+					return ErrAccessKeyIsRequired
+				}
+
+				// Revoke the access key
+				var accessKey *bux.AccessKey
+				if accessKey, err = app.backend.RevokeAccessKey(context.Background(), xpubKey, args[1]); err != nil {
+					return fmt.Errorf("error revoking access key: %w", err)
+				}
+
+				// Display the access key
+				return displayModel(newAccessKey(accessKey))
+			}
+
+			return ErrUnknownSubcommand
+		},
+	}
+
+	// Set the metadata flag
+	addMetadataFlag(newCmd)
+
+	// Set the xpub flags (get: xpub id, revoke: xpub)
+	newCmd.Flags().StringVarP(&xpubID, flagXpubID, flagXpubIDShort, "", "Xpub ID that owns the access key")
+	newCmd.Flags().StringVar(&xpubKey, flagXpub, "", "Xpub that owns the access key (required to revoke)")
+
+	// Set the list filters
+	newCmd.Flags().StringVar(&accessKeyStatus, flagStatus, "", "Filter by status: "+strings.Join([]string{
+		accessKeyStatusActive, accessKeyStatusRevoked,
+	}, ", "))
+	addPaginationFlags(newCmd)
+
+	return
+}
+
+// accessKeyConditions will build the conditions for listing access keys from the status filter
+func accessKeyConditions(status string) (map[string]interface{}, error) {
+	conditions := make(map[string]interface{})
+	switch status {
+	case "":
+	case accessKeyStatusActive:
+		conditions["revoked_at"] = nil
+	case accessKeyStatusRevoked:
+		conditions["revoked_at"] = map[string]interface{}{conditionExists: true}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAccessKeyStatus, status)
+	}
+	return conditions, nil
+}
+
+// newAccessKey will wrap the bux access key model (and flag if it is revoked)
+func newAccessKey(accessKey *bux.AccessKey) *AccessKey {
+	return &AccessKey{Bux: accessKey, Revoked: accessKey != nil && accessKey.RevokedAt.Valid}
+}
+
+// getAccessKey gets an access key by ID (the xpub id is optional)
+func getAccessKey(ctx context.Context, app *App, xpubID, id string) (*AccessKey, error) {
+	accessKey, err := app.backend.GetAccessKey(ctx, xpubID, id)
+	if err != nil {
+		return nil, err
+	}
+	return newAccessKey(accessKey), nil
+}
+
+// listAccessKeys gets a page of access keys for the xpub and the total count matching the filters
+func listAccessKeys(ctx context.Context, app *App, xpubID string, metaData bux.Metadata,
+	conditions map[string]interface{},
+	queryParams *datastore.QueryParams) (accessKeys []*AccessKey, total int64, err error) {
+
+	// Get the access keys
+	var results []*bux.AccessKey
+	if results, err = app.backend.GetAccessKeys(
+		ctx, xpubID, metadataConditions(metaData), conditions, queryParams,
+	); err != nil {
+		return
+	}
+	accessKeys = make([]*AccessKey, 0, len(results))
+	for _, result := range results {
+		accessKeys = append(accessKeys, newAccessKey(result))
+	}
+
+	// Get the total count
+	total, err = app.backend.GetAccessKeysCount(ctx, xpubID, metadataConditions(metaData), conditions)
+	return
+}
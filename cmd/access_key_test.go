@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/BuxOrg/bux"
+	customTypes "github.com/mrz1836/go-datastore/custom_types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessKeyConditions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("all", func(t *testing.T) {
+		conditions, err := accessKeyConditions("")
+		require.NoError(t, err)
+		assert.Empty(t, conditions)
+	})
+
+	t.Run("active", func(t *testing.T) {
+		conditions, err := accessKeyConditions(accessKeyStatusActive)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"revoked_at": nil}, conditions)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		conditions, err := accessKeyConditions(accessKeyStatusRevoked)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"revoked_at": map[string]interface{}{conditionExists: true}}, conditions)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := accessKeyConditions("expired")
+		require.ErrorIs(t, err, ErrInvalidAccessKeyStatus)
+	})
+}
+
+func TestNewAccessKey(t *testing.T) {
+	t.Parallel()
+
+	t.Run("active", func(t *testing.T) {
+		accessKey := newAccessKey(&bux.AccessKey{ID: "key-id"})
+		assert.False(t, accessKey.Revoked)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		accessKey := newAccessKey(&bux.AccessKey{
+			ID:        "key-id",
+			RevokedAt: customTypes.NullTime{NullTime: sql.NullTime{Time: time.Now(), Valid: true}},
+		})
+		assert.True(t, accessKey.Revoked)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		accessKey := newAccessKey(nil)
+		assert.Nil(t, accessKey.Bux)
+		assert.False(t, accessKey.Revoked)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -19,7 +20,10 @@ import (
 	"github.com/mitchellh/go-homedir"
 	"github.com/mrz1836/go-cachestore"
 	"github.com/mrz1836/go-datastore"
+	"github.com/mrz1836/go-whatsonchain"
+	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/tonicpow/go-minercraft"
 )
@@ -36,16 +40,12 @@ func commandPreprocessor() (app *App) {
 	// Set up the application resources
 	setupAppResources(app)
 
-	// Load the configuration
-	initConfig(app)
-
-	// Mode is required
-	if viper.GetString("mode") == "" {
-		er(ErrModeIsRequired)
-	}
-
 	// Add config option
-	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "custom config file (default is $HOME/"+applicationName+"/"+configFileDefault+".json)")
+	rootCmd.PersistentFlags().StringVar(&configFile, flagConfig, "", "custom config file (default is $HOME/"+applicationName+"/"+configFileDefault+".json)")
+
+	// Add the config profile option
+	rootCmd.PersistentFlags().StringVar(&profileName, flagProfile, "",
+		"config profile (default is $"+profileEnv+", the active profile or "+configProfileDefault+")")
 
 	// Add document generation for all commands
 	rootCmd.PersistentFlags().BoolVar(&generateDocs, "docs", false, "generate docs from all commands (./"+docsLocation+")")
@@ -59,9 +59,16 @@ func commandPreprocessor() (app *App) {
 	// Add a toggle for verbose logging
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "enable verbose logging")
 
+	// Add the output format for all commands
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, flagOutput, flagOutputShort, outputFormatPretty,
+		"output format: "+strings.Join(outputFormats, ", "))
+
 	// Add xpriv command
 	rootCmd.AddCommand(returnXprivCmd())
 
+	// Add keystore command
+	rootCmd.AddCommand(returnKeystoreCmd())
+
 	// Add xpub command
 	rootCmd.AddCommand(returnXpubCmd(app))
 
@@ -71,34 +78,114 @@ func commandPreprocessor() (app *App) {
 	// Add transaction command
 	rootCmd.AddCommand(returnTransactionCmd(app))
 
+	// Add draft command
+	rootCmd.AddCommand(returnDraftCmd(app))
+
+	// Add utxo command
+	rootCmd.AddCommand(returnUtxoCmd(app))
+
+	// Add paymail command
+	rootCmd.AddCommand(returnPaymailCmd(app))
+
+	// Add access key command
+	rootCmd.AddCommand(returnAccessKeyCmd(app))
+
+	// Add worker command
+	rootCmd.AddCommand(returnWorkerCmd(app))
+
+	// Add miners command
+	rootCmd.AddCommand(returnMinersCmd(app))
+
+	// Add config command
+	rootCmd.AddCommand(returnConfigCmd(app))
+
+	// The config flags are needed before the flags are parsed by cobra
+	preParseConfigFlags(os.Args[1:])
+
+	// The config command loads the profiles itself (IE: to create, switch or fix a profile),
+	// the offline commands do not use the config (IE: signing on an air-gapped machine)
+	if cmd, args, err := rootCmd.Find(os.Args[1:]); err == nil && cmd.Name() == configCommandName {
+		return
+	} else if err == nil && isOfflineCommand(cmd, args) {
+		app.offline = true
+		return
+	}
+
+	// Load the configuration
+	er(initConfig(app))
+
+	// Mode is required
+	if viper.GetString("mode") == "" {
+		er(ErrModeIsRequired)
+	}
+
 	return
 }
 
-// displayModel will display a model in a pretty format
-func displayModel(v any) {
-	if v == nil {
-		displayError(ErrModelIsNil)
-		return
+// isOfflineCommand will return true if the command runs without the config and BUX
+// (transaction sign, transaction decode --offline)
+func isOfflineCommand(cmd *cobra.Command, args []string) bool {
+	if cmd.Name() != transactionCommandName {
+		return false
 	}
-	if b, err := json.MarshalIndent(v, "", "  "); err != nil {
-		displayError(fmt.Errorf("error marshaling model: %w", err))
-	} else {
-		chalker.Log(chalker.INFO, string(b))
+	flags := parseRawFlags(cmd, args)
+	if positional := flags.Args(); len(positional) == 0 {
+		return false
+	} else if positional[0] == transactionCommandSign {
+		return true
+	} else if positional[0] != transactionCommandDecode {
+		return false
 	}
+	offline, _ := strconv.ParseBool(flags.Lookup(flagOffline).Value.String())
+	return offline
 }
 
-// displayError will display an error in a pretty format
-func displayError(err error) {
-	if err != nil {
-		chalker.Log(chalker.ERROR, fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+// parseRawFlags will parse the flags of the command into raw (string) values
+//
+// The flags are parsed into throwaway values, cobra parses them (again) when the command is executed
+func parseRawFlags(cmd *cobra.Command, args []string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.Usage = func() {}
+	flags.SetOutput(new(strings.Builder))
+	addFlag := func(flag *pflag.Flag) {
+		if flags.Lookup(flag.Name) == nil {
+			flags.AddFlag(&pflag.Flag{
+				Name:        flag.Name,
+				NoOptDefVal: flag.NoOptDefVal,
+				Shorthand:   flag.Shorthand,
+				Value:       new(rawValue),
+			})
+		}
 	}
+	cmd.Flags().VisitAll(addFlag)
+	cmd.InheritedFlags().VisitAll(addFlag)
+	_ = flags.Parse(args)
+	return flags
+}
+
+// rawValue is a flag value that is stored as the raw string (see: parseRawFlags)
+type rawValue struct {
+	value string
 }
 
-// er is a basic helper method to catch errors loading the application
+// Set will store the raw value
+func (v *rawValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+// String will return the raw value
+func (v *rawValue) String() string { return v.value }
+
+// Type will return the type of the value
+func (v *rawValue) Type() string { return "string" }
+
+// er is a basic helper method to catch errors loading the application (exits with the mapped exit code)
 func er(err error) {
 	if err != nil {
 		displayError(err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -126,8 +213,8 @@ func generateDocumentation() {
 	chalker.Log(chalker.SUCCESS, fmt.Sprintf("Successfully generated documentation for %d commands", len(rootCmd.Commands())))
 }
 
-// initConfig reads in config file and ENV variables if set
-func initConfig(app *App) {
+// initConfig reads in config file (custom file or the selected profile) and ENV variables if set
+func initConfig(app *App) error {
 
 	// Create a lock for modifying the config
 	viperLock.Lock()
@@ -146,28 +233,27 @@ func initConfig(app *App) {
 
 		// Use config file from the flag
 		viper.SetConfigFile(configFile)
-	} else {
-
-		// Check if the default config file exists
-		if _, err := os.Stat(filepath.Join(applicationDirectory, configFileDefault+".json")); err != nil {
+	} else if name := activeProfileName(applicationDirectory); name != configProfileDefault {
 
-			// Read the example config
-			var content []byte
-			content, err = os.ReadFile("config-example.json")
-			er(err)
+		// Use the named profile
+		path, err := profileConfigPath(applicationDirectory, name)
+		if err != nil {
+			return err
+		}
 
-			// Make a dummy file if it doesn't exist
-			var file *os.File
-			file, err = os.OpenFile(filepath.Join(applicationDirectory, configFileDefault+".json"), os.O_RDWR|os.O_CREATE, 0755) //nolint:gosec // We don't care about the permissions
-			er(err)
+		verboseLog(func() {
+			chalker.Log(chalker.INFO, fmt.Sprintf("Loading configuration profile: %s...", name))
+		})
 
-			defer func() {
-				_ = file.Close()
-			}()
+		viper.SetConfigFile(path)
+	} else {
 
-			// Write the example config into the file
-			_, err = file.WriteString(string(content))
-			er(err)
+		// Create the default config file if it doesn't exist (embedded in the binary, see: config init)
+		path := filepath.Join(applicationDirectory, configFileDefault+".json")
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err = writeConfigFile(path, defaultConfig, false); err != nil {
+				return err
+			}
 		}
 
 		// Search config in home directory with name "." (without extension)
@@ -184,10 +270,14 @@ func initConfig(app *App) {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in
-	er(viper.ReadInConfig())
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("%w: %s", ErrFailedToReadConfig, err.Error())
+	}
 
 	// Unmarshal into values struct
-	er(viper.Unmarshal(&app.config))
+	if err := viper.Unmarshal(&app.config); err != nil {
+		return fmt.Errorf("%w: %s", ErrFailedToReadConfig, err.Error())
+	}
 
 	// Fix for relative paths in database configuration (SQLite)
 	usr, _ := user.Current()
@@ -212,10 +302,18 @@ func initConfig(app *App) {
 	verboseLog(func() {
 		chalker.Log(chalker.INFO, fmt.Sprintf("...loaded config file: %s", viper.ConfigFileUsed()))
 	})
+	return nil
 }
 
 // loadBux will load BUX into the app
-func loadBux(app *App) (loaded bool) {
+func loadBux(app *App) (err error) {
+
+	// Validate the config (missing values would fail or panic when loading BUX)
+	if app.config == nil {
+		return ErrFailedToReadConfig
+	} else if err = configProblemsError(validateConfig(app.config)); err != nil {
+		return err
+	}
 
 	// Start building BUX client options
 	var options []bux.ClientOps
@@ -247,10 +345,8 @@ func loadBux(app *App) (loaded bool) {
 		}
 
 		// Set the datastore
-		var err error
 		if options, err = loadDatastore(options, app); err != nil {
-			displayError(fmt.Errorf("error loading datastore: %w", err))
-			return
+			return fmt.Errorf("error loading datastore: %w", err)
 		}
 
 		// Load task manager (redis or taskq)
@@ -268,6 +364,11 @@ func loadBux(app *App) (loaded bool) {
 			} else {
 				options = append(options, bux.WithTaskQ(config, app.config.TaskManager.Factory))
 			}
+
+			// Custom cron service (IE: the worker schedules the tasks)
+			if app.cronService != nil {
+				options = append(options, bux.WithCronService(app.cronService))
+			}
 		}
 
 		// Add chainstate options
@@ -278,57 +379,95 @@ func loadBux(app *App) (loaded bool) {
 			app.config.Chainstate.SyncOnChain,
 		))
 
-		// Exclude providers (NowNodes needs API key) // todo: make this configurable
-		options = append(options, bux.WithExcludedProviders([]string{chainstate.ProviderNowNodes}))
+		// Exclude providers (NowNodes is excluded without an API key)
+		var excluded []string
+		if excluded, err = excludedProviders(app.config.Chainstate); err != nil {
+			return err
+		}
+		options = append(options, bux.WithExcludedProviders(excluded))
 
-		// Detect custom miners for broadcasting
-		if len(app.config.Chainstate.MinersBroadcast) > 0 {
-			options = detectMiners(app.config.Chainstate.MinersBroadcast, options, true)
+		// API keys for the providers
+		if len(app.config.Chainstate.NowNodesAPIKey) > 0 {
+			options = append(options, bux.WithNowNodesAPIKey(app.config.Chainstate.NowNodesAPIKey))
+		}
+		if len(app.config.Chainstate.WhatsOnChainAPIKey) > 0 {
+			options = append(options, bux.WithWhatsOnChainAPIKey(app.config.Chainstate.WhatsOnChainAPIKey))
 		}
 
-		// Detect custom miners for querying
-		if len(app.config.Chainstate.MinersQuery) > 0 {
-			options = detectMiners(app.config.Chainstate.MinersQuery, options, false)
+		// Known and custom miners (with the tokens)
+		var miners []*minercraft.Miner
+		if miners, err = newMiners(app.config.Chainstate); err != nil {
+			return err
 		}
 
-		// Custom rates for TAAL using their API key
-		if len(app.config.Chainstate.TaalAPIKey) > 0 {
+		// Detect custom miners for broadcasting and querying (the defaults are used to set the tokens)
+		minersBroadcast, minersQuery := app.config.Chainstate.MinersBroadcast, app.config.Chainstate.MinersQuery
+		if len(app.config.Chainstate.MinerTokens) > 0 || len(app.config.Chainstate.TaalAPIKey) > 0 {
 			verboseLog(func() {
-				chalker.Log(chalker.INFO, "taal api key detected and loaded")
+				chalker.Log(chalker.INFO, "miner tokens detected and loaded")
 			})
-			miners, _ := minercraft.DefaultMiners()
-			taal := minercraft.MinerByName(miners, minercraft.MinerTaal)
-			taal.Token = app.config.Chainstate.TaalAPIKey
-			options = append(options, bux.WithBroadcastMiners([]*chainstate.Miner{{Miner: taal}}))
-			options = append(options, bux.WithQueryMiners([]*chainstate.Miner{{Miner: taal}}))
+			if len(minersBroadcast) == 0 {
+				minersBroadcast = defaultMinerNames(true)
+			}
+			if len(minersQuery) == 0 {
+				minersQuery = defaultMinerNames(false)
+			}
+		}
+		lenient := app.config.Chainstate.LenientMiners
+		if len(minersBroadcast) > 0 {
+			if options, err = detectMiners(minersBroadcast, miners, options, true, lenient); err != nil {
+				return err
+			}
+		}
+		if len(minersQuery) > 0 {
+			if options, err = detectMiners(minersQuery, miners, options, false, lenient); err != nil {
+				return err
+			}
 		}
 
 		// Load BUX
 		app.bux, err = bux.NewClient(context.Background(), options...)
 		if err != nil {
-			displayError(errors.New("error loading BUX: " + err.Error()))
-			return
+			return fmt.Errorf("%w: %s", ErrFailedToLoadBux, err.Error())
 		}
 
+		// Use the BUX engine as the backend
+		app.backend = newDatabaseBackend(app.bux)
+
 	} else if app.config.Mode == modeServer {
-		displayError(ErrServerModeIsNotImplemented)
-		return
+
+		// Use the remote BUX server as the backend
+		var backend *serverBackend
+		if backend, err = newServerBackend(app.config.Server, app.GetUserAgent()); err != nil {
+			return fmt.Errorf("error loading BUX server: %w", err)
+		}
+
+		// WhatsOnChain API key (no chainstate is available in server mode)
+		if app.config.Chainstate != nil && len(app.config.Chainstate.WhatsOnChainAPIKey) > 0 {
+			backend.woc = newWhatsOnChainClient(app.GetUserAgent(), app.config.Chainstate.WhatsOnChainAPIKey)
+		}
+		app.backend = backend
+
+		verboseLog(func() {
+			chalker.Log(chalker.SUCCESS, fmt.Sprintf("using BUX server: %s", app.config.Server.URL))
+		})
 	} else {
-		displayError(ErrUnknownMode)
-		return
+		return ErrUnknownMode
 	}
 
 	// Success on loading?
-	if app.bux != nil {
-		loaded = true
+	if app.backend != nil {
 
-		verboseLog(func() {
-			chalker.Log(chalker.SUCCESS, fmt.Sprintf("successfully loaded BUX version: %s", app.bux.UserAgent()))
-		})
+		// Database mode has a local BUX engine
+		if app.bux != nil {
+			verboseLog(func() {
+				chalker.Log(chalker.SUCCESS, fmt.Sprintf("successfully loaded BUX version: %s", app.bux.UserAgent()))
+			})
 
-		// Print some basic stats
-		if app.config.Verbose {
-			printBuxStats(app)
+			// Print some basic stats
+			if app.config.Verbose {
+				printBuxStats(app)
+			}
 		}
 	}
 
@@ -428,7 +567,10 @@ func printBuxStats(app *App) {
 }
 
 // detectMiners will detect the miners from the config and add them to the BUX options
-func detectMiners(minerNames []string, options []bux.ClientOps, isBroadcast bool) []bux.ClientOps {
+//
+// Unknown miners are an error, or a warning if lenient (the miner is ignored)
+func detectMiners(minerNames []string, miners []*minercraft.Miner, options []bux.ClientOps,
+	isBroadcast, lenient bool) ([]bux.ClientOps, error) {
 
 	if isBroadcast {
 		verboseLog(func() {
@@ -440,13 +582,13 @@ func detectMiners(minerNames []string, options []bux.ClientOps, isBroadcast bool
 		})
 	}
 
-	miners, _ := minercraft.DefaultMiners()
+	selected, err := selectMiners(minerNames, miners, lenient)
+	if err != nil {
+		return nil, err
+	}
 	var foundMiners []*chainstate.Miner
-	for _, minerName := range minerNames {
-		miner := minercraft.MinerByName(miners, minerName)
-		if miner != nil {
-			foundMiners = append(foundMiners, &chainstate.Miner{Miner: miner})
-		}
+	for _, miner := range selected {
+		foundMiners = append(foundMiners, &chainstate.Miner{Miner: miner})
 	}
 	if len(foundMiners) > 0 {
 		if isBroadcast {
@@ -459,7 +601,7 @@ func detectMiners(minerNames []string, options []bux.ClientOps, isBroadcast bool
 			chalker.Log(chalker.INFO, "added miners: "+string(jsonMiners))
 		})
 	}
-	return options
+	return options, nil
 }
 
 // setupAppResources will set up the local application directories
@@ -497,23 +639,29 @@ func (a *App) GetUserAgent() string {
 	return "BUX-CLI: " + Version
 }
 
+// WhatsOnChain will return the WhatsOnChain client, cached in the local database unless disabled (--no-cache)
+func (a *App) WhatsOnChain() whatsonchain.ClientInterface {
+	client := a.backend.WhatsOnChain()
+	if disableCache || a.database == nil || !a.database.Connected {
+		return client
+	}
+	return newCachedWhatsOnChain(client, a.database, a.config.Cache)
+}
+
 // InitializeBUX will initialize BUX if it is not already initialized
-func (a *App) InitializeBUX() (deferFunc func()) {
+func (a *App) InitializeBUX() (deferFunc func(), err error) {
 
 	// Load BUX if not already loaded
-	if a.bux == nil {
-		loaded := loadBux(a)
-
-		// Fail if BUX is not loaded
-		if !loaded {
-			er(ErrFailedToLoadBux)
+	if a.backend == nil {
+		if err = loadBux(a); err != nil {
+			return
 		}
 	}
 
 	// Return a function to close BUX
 	deferFunc = func() {
-		if a.bux != nil {
-			_ = a.bux.Close(context.Background())
+		if a.backend != nil {
+			_ = a.backend.Close(context.Background())
 		}
 	}
 	return
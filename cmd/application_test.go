@@ -17,3 +17,19 @@ func TestAppConfig_GetUserAgent(t *testing.T) {
 		assert.Equal(t, "BUX-CLI: "+Version, agent)
 	})
 }
+
+func TestIsOfflineCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := returnTransactionCmd(new(App))
+	assert.True(t, isOfflineCommand(cmd, []string{"sign", "draft.json", "--key", "name"}))
+	assert.True(t, isOfflineCommand(cmd, []string{"--key", "sign", "--woc", "sign", "draft.json"}))
+	assert.True(t, isOfflineCommand(cmd, []string{"-o", "json", "sign", "draft.json"}))
+	assert.False(t, isOfflineCommand(cmd, []string{"send", "xpub", "--key", "sign"}))
+	assert.False(t, isOfflineCommand(cmd, []string{"--to", "sign", "new", "xpub"}))
+	assert.True(t, isOfflineCommand(cmd, []string{"decode", "0100", "--offline"}))
+	assert.True(t, isOfflineCommand(cmd, []string{"--offline=true", "decode", "-"}))
+	assert.False(t, isOfflineCommand(cmd, []string{"decode", "0100"}))
+	assert.False(t, isOfflineCommand(cmd, []string{"decode", "0100", "--offline=false"}))
+	assert.False(t, isOfflineCommand(returnXpubCmd(new(App)), []string{"sign"}))
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/BuxOrg/bux"
+	"github.com/mrz1836/go-datastore"
+	"github.com/mrz1836/go-whatsonchain"
+)
+
+// Backend is the interface that all commands use to interact with BUX,
+// either directly via the database (bux engine) or remotely via a BUX server
+type Backend interface {
+	CancelDraftTransaction(ctx context.Context, id string) (*bux.DraftTransaction, error)
+	Close(ctx context.Context) error
+	DeletePaymail(ctx context.Context, address string) error
+	GetAccessKey(ctx context.Context, xPubID, id string) (*bux.AccessKey, error)
+	GetAccessKeys(ctx context.Context, xPubID string, metadata *bux.Metadata, conditions map[string]interface{},
+		queryParams *datastore.QueryParams) ([]*bux.AccessKey, error)
+	GetAccessKeysCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+		conditions map[string]interface{}) (int64, error)
+	FindDestination(ctx context.Context, lockingScript string) (*bux.Destination, error)
+	GetDraftTransaction(ctx context.Context, id string) (*bux.DraftTransaction, error)
+	GetDraftTransactions(ctx context.Context, xPubID string, metadata *bux.Metadata, conditions map[string]interface{},
+		queryParams *datastore.QueryParams) ([]*bux.DraftTransaction, error)
+	GetDraftTransactionsCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+		conditions map[string]interface{}) (int64, error)
+	GetDestination(ctx context.Context, xPubID, idOrAddressOrScript string) (*bux.Destination, error)
+	GetDestinations(ctx context.Context, xPubID string, metadata *bux.Metadata, conditions map[string]interface{},
+		queryParams *datastore.QueryParams) ([]*bux.Destination, error)
+	GetDestinationsCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+		conditions map[string]interface{}) (int64, error)
+	GetPaymail(ctx context.Context, address string) (*bux.PaymailAddress, error)
+	GetPaymails(ctx context.Context, xPubID string, metadata *bux.Metadata,
+		queryParams *datastore.QueryParams) ([]*bux.PaymailAddress, error)
+	GetPaymailsCount(ctx context.Context, xPubID string, metadata *bux.Metadata) (int64, error)
+	GetTransaction(ctx context.Context, xPubID, txID string) (*bux.Transaction, error)
+	GetTransactions(ctx context.Context, xPubID string, metadata *bux.Metadata, conditions map[string]interface{},
+		queryParams *datastore.QueryParams) ([]*bux.Transaction, error)
+	GetTransactionsCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+		conditions map[string]interface{}) (int64, error)
+	GetUtxo(ctx context.Context, txID string, outputIndex uint32) (*bux.Utxo, error)
+	GetUtxos(ctx context.Context, xPubID string, metadata *bux.Metadata, conditions map[string]interface{},
+		queryParams *datastore.QueryParams) ([]*bux.Utxo, error)
+	GetUtxosCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+		conditions map[string]interface{}) (int64, error)
+	GetXpub(ctx context.Context, xPubKey string) (*bux.Xpub, error)
+	GetXpubByID(ctx context.Context, xPubID string) (*bux.Xpub, error)
+	GetXpubs(ctx context.Context, metadata *bux.Metadata) ([]*bux.Xpub, error)
+	NewAccessKey(ctx context.Context, xPubKey string, metadata bux.Metadata) (*bux.AccessKey, error)
+	NewDestination(ctx context.Context, xPubKey string, chain uint32, destinationType string, monitor bool,
+		metadata bux.Metadata) (*bux.Destination, error)
+	NewPaymail(ctx context.Context, xPubKey, address, publicName, avatar string,
+		metadata bux.Metadata) (*bux.PaymailAddress, error)
+	NewTransaction(ctx context.Context, xPubKey string, config *bux.TransactionConfig,
+		metadata bux.Metadata) (*bux.DraftTransaction, error)
+	NewXpub(ctx context.Context, xPubKey string, metadata bux.Metadata) (*bux.Xpub, error)
+	RecordTransaction(ctx context.Context, xPubKey, txHex, draftID string,
+		metadata bux.Metadata) (*bux.Transaction, error)
+	RevokeAccessKey(ctx context.Context, xPubKey, id string) (*bux.AccessKey, error)
+	UpdateDestinationMetadata(ctx context.Context, xPubID, idOrAddressOrScript string,
+		metadata bux.Metadata) (*bux.Destination, error)
+	UpdatePaymailMetadata(ctx context.Context, address string, metadata bux.Metadata) (*bux.PaymailAddress, error)
+	UpdateTransactionMetadata(ctx context.Context, xPubID, txID string, metadata bux.Metadata) (*bux.Transaction, error)
+	UpdateXpubMetadata(ctx context.Context, xPubKey, xPubID string, metadata bux.Metadata) (*bux.Xpub, error)
+	WhatsOnChain() whatsonchain.ClientInterface
+}
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/BuxOrg/bux"
+	"github.com/mrz1836/go-datastore"
+	"github.com/mrz1836/go-whatsonchain"
+)
+
+// databaseBackend is the backend that talks directly to the BUX engine (database mode)
+type databaseBackend struct {
+	client bux.ClientInterface
+}
+
+// newDatabaseBackend will return a new backend using a loaded BUX client
+func newDatabaseBackend(client bux.ClientInterface) *databaseBackend {
+	return &databaseBackend{client: client}
+}
+
+// modelOptions will return the default model options with the metadata (if provided)
+func (d *databaseBackend) modelOptions(metadata bux.Metadata) []bux.ModelOps {
+	modelOps := d.client.DefaultModelOptions()
+	if len(metadata) > 0 {
+		modelOps = append(modelOps, bux.WithMetadatas(metadata))
+	}
+	return modelOps
+}
+
+// CancelDraftTransaction will cancel an open draft transaction, the reserved utxos are released
+func (d *databaseBackend) CancelDraftTransaction(ctx context.Context, id string) (*bux.DraftTransaction, error) {
+	draft, err := d.GetDraftTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if draft.Status != bux.DraftStatusDraft {
+		return nil, fmt.Errorf("%w: the draft status is %s", ErrInvalidDraft, draft.Status)
+	}
+
+	// Saving the canceled draft removes the reservations of the utxos (see: DraftTransaction.AfterUpdated)
+	draft.SetOptions(d.client.DefaultModelOptions()...)
+	draft.Status = bux.DraftStatusCanceled
+	if err = draft.Save(ctx); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// Close will close the BUX client
+func (d *databaseBackend) Close(ctx context.Context) error {
+	return d.client.Close(ctx)
+}
+
+// DeletePaymail will delete a paymail address (soft delete)
+func (d *databaseBackend) DeletePaymail(ctx context.Context, address string) error {
+	return d.client.DeletePaymailAddress(ctx, address)
+}
+
+// GetAccessKey will get an access key by ID (the xpub ID is optional, if set the key must belong to the xpub)
+func (d *databaseBackend) GetAccessKey(ctx context.Context, xPubID, id string) (*bux.AccessKey, error) {
+	if len(xPubID) > 0 {
+		return d.client.GetAccessKey(ctx, xPubID, id)
+	}
+
+	// Find the access key by ID only
+	accessKeys, err := d.client.GetAccessKeys(ctx, nil, &map[string]interface{}{"id": id}, nil)
+	if err != nil {
+		return nil, err
+	} else if len(accessKeys) == 0 {
+		return nil, bux.ErrMissingAccessKey
+	}
+	return accessKeys[0], nil
+}
+
+// GetAccessKeys will get the access keys for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetAccessKeys(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) ([]*bux.AccessKey, error) {
+	return d.client.GetAccessKeysByXPubID(ctx, xPubID, metadata, copyConditions(conditions), queryParams)
+}
+
+// GetAccessKeysCount will count the access keys for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetAccessKeysCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (int64, error) {
+	return d.client.GetAccessKeysByXPubIDCount(ctx, xPubID, metadata, copyConditions(conditions))
+}
+
+// FindDestination will find the destination of any xpub by locking script (nil if not found)
+func (d *databaseBackend) FindDestination(ctx context.Context, lockingScript string) (*bux.Destination, error) {
+	destinations, err := d.client.GetDestinations(
+		ctx, nil, &map[string]interface{}{"locking_script": lockingScript}, nil,
+	)
+	if err != nil || len(destinations) == 0 {
+		return nil, err
+	}
+	return destinations[0], nil
+}
+
+// GetDraftTransaction will get a draft transaction by ID
+func (d *databaseBackend) GetDraftTransaction(ctx context.Context, id string) (*bux.DraftTransaction, error) {
+	drafts, err := d.client.GetDraftTransactions(ctx, nil, &map[string]interface{}{"id": id}, nil)
+	if err != nil {
+		return nil, err
+	} else if len(drafts) == 0 {
+		return nil, bux.ErrDraftNotFound
+	}
+	return drafts[0], nil
+}
+
+// GetDraftTransactions will get the draft transactions for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetDraftTransactions(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) ([]*bux.DraftTransaction, error) {
+	dbConditions := copyConditions(conditions)
+	(*dbConditions)["xpub_id"] = xPubID
+	return d.client.GetDraftTransactions(ctx, metadata, dbConditions, queryParams)
+}
+
+// GetDraftTransactionsCount will count the draft transactions for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetDraftTransactionsCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (int64, error) {
+	dbConditions := copyConditions(conditions)
+	(*dbConditions)["xpub_id"] = xPubID
+	return d.client.GetDraftTransactionsCount(ctx, metadata, dbConditions)
+}
+
+// GetDestination will get a destination by ID, address or locking script
+func (d *databaseBackend) GetDestination(ctx context.Context, xPubID,
+	idOrAddressOrScript string) (destination *bux.Destination, err error) {
+
+	// Get the destination by ID
+	destination, err = d.client.GetDestinationByID(ctx, xPubID, idOrAddressOrScript)
+	if err != nil && !errors.Is(err, bux.ErrMissingDestination) {
+		return
+	}
+
+	// If destination is nil, try to get it by address or locking script
+	if destination == nil {
+		destination, err = d.client.GetDestinationByAddress(ctx, xPubID, idOrAddressOrScript)
+		if err != nil && errors.Is(err, bux.ErrMissingDestination) {
+			destination, err = d.client.GetDestinationByLockingScript(ctx, xPubID, idOrAddressOrScript)
+			if err != nil {
+				err = fmt.Errorf("error finding destination: %w", err)
+			}
+		}
+	}
+	return
+}
+
+// GetDestinations will get the destinations for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetDestinations(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) ([]*bux.Destination, error) {
+	return d.client.GetDestinationsByXpubID(ctx, xPubID, metadata, copyConditions(conditions), queryParams)
+}
+
+// GetDestinationsCount will count the destinations for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetDestinationsCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (int64, error) {
+	return d.client.GetDestinationsByXpubIDCount(ctx, xPubID, metadata, copyConditions(conditions))
+}
+
+// GetPaymail will get a paymail address
+func (d *databaseBackend) GetPaymail(ctx context.Context, address string) (*bux.PaymailAddress, error) {
+	return d.client.GetPaymailAddress(ctx, address)
+}
+
+// GetPaymails will get the paymail addresses for the xpub (deleted addresses are excluded)
+func (d *databaseBackend) GetPaymails(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	queryParams *datastore.QueryParams) ([]*bux.PaymailAddress, error) {
+	return d.client.GetPaymailAddresses(ctx, metadata, paymailConditions(xPubID), queryParams)
+}
+
+// GetPaymailsCount will count the paymail addresses for the xpub (deleted addresses are excluded)
+func (d *databaseBackend) GetPaymailsCount(ctx context.Context, xPubID string, metadata *bux.Metadata) (int64, error) {
+	return d.client.GetPaymailAddressesCount(ctx, metadata, paymailConditions(xPubID))
+}
+
+// GetTransaction will get a transaction by ID
+func (d *databaseBackend) GetTransaction(ctx context.Context, xPubID, txID string) (*bux.Transaction, error) {
+	return d.client.GetTransaction(ctx, xPubID, txID)
+}
+
+// GetTransactions will get the transactions for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetTransactions(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) ([]*bux.Transaction, error) {
+	return d.client.GetTransactionsByXpubID(ctx, xPubID, metadata, copyConditions(conditions), queryParams)
+}
+
+// GetTransactionsCount will count the transactions for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetTransactionsCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (int64, error) {
+	return d.client.GetTransactionsByXpubIDCount(ctx, xPubID, metadata, copyConditions(conditions))
+}
+
+// GetUtxo will get a utxo by transaction ID and output index
+func (d *databaseBackend) GetUtxo(ctx context.Context, txID string, outputIndex uint32) (*bux.Utxo, error) {
+	return d.client.GetUtxoByTransactionID(ctx, txID, outputIndex)
+}
+
+// GetUtxos will get the utxos for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetUtxos(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) ([]*bux.Utxo, error) {
+	return d.client.GetUtxosByXpubID(ctx, xPubID, metadata, copyConditions(conditions), queryParams)
+}
+
+// GetUtxosCount will count the utxos for the xpub matching the metadata and conditions
+func (d *databaseBackend) GetUtxosCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (int64, error) {
+	dbConditions := copyConditions(conditions)
+	(*dbConditions)["xpub_id"] = xPubID
+	return d.client.GetUtxosCount(ctx, metadata, dbConditions)
+}
+
+// GetXpub will get a xpub by the raw xpub key
+func (d *databaseBackend) GetXpub(ctx context.Context, xPubKey string) (*bux.Xpub, error) {
+	return d.client.GetXpub(ctx, xPubKey)
+}
+
+// GetXpubByID will get a xpub by the xpub ID
+func (d *databaseBackend) GetXpubByID(ctx context.Context, xPubID string) (*bux.Xpub, error) {
+	return d.client.GetXpubByID(ctx, xPubID)
+}
+
+// GetXpubs will get all xpubs matching the metadata
+func (d *databaseBackend) GetXpubs(ctx context.Context, metadata *bux.Metadata) ([]*bux.Xpub, error) {
+	return d.client.GetXPubs(ctx, metadata, nil, nil)
+}
+
+// NewAccessKey will create a new access key for the xpub
+func (d *databaseBackend) NewAccessKey(ctx context.Context, xPubKey string,
+	metadata bux.Metadata) (*bux.AccessKey, error) {
+	return d.client.NewAccessKey(ctx, xPubKey, d.modelOptions(metadata)...)
+}
+
+// NewDestination will create a new destination for the xpub (chain, type and if the destination is monitored)
+func (d *databaseBackend) NewDestination(ctx context.Context, xPubKey string, chain uint32, destinationType string,
+	monitor bool, metadata bux.Metadata) (*bux.Destination, error) {
+
+	// Make sure the xpub exists
+	xpub, err := d.client.GetXpub(ctx, xPubKey)
+	if err != nil {
+		return nil, err
+	} else if xpub == nil {
+		return nil, ErrXpubNotFound
+	}
+
+	return d.client.NewDestination(ctx, xPubKey, chain, destinationType, monitor, d.modelOptions(metadata)...)
+}
+
+// NewPaymail will create a new paymail address for the xpub
+func (d *databaseBackend) NewPaymail(ctx context.Context, xPubKey, address, publicName, avatar string,
+	metadata bux.Metadata) (*bux.PaymailAddress, error) {
+	return d.client.NewPaymailAddress(ctx, xPubKey, address, publicName, avatar, d.modelOptions(metadata)...)
+}
+
+// NewTransaction will create a new draft transaction
+func (d *databaseBackend) NewTransaction(ctx context.Context, xPubKey string,
+	config *bux.TransactionConfig, metadata bux.Metadata) (*bux.DraftTransaction, error) {
+
+	// Make sure the xpub exists
+	xpub, err := d.client.GetXpub(ctx, xPubKey)
+	if err != nil {
+		return nil, err
+	} else if xpub == nil {
+		return nil, ErrXpubNotFound
+	}
+
+	return d.client.NewTransaction(ctx, xPubKey, config, d.modelOptions(metadata)...)
+}
+
+// NewXpub will create a new xpub
+func (d *databaseBackend) NewXpub(ctx context.Context, xPubKey string, metadata bux.Metadata) (*bux.Xpub, error) {
+	return d.client.NewXpub(ctx, xPubKey, d.modelOptions(metadata)...)
+}
+
+// RecordTransaction will record a transaction
+func (d *databaseBackend) RecordTransaction(ctx context.Context, xPubKey, txHex, draftID string,
+	metadata bux.Metadata) (*bux.Transaction, error) {
+
+	// Make sure the xpub exists
+	xpub, err := d.client.GetXpub(ctx, xPubKey)
+	if err != nil {
+		return nil, err
+	} else if xpub == nil {
+		return nil, ErrXpubNotFound
+	}
+
+	return d.client.RecordTransaction(ctx, xPubKey, txHex, draftID, d.modelOptions(metadata)...)
+}
+
+// RevokeAccessKey will revoke an access key (the raw xpub is required to prove ownership)
+func (d *databaseBackend) RevokeAccessKey(ctx context.Context, xPubKey, id string) (*bux.AccessKey, error) {
+	if len(xPubKey) == 0 {
+		return nil, ErrXpubIsRequired
+	}
+	return d.client.RevokeAccessKey(ctx, xPubKey, id)
+}
+
+// UpdateDestinationMetadata will update (merge) the metadata of a destination (by ID, address or locking script)
+func (d *databaseBackend) UpdateDestinationMetadata(ctx context.Context, xPubID, idOrAddressOrScript string,
+	metadata bux.Metadata) (*bux.Destination, error) {
+	destination, err := d.GetDestination(ctx, xPubID, idOrAddressOrScript)
+	if err != nil {
+		return nil, err
+	} else if destination == nil {
+		return nil, bux.ErrMissingDestination
+	}
+	return d.client.UpdateDestinationMetadataByID(ctx, xPubID, destination.ID, metadata)
+}
+
+// UpdatePaymailMetadata will update (merge) the metadata of a paymail address
+func (d *databaseBackend) UpdatePaymailMetadata(ctx context.Context, address string,
+	metadata bux.Metadata) (*bux.PaymailAddress, error) {
+	return d.client.UpdatePaymailAddressMetadata(ctx, address, metadata)
+}
+
+// UpdateTransactionMetadata will update (merge) the metadata of a transaction (the metadata is stored per xpub)
+func (d *databaseBackend) UpdateTransactionMetadata(ctx context.Context, xPubID, txID string,
+	metadata bux.Metadata) (*bux.Transaction, error) {
+	return d.client.UpdateTransactionMetadata(ctx, xPubID, txID, metadata)
+}
+
+// UpdateXpubMetadata will update (merge) the metadata of a xpub
+func (d *databaseBackend) UpdateXpubMetadata(ctx context.Context, _, xPubID string,
+	metadata bux.Metadata) (*bux.Xpub, error) {
+	return d.client.UpdateXpubMetadata(ctx, xPubID, metadata)
+}
+
+// WhatsOnChain will return the WhatsOnChain client from chainstate
+func (d *databaseBackend) WhatsOnChain() whatsonchain.ClientInterface {
+	return d.client.Chainstate().WhatsOnChain()
+}
+
+// copyConditions will copy the conditions (BUX modifies the conditions, IE: removes the direction)
+func copyConditions(conditions map[string]interface{}) *map[string]interface{} {
+	copied := make(map[string]interface{}, len(conditions))
+	for key, value := range conditions {
+		copied[key] = value
+	}
+	return &copied
+}
+
+// paymailConditions will return the conditions for the paymail addresses of the xpub (not deleted)
+func paymailConditions(xPubID string) *map[string]interface{} {
+	return &map[string]interface{}{
+		"deleted_at": nil,
+		"xpub_id":    xPubID,
+	}
+}
@@ -0,0 +1,706 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/bitcoinschema/go-bitcoin/v2"
+	"github.com/mrz1836/go-datastore"
+	"github.com/mrz1836/go-whatsonchain"
+)
+
+// Routes on the BUX server
+const (
+	serverRouteAccessKey          = "/access-key"
+	serverRouteAccessKeyCount     = "/access-key/count"
+	serverRouteAccessKeySearch    = "/access-key/search"
+	serverRouteAdminDestinations  = "/admin/destinations/search"
+	serverRouteAdminPaymail       = "/admin/paymail/get"
+	serverRouteAdminPaymailCount  = "/admin/paymails/count"
+	serverRouteAdminPaymailCreate = "/admin/paymail/create"
+	serverRouteAdminPaymailDelete = "/admin/paymail/delete"
+	serverRouteAdminPaymailSearch = "/admin/paymails/search"
+	serverRouteAdminXpub          = "/admin/xpub"
+	serverRouteAdminXpubSearch    = "/admin/xpubs/search"
+	serverRouteDestination        = "/destination"
+	serverRouteDestinationCount   = "/destination/count"
+	serverRouteDestinationSearch  = "/destination/search"
+	serverRouteTransaction        = "/transaction"
+	serverRouteTransactionCount   = "/transaction/count"
+	serverRouteTransactionRecord  = "/transaction/record"
+	serverRouteTransactionSearch  = "/transaction/search"
+	serverRouteUtxo               = "/utxo"
+	serverRouteUtxoCount          = "/utxo/count"
+	serverRouteUtxoSearch         = "/utxo/search"
+	serverRouteXpub               = "/xpub"
+)
+
+// defaultServerTimeout is the default timeout for requests to the BUX server
+const defaultServerTimeout = 30 * time.Second
+
+// serverBackend is the backend that talks to a remote BUX server over HTTP (server mode)
+type serverBackend struct {
+	config     *ServerConfig
+	httpClient *http.Client
+	userAgent  string
+	woc        whatsonchain.ClientInterface
+	xPubID     string // ID of the xpub that is authenticated (see: authenticatedXpubID)
+}
+
+// serverError is an error returned from the BUX server
+type serverError struct {
+	Message    string
+	StatusCode int
+}
+
+// Error will return the error message from the BUX server
+func (e *serverError) Error() string {
+	return fmt.Sprintf("bux server responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// newServerBackend will return a new backend for a remote BUX server
+func newServerBackend(config *ServerConfig, userAgent string) (*serverBackend, error) {
+
+	// Check the config
+	if config == nil || len(config.URL) == 0 {
+		return nil, ErrServerURLIsRequired
+	}
+	if _, err := url.ParseRequestURI(config.URL); err != nil {
+		return nil, fmt.Errorf("invalid server url: %w", err)
+	}
+
+	// Set the timeout
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultServerTimeout
+	}
+
+	return &serverBackend{
+		config:     config,
+		httpClient: &http.Client{Timeout: timeout},
+		userAgent:  userAgent,
+		woc:        newWhatsOnChainClient(userAgent, ""), // No chainstate is available in server mode
+	}, nil
+}
+
+// newWhatsOnChainClient will create the WhatsOnChain client (the API key is optional)
+func newWhatsOnChainClient(userAgent, apiKey string) whatsonchain.ClientInterface {
+	wocOptions := whatsonchain.ClientDefaultOptions()
+	wocOptions.UserAgent = userAgent
+	wocOptions.APIKey = apiKey
+	return whatsonchain.NewClient(whatsonchain.NetworkMain, wocOptions, nil)
+}
+
+// authenticate will set the authentication headers on the request
+//
+// The configured credentials take priority, the xPubKey is used as a fallback (signing disabled on the server)
+func (s *serverBackend) authenticate(req *http.Request, body, xPubKey string, admin bool) error {
+
+	// Admin requests use the admin key
+	if admin {
+		if len(s.config.AdminKey) == 0 {
+			return ErrServerAdminKeyIsRequired
+		}
+		return s.authenticateWithXpriv(req, body, s.config.AdminKey)
+	}
+
+	// Select the authentication method
+	if len(s.config.Xpriv) > 0 {
+		return s.authenticateWithXpriv(req, body, s.config.Xpriv)
+	} else if len(s.config.AccessKey) > 0 {
+		return bux.SetSignatureFromAccessKey(&req.Header, s.config.AccessKey, body)
+	} else if len(s.config.Xpub) > 0 {
+		req.Header.Set(bux.AuthHeader, s.config.Xpub)
+		return nil
+	} else if len(xPubKey) > 0 {
+		req.Header.Set(bux.AuthHeader, xPubKey)
+		return nil
+	}
+	return ErrServerAuthIsRequired
+}
+
+// authenticateWithXpriv will set the xpub header (and signature if enabled) using the xpriv
+func (s *serverBackend) authenticateWithXpriv(req *http.Request, body, xPriv string) error {
+	hdKey, err := bitcoin.GenerateHDKeyFromString(xPriv)
+	if err != nil {
+		return err
+	}
+	if s.config.SignRequest {
+		return bux.SetSignature(&req.Header, hdKey, body)
+	}
+	var xPubKey string
+	if xPubKey, err = bitcoin.GetExtendedPublicKey(hdKey); err != nil {
+		return err
+	}
+	req.Header.Set(bux.AuthHeader, xPubKey)
+	return nil
+}
+
+// authenticatedXpubID will return the ID of the xpub that the configured credentials authenticate as
+//
+// An access key does not reveal the xpub, so the server is asked once (the ID is kept for the next requests)
+func (s *serverBackend) authenticatedXpubID(ctx context.Context) (string, error) {
+	if len(s.xPubID) > 0 {
+		return s.xPubID, nil
+	}
+	if len(s.config.Xpriv) > 0 {
+		hdKey, err := bitcoin.GenerateHDKeyFromString(s.config.Xpriv)
+		if err != nil {
+			return "", err
+		}
+		var xPubKey string
+		if xPubKey, err = bitcoin.GetExtendedPublicKey(hdKey); err != nil {
+			return "", err
+		}
+		s.xPubID = utils.Hash(xPubKey)
+	} else if len(s.config.AccessKey) > 0 {
+		var xpub *bux.Xpub
+		if err := s.request(ctx, http.MethodGet, serverRouteXpub, nil, nil, "", false, &xpub); err != nil {
+			return "", err
+		} else if xpub == nil || len(xpub.ID) == 0 {
+			return "", ErrXpubNotFound
+		}
+		s.xPubID = xpub.ID
+	} else if len(s.config.Xpub) > 0 {
+		s.xPubID = utils.Hash(s.config.Xpub)
+	} else {
+		return "", ErrServerAuthIsRequired
+	}
+	return s.xPubID, nil
+}
+
+// checkXpubID will check that the xpub ID is the xpub that is authenticated
+//
+// The server only returns the data of the xpub that is authenticated, a request for another xpub
+// would silently return the wrong data (an empty xpub ID is not checked)
+func (s *serverBackend) checkXpubID(ctx context.Context, xPubID string) error {
+	if len(xPubID) == 0 {
+		return nil
+	}
+	authenticatedID, err := s.authenticatedXpubID(ctx)
+	if err != nil {
+		return err
+	} else if authenticatedID != xPubID {
+		return fmt.Errorf("%w: xpub id %s was requested, the server is authenticated as %s",
+			ErrServerXpubMismatch, xPubID, authenticatedID)
+	}
+	return nil
+}
+
+// checkXpubKey will check that the xpub key is the xpub that is authenticated
+//
+// Without configured credentials the xpub key itself is used to authenticate, so it always matches
+func (s *serverBackend) checkXpubKey(ctx context.Context, xPubKey string) error {
+	if len(xPubKey) == 0 || (len(s.config.Xpriv) == 0 && len(s.config.AccessKey) == 0 && len(s.config.Xpub) == 0) {
+		return nil
+	}
+	return s.checkXpubID(ctx, utils.Hash(xPubKey))
+}
+
+// request will make a request to the BUX server and decode the response into the model
+func (s *serverBackend) request(ctx context.Context, method, route string, query url.Values,
+	payload interface{}, xPubKey string, admin bool, model interface{}) error {
+
+	// Encode the payload
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+
+	// Build the URL
+	endpoint := strings.TrimSuffix(s.config.URL, "/") + route
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+	if err = s.authenticate(req, string(body), xPubKey, admin); err != nil {
+		return err
+	}
+
+	// Fire the request
+	var resp *http.Response
+	if resp, err = s.httpClient.Do(req); err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	// Read the response
+	var respBody []byte
+	if respBody, err = io.ReadAll(resp.Body); err != nil {
+		return err
+	}
+
+	// Check the status code
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return newServerError(resp.StatusCode, respBody)
+	}
+
+	// Decode the response
+	if model != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, model)
+	}
+	return nil
+}
+
+// newServerError will create a server error from the response body
+func newServerError(statusCode int, body []byte) error {
+	message := strings.TrimSpace(string(body))
+
+	// The server can respond with a JSON string, or an object with a message
+	var jsonMessage string
+	var jsonObject struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &jsonMessage); err == nil {
+		message = jsonMessage
+	} else if err = json.Unmarshal(body, &jsonObject); err == nil && len(jsonObject.Message) > 0 {
+		message = jsonObject.Message
+	}
+	if len(message) == 0 {
+		message = http.StatusText(statusCode)
+	}
+
+	return &serverError{Message: message, StatusCode: statusCode}
+}
+
+// CancelDraftTransaction is not available on the BUX server
+func (s *serverBackend) CancelDraftTransaction(_ context.Context, _ string) (*bux.DraftTransaction, error) {
+	return nil, ErrNotSupportedInServerMode
+}
+
+// Close will close any idle connections to the server
+func (s *serverBackend) Close(_ context.Context) error {
+	s.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// DeletePaymail will delete a paymail address (requires the admin key)
+func (s *serverBackend) DeletePaymail(ctx context.Context, address string) error {
+	return s.request(ctx, http.MethodDelete, serverRouteAdminPaymailDelete, nil, map[string]interface{}{
+		"address": address,
+	}, "", true, nil)
+}
+
+// GetAccessKey will get an access key by ID (the xpub id is optional, the server checks it belongs to the xpub that is authenticated)
+func (s *serverBackend) GetAccessKey(ctx context.Context, xPubID, id string) (accessKey *bux.AccessKey, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(
+		ctx, http.MethodGet, serverRouteAccessKey, url.Values{"id": []string{id}},
+		nil, "", false, &accessKey,
+	)
+	return
+}
+
+// GetAccessKeys will search the access keys (the xpub id must be the xpub that is authenticated)
+func (s *serverBackend) GetAccessKeys(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) (accessKeys []*bux.AccessKey, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteAccessKeySearch, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+		"params":     queryParams,
+	}, "", false, &accessKeys)
+	return
+}
+
+// GetAccessKeysCount will count the access keys (the xpub id must be the xpub that is authenticated)
+func (s *serverBackend) GetAccessKeysCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (count int64, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteAccessKeyCount, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+	}, "", false, &count)
+	return
+}
+
+// GetDestination will get a destination by ID, address or locking script
+func (s *serverBackend) GetDestination(ctx context.Context, xPubID,
+	idOrAddressOrScript string) (destination *bux.Destination, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(
+		ctx, http.MethodGet, serverRouteDestination,
+		url.Values{destinationField(idOrAddressOrScript): []string{idOrAddressOrScript}},
+		nil, "", false, &destination,
+	)
+	return
+}
+
+// destinationField will detect which field is being used (id, address or locking script)
+func destinationField(idOrAddressOrScript string) string {
+	if len(idOrAddressOrScript) == 64 {
+		return "id"
+	} else if valid, _ := bitcoin.ValidA58([]byte(idOrAddressOrScript)); valid {
+		return "address"
+	}
+	return "locking_script"
+}
+
+// FindDestination will find the destination of any xpub by locking script (requires the admin key, nil if not found)
+func (s *serverBackend) FindDestination(ctx context.Context, lockingScript string) (*bux.Destination, error) {
+	var destinations []*bux.Destination
+	if err := s.request(ctx, http.MethodPost, serverRouteAdminDestinations, nil, map[string]interface{}{
+		"conditions": map[string]interface{}{"locking_script": lockingScript},
+	}, "", true, &destinations); err != nil || len(destinations) == 0 {
+		return nil, err
+	}
+	return destinations[0], nil
+}
+
+// GetDestinations will search the destinations (the xpub id must be the xpub that is authenticated)
+func (s *serverBackend) GetDestinations(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) (destinations []*bux.Destination, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteDestinationSearch, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+		"params":     queryParams,
+	}, "", false, &destinations)
+	return
+}
+
+// GetDestinationsCount will count the destinations (the xpub id must be the xpub that is authenticated)
+func (s *serverBackend) GetDestinationsCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (count int64, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteDestinationCount, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+	}, "", false, &count)
+	return
+}
+
+// GetDraftTransaction is not available on the BUX server
+func (s *serverBackend) GetDraftTransaction(_ context.Context, _ string) (*bux.DraftTransaction, error) {
+	return nil, ErrNotSupportedInServerMode
+}
+
+// GetDraftTransactions is not available on the BUX server
+func (s *serverBackend) GetDraftTransactions(_ context.Context, _ string, _ *bux.Metadata,
+	_ map[string]interface{}, _ *datastore.QueryParams) ([]*bux.DraftTransaction, error) {
+	return nil, ErrNotSupportedInServerMode
+}
+
+// GetDraftTransactionsCount is not available on the BUX server
+func (s *serverBackend) GetDraftTransactionsCount(_ context.Context, _ string, _ *bux.Metadata,
+	_ map[string]interface{}) (int64, error) {
+	return 0, ErrNotSupportedInServerMode
+}
+
+// GetPaymail will get a paymail address (requires the admin key)
+func (s *serverBackend) GetPaymail(ctx context.Context, address string) (paymail *bux.PaymailAddress, err error) {
+	err = s.request(ctx, http.MethodPost, serverRouteAdminPaymail, nil, map[string]interface{}{
+		"address": address,
+	}, "", true, &paymail)
+	return
+}
+
+// GetPaymails will search the paymail addresses for the xpub (requires the admin key)
+func (s *serverBackend) GetPaymails(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	queryParams *datastore.QueryParams) (paymails []*bux.PaymailAddress, err error) {
+	err = s.request(ctx, http.MethodPost, serverRouteAdminPaymailSearch, nil, map[string]interface{}{
+		"conditions": *paymailConditions(xPubID),
+		"metadata":   metadata,
+		"params":     queryParams,
+	}, "", true, &paymails)
+	return
+}
+
+// GetPaymailsCount will count the paymail addresses for the xpub (requires the admin key)
+func (s *serverBackend) GetPaymailsCount(ctx context.Context, xPubID string,
+	metadata *bux.Metadata) (count int64, err error) {
+	err = s.request(ctx, http.MethodPost, serverRouteAdminPaymailCount, nil, map[string]interface{}{
+		"conditions": *paymailConditions(xPubID),
+		"metadata":   metadata,
+	}, "", true, &count)
+	return
+}
+
+// GetTransaction will get a transaction by ID
+func (s *serverBackend) GetTransaction(ctx context.Context, xPubID,
+	txID string) (transaction *bux.Transaction, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(
+		ctx, http.MethodGet, serverRouteTransaction, url.Values{"id": []string{txID}},
+		nil, "", false, &transaction,
+	)
+	return
+}
+
+// GetTransactions will search the transactions (the xpub id must be the xpub that is authenticated)
+func (s *serverBackend) GetTransactions(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) (transactions []*bux.Transaction, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteTransactionSearch, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+		"params":     queryParams,
+	}, "", false, &transactions)
+	return
+}
+
+// GetTransactionsCount will count the transactions (the xpub id must be the xpub that is authenticated)
+func (s *serverBackend) GetTransactionsCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (count int64, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteTransactionCount, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+	}, "", false, &count)
+	return
+}
+
+// GetUtxo will get a utxo by transaction ID and output index
+func (s *serverBackend) GetUtxo(ctx context.Context, txID string, outputIndex uint32) (utxo *bux.Utxo, err error) {
+	err = s.request(ctx, http.MethodGet, serverRouteUtxo, url.Values{
+		"tx_id":        []string{txID},
+		"output_index": []string{strconv.FormatUint(uint64(outputIndex), 10)},
+	}, nil, "", false, &utxo)
+	return
+}
+
+// GetUtxos will search the utxos (the xpub id must be the xpub that is authenticated)
+func (s *serverBackend) GetUtxos(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}, queryParams *datastore.QueryParams) (utxos []*bux.Utxo, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteUtxoSearch, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+		"params":     queryParams,
+	}, "", false, &utxos)
+	return
+}
+
+// GetUtxosCount will count the utxos (the xpub id must be the xpub that is authenticated)
+func (s *serverBackend) GetUtxosCount(ctx context.Context, xPubID string, metadata *bux.Metadata,
+	conditions map[string]interface{}) (count int64, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteUtxoCount, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+	}, "", false, &count)
+	return
+}
+
+// GetXpub will get the xpub (the server returns the xpub that is authenticated)
+func (s *serverBackend) GetXpub(ctx context.Context, xPubKey string) (xpub *bux.Xpub, err error) {
+	if err = s.checkXpubKey(ctx, xPubKey); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodGet, serverRouteXpub, nil, nil, xPubKey, false, &xpub)
+	return
+}
+
+// GetXpubByID will get a xpub by ID (requires the admin key)
+func (s *serverBackend) GetXpubByID(ctx context.Context, xPubID string) (*bux.Xpub, error) {
+	xpubs, err := s.searchXpubs(ctx, map[string]interface{}{"id": xPubID}, nil)
+	if err != nil {
+		return nil, err
+	} else if len(xpubs) == 0 {
+		return nil, ErrXpubNotFound
+	}
+	return xpubs[0], nil
+}
+
+// GetXpubs will get all xpubs matching the metadata (requires the admin key)
+func (s *serverBackend) GetXpubs(ctx context.Context, metadata *bux.Metadata) ([]*bux.Xpub, error) {
+	return s.searchXpubs(ctx, nil, metadata)
+}
+
+// searchXpubs will search the xpubs using the admin endpoint
+func (s *serverBackend) searchXpubs(ctx context.Context, conditions map[string]interface{},
+	metadata *bux.Metadata) (xpubs []*bux.Xpub, err error) {
+	err = s.request(ctx, http.MethodPost, serverRouteAdminXpubSearch, nil, map[string]interface{}{
+		"conditions": conditions,
+		"metadata":   metadata,
+	}, "", true, &xpubs)
+	return
+}
+
+// NewAccessKey will create a new access key for the xpub
+func (s *serverBackend) NewAccessKey(ctx context.Context, xPubKey string,
+	metadata bux.Metadata) (accessKey *bux.AccessKey, err error) {
+	if err = s.checkXpubKey(ctx, xPubKey); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteAccessKey, nil, map[string]interface{}{
+		"metadata": metadata,
+	}, xPubKey, false, &accessKey)
+	return
+}
+
+// NewDestination will create a new destination for the xpub
+//
+// The server only creates external P2PKH destinations, other chains and types (or monitoring) are not supported
+func (s *serverBackend) NewDestination(ctx context.Context, xPubKey string, chain uint32, destinationType string,
+	monitor bool, metadata bux.Metadata) (destination *bux.Destination, err error) {
+	if chain != utils.ChainExternal || destinationType != utils.ScriptTypePubKeyHash {
+		return nil, fmt.Errorf("%w: only external %s destinations can be created",
+			ErrNotSupportedInServerMode, utils.ScriptTypePubKeyHash)
+	} else if monitor {
+		return nil, fmt.Errorf("%w: destinations cannot be monitored", ErrNotSupportedInServerMode)
+	} else if err = s.checkXpubKey(ctx, xPubKey); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteDestination, nil, map[string]interface{}{
+		"metadata": metadata,
+	}, xPubKey, false, &destination)
+	return
+}
+
+// NewPaymail will create a new paymail address for the xpub (requires the admin key)
+func (s *serverBackend) NewPaymail(ctx context.Context, xPubKey, address, publicName, avatar string,
+	metadata bux.Metadata) (paymail *bux.PaymailAddress, err error) {
+	err = s.request(ctx, http.MethodPost, serverRouteAdminPaymailCreate, nil, map[string]interface{}{
+		"address":     address,
+		"avatar":      avatar,
+		"key":         xPubKey,
+		"metadata":    metadata,
+		"public_name": publicName,
+	}, "", true, &paymail)
+	return
+}
+
+// NewTransaction will create a new draft transaction
+func (s *serverBackend) NewTransaction(ctx context.Context, xPubKey string, config *bux.TransactionConfig,
+	metadata bux.Metadata) (draft *bux.DraftTransaction, err error) {
+	if err = s.checkXpubKey(ctx, xPubKey); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteTransaction, nil, map[string]interface{}{
+		"config":   config,
+		"metadata": metadata,
+	}, xPubKey, false, &draft)
+	return
+}
+
+// NewXpub will register a new xpub (requires the admin key)
+func (s *serverBackend) NewXpub(ctx context.Context, xPubKey string,
+	metadata bux.Metadata) (xpub *bux.Xpub, err error) {
+	err = s.request(ctx, http.MethodPost, serverRouteAdminXpub, nil, map[string]interface{}{
+		"key":      xPubKey,
+		"metadata": metadata,
+	}, "", true, &xpub)
+	return
+}
+
+// RecordTransaction will record a transaction
+func (s *serverBackend) RecordTransaction(ctx context.Context, xPubKey, txHex, draftID string,
+	metadata bux.Metadata) (transaction *bux.Transaction, err error) {
+	if err = s.checkXpubKey(ctx, xPubKey); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPost, serverRouteTransactionRecord, nil, map[string]interface{}{
+		"hex":          txHex,
+		"metadata":     metadata,
+		"reference_id": draftID,
+	}, xPubKey, false, &transaction)
+	return
+}
+
+// RevokeAccessKey will revoke an access key
+func (s *serverBackend) RevokeAccessKey(ctx context.Context, xPubKey,
+	id string) (accessKey *bux.AccessKey, err error) {
+	if err = s.checkXpubKey(ctx, xPubKey); err != nil {
+		return
+	}
+	err = s.request(
+		ctx, http.MethodDelete, serverRouteAccessKey, url.Values{"id": []string{id}},
+		nil, xPubKey, false, &accessKey,
+	)
+	return
+}
+
+// UpdateDestinationMetadata will update (merge) the metadata of a destination (by ID, address or locking script)
+func (s *serverBackend) UpdateDestinationMetadata(ctx context.Context, xPubID, idOrAddressOrScript string,
+	metadata bux.Metadata) (destination *bux.Destination, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPatch, serverRouteDestination, nil, map[string]interface{}{
+		destinationField(idOrAddressOrScript): idOrAddressOrScript,
+		"metadata":                            metadata,
+	}, "", false, &destination)
+	return
+}
+
+// UpdateTransactionMetadata will update (merge) the metadata of a transaction (the xpub id must be the xpub that
+// is authenticated)
+func (s *serverBackend) UpdateTransactionMetadata(ctx context.Context, xPubID, txID string,
+	metadata bux.Metadata) (transaction *bux.Transaction, err error) {
+	if err = s.checkXpubID(ctx, xPubID); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPatch, serverRouteTransaction, nil, map[string]interface{}{
+		"id":       txID,
+		"metadata": metadata,
+	}, "", false, &transaction)
+	return
+}
+
+// UpdateXpubMetadata will update (merge) the metadata of the xpub (the server updates the xpub that is authenticated)
+func (s *serverBackend) UpdateXpubMetadata(ctx context.Context, xPubKey, _ string,
+	metadata bux.Metadata) (xpub *bux.Xpub, err error) {
+	if err = s.checkXpubKey(ctx, xPubKey); err != nil {
+		return
+	}
+	err = s.request(ctx, http.MethodPatch, serverRouteXpub, nil, map[string]interface{}{
+		"metadata": metadata,
+	}, xPubKey, false, &xpub)
+	return
+}
+
+// UpdatePaymailMetadata is not available on the BUX server
+func (s *serverBackend) UpdatePaymailMetadata(_ context.Context, _ string,
+	_ bux.Metadata) (*bux.PaymailAddress, error) {
+	return nil, ErrNotSupportedInServerMode
+}
+
+// WhatsOnChain will return the standalone WhatsOnChain client
+func (s *serverBackend) WhatsOnChain() whatsonchain.ClientInterface {
+	return s.woc
+}
@@ -0,0 +1,669 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/bitcoinschema/go-bitcoin/v2"
+	"github.com/mrz1836/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testXpub is a valid xpub used for testing
+const testXpub = "xpub661MyMwAqRbcGpZVrSHU2pkDXgDWFXQDXPBXJ5kvfYy5Bjd4mSHhBFhTdkxBJ3NBSvkaLvGsGb6gvYyhaxs37zmFJ5tvZ6kQaHMUABW7RVn"
+
+// testXpubID is the ID of the test xpub
+var testXpubID = utils.Hash(testXpub)
+
+// newTestXpub will return the xpub of the xpriv (testXpub has an invalid checksum)
+func newTestXpub(t *testing.T, xpriv string) string {
+	hdKey, err := bitcoin.GenerateHDKeyFromString(xpriv)
+	require.NoError(t, err)
+	var xPubKey string
+	xPubKey, err = bitcoin.GetExtendedPublicKey(hdKey)
+	require.NoError(t, err)
+	return xPubKey
+}
+
+// newTestServer will return a stand-in BUX server that records the last request
+func newTestServer(t *testing.T, status int, response interface{},
+	lastRequest **http.Request, lastBody *map[string]interface{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		*lastRequest = req
+		if lastBody != nil && req.ContentLength > 0 {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(lastBody))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewServerBackend(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing config", func(t *testing.T) {
+		backend, err := newServerBackend(nil, "test")
+		require.ErrorIs(t, err, ErrServerURLIsRequired)
+		assert.Nil(t, backend)
+	})
+
+	t.Run("missing url", func(t *testing.T) {
+		backend, err := newServerBackend(&ServerConfig{}, "test")
+		require.ErrorIs(t, err, ErrServerURLIsRequired)
+		assert.Nil(t, backend)
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		backend, err := newServerBackend(&ServerConfig{URL: "not-a-url"}, "test")
+		require.Error(t, err)
+		assert.Nil(t, backend)
+	})
+
+	t.Run("valid config", func(t *testing.T) {
+		backend, err := newServerBackend(&ServerConfig{URL: "http://localhost:3003/v1"}, "test")
+		require.NoError(t, err)
+		require.NotNil(t, backend)
+		assert.Equal(t, defaultServerTimeout, backend.httpClient.Timeout)
+		assert.NotNil(t, backend.WhatsOnChain())
+	})
+}
+
+func TestServerBackend_GetXpub(t *testing.T) {
+	t.Parallel()
+
+	t.Run("xpub from the command is used as a fallback", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, &bux.Xpub{ID: "xpub-id"}, &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL + "/v1"}, "test-agent")
+		require.NoError(t, err)
+
+		var xpub *bux.Xpub
+		xpub, err = backend.GetXpub(context.Background(), testXpub)
+		require.NoError(t, err)
+		require.NotNil(t, xpub)
+		assert.Equal(t, "xpub-id", xpub.ID)
+		assert.Equal(t, http.MethodGet, req.Method)
+		assert.Equal(t, "/v1"+serverRouteXpub, req.URL.Path)
+		assert.Equal(t, testXpub, req.Header.Get(bux.AuthHeader))
+		assert.Equal(t, "test-agent", req.Header.Get("User-Agent"))
+	})
+
+	t.Run("configured xpub takes priority", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, &bux.Xpub{ID: "xpub-id"}, &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: "configured-xpub"}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.GetXpub(context.Background(), "configured-xpub")
+		require.NoError(t, err)
+		assert.Equal(t, "configured-xpub", req.Header.Get(bux.AuthHeader))
+	})
+
+	t.Run("another xpub than the configured xpriv", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, &bux.Xpub{ID: "xpub-id"}, &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpriv: testMnemonicVectors[0].xpriv}, "test")
+		require.NoError(t, err)
+
+		// The xpub of the configured xpriv
+		_, err = backend.GetXpub(context.Background(), newTestXpub(t, testMnemonicVectors[0].xpriv))
+		require.NoError(t, err)
+		require.NotNil(t, req)
+
+		// Another xpub is not sent to the server (the authenticated xpub would be returned instead)
+		req = nil
+		_, err = backend.GetXpub(context.Background(), testXpub)
+		require.ErrorIs(t, err, ErrServerXpubMismatch)
+		assert.Nil(t, req)
+	})
+
+	t.Run("signed request using the xpriv", func(t *testing.T) {
+		xPriv, xPub, err := bitcoin.GenerateHDKeyPair(bitcoin.SecureSeedLength)
+		require.NoError(t, err)
+
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, &bux.Xpub{ID: "xpub-id"}, &req, nil)
+		var backend *serverBackend
+		backend, err = newServerBackend(&ServerConfig{URL: server.URL, Xpriv: xPriv, SignRequest: true}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.GetXpub(context.Background(), "")
+		require.NoError(t, err)
+		assert.Equal(t, xPub, req.Header.Get(bux.AuthHeader))
+		assert.NotEmpty(t, req.Header.Get(bux.AuthSignature))
+		assert.NotEmpty(t, req.Header.Get(bux.AuthHeaderNonce))
+	})
+
+	t.Run("missing authentication", func(t *testing.T) {
+		backend, err := newServerBackend(&ServerConfig{URL: "http://localhost:3003/v1"}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.GetXpub(context.Background(), "")
+		require.ErrorIs(t, err, ErrServerAuthIsRequired)
+	})
+
+	t.Run("error response from the server", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusUnauthorized, "xpub not authorized", &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.GetXpub(context.Background(), testXpub)
+		require.Error(t, err)
+		var srvErr *serverError
+		require.ErrorAs(t, err, &srvErr)
+		assert.Equal(t, http.StatusUnauthorized, srvErr.StatusCode)
+		assert.Equal(t, "xpub not authorized", srvErr.Message)
+	})
+}
+
+func TestServerBackend_NewXpub(t *testing.T) {
+	t.Parallel()
+
+	t.Run("admin key is required", func(t *testing.T) {
+		backend, err := newServerBackend(&ServerConfig{URL: "http://localhost:3003/v1"}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.NewXpub(context.Background(), testXpub, nil)
+		require.ErrorIs(t, err, ErrServerAdminKeyIsRequired)
+	})
+
+	t.Run("registers the xpub using the admin key", func(t *testing.T) {
+		adminXpriv, adminXpub, err := bitcoin.GenerateHDKeyPair(bitcoin.SecureSeedLength)
+		require.NoError(t, err)
+
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusCreated, &bux.Xpub{ID: "new-xpub-id"}, &req, &body)
+		var backend *serverBackend
+		backend, err = newServerBackend(&ServerConfig{URL: server.URL, AdminKey: adminXpriv}, "test")
+		require.NoError(t, err)
+
+		var xpub *bux.Xpub
+		xpub, err = backend.NewXpub(context.Background(), testXpub, bux.Metadata{"user": "test"})
+		require.NoError(t, err)
+		require.NotNil(t, xpub)
+		assert.Equal(t, "new-xpub-id", xpub.ID)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, serverRouteAdminXpub, req.URL.Path)
+		assert.Equal(t, adminXpub, req.Header.Get(bux.AuthHeader))
+		assert.Equal(t, testXpub, body["key"])
+		assert.Equal(t, map[string]interface{}{"user": "test"}, body["metadata"])
+	})
+}
+
+func TestServerBackend_GetDestination(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+		field string
+	}{
+		{"by id", "1a0b10d4eda0636aae1709e7e7080485a4d99af3ca2962c6e677cf5b53d8ab8c", "id"},
+		{"by address", "1CfaQw9udYNPccssFJFZ94DN8MqNZm9nGt", "address"},
+		{"by locking script", "76a9147ff514e6ae3deb46e6644caac5cdd0bf2388906588ac", "locking_script"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var req *http.Request
+			server := newTestServer(t, http.StatusOK, &bux.Destination{ID: "destination-id"}, &req, nil)
+			backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+			require.NoError(t, err)
+
+			var destination *bux.Destination
+			destination, err = backend.GetDestination(context.Background(), testXpubID, test.value)
+			require.NoError(t, err)
+			require.NotNil(t, destination)
+			assert.Equal(t, "destination-id", destination.ID)
+			assert.Equal(t, serverRouteDestination, req.URL.Path)
+			assert.Equal(t, test.value, req.URL.Query().Get(test.field))
+		})
+	}
+}
+
+func TestServerBackend_CancelDraftTransaction(t *testing.T) {
+	t.Parallel()
+
+	backend, err := newServerBackend(&ServerConfig{URL: "http://localhost:3003/v1"}, "test")
+	require.NoError(t, err)
+
+	_, err = backend.CancelDraftTransaction(context.Background(), "draft-id")
+	require.ErrorIs(t, err, ErrNotSupportedInServerMode)
+}
+
+func TestServerBackend_CheckXpubID(t *testing.T) {
+	t.Parallel()
+
+	t.Run("xpub of the configured xpriv", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, []*bux.Utxo{}, &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpriv: testMnemonicVectors[0].xpriv}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.GetUtxos(
+			context.Background(), utils.Hash(newTestXpub(t, testMnemonicVectors[0].xpriv)), nil, nil, nil,
+		)
+		require.NoError(t, err)
+		require.NotNil(t, req)
+
+		// Another xpub is not sent to the server
+		req = nil
+		_, err = backend.GetUtxos(context.Background(), testXpubID, nil, nil, nil)
+		require.ErrorIs(t, err, ErrServerXpubMismatch)
+		assert.Nil(t, req)
+	})
+
+	t.Run("only the xpub is configured", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, int64(3), &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		count, countErr := backend.GetTransactionsCount(context.Background(), testXpubID, nil, nil)
+		require.NoError(t, countErr)
+		assert.Equal(t, int64(3), count)
+		assert.Equal(t, testXpub, req.Header.Get(bux.AuthHeader))
+
+		_, err = backend.GetDestinations(context.Background(), "another-xpub-id", nil, nil, nil)
+		require.ErrorIs(t, err, ErrServerXpubMismatch)
+		_, err = backend.GetTransaction(context.Background(), "another-xpub-id", "tx-id")
+		require.ErrorIs(t, err, ErrServerXpubMismatch)
+		_, err = backend.UpdateTransactionMetadata(context.Background(), "another-xpub-id", "tx-id", nil)
+		require.ErrorIs(t, err, ErrServerXpubMismatch)
+		assert.Equal(t, exitCodeUnauthorized, exitCode(err))
+	})
+
+	t.Run("access key asks the server once", func(t *testing.T) {
+		xpubRequests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if req.URL.Path == serverRouteXpub {
+				xpubRequests++
+				require.NoError(t, json.NewEncoder(w).Encode(&bux.Xpub{ID: testXpubID}))
+				return
+			}
+			require.NoError(t, json.NewEncoder(w).Encode([]*bux.AccessKey{}))
+		}))
+		t.Cleanup(server.Close)
+		backend, err := newServerBackend(&ServerConfig{
+			AccessKey: "54035dd4c7dda99ac473905a3d82f7864322b49bab1ff441cc457183b9bd8abd", URL: server.URL,
+		}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.GetAccessKeys(context.Background(), testXpubID, nil, nil, nil)
+		require.NoError(t, err)
+		_, err = backend.GetAccessKeys(context.Background(), "another-xpub-id", nil, nil, nil)
+		require.ErrorIs(t, err, ErrServerXpubMismatch)
+		assert.Equal(t, 1, xpubRequests)
+	})
+
+	t.Run("no authentication", func(t *testing.T) {
+		backend, err := newServerBackend(&ServerConfig{URL: "http://localhost:3003/v1"}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.GetUtxosCount(context.Background(), testXpubID, nil, nil)
+		require.ErrorIs(t, err, ErrServerAuthIsRequired)
+	})
+}
+
+func TestServerBackend_GetDraftTransactions(t *testing.T) {
+	t.Parallel()
+
+	backend, err := newServerBackend(&ServerConfig{URL: "http://localhost:3003/v1"}, "test")
+	require.NoError(t, err)
+
+	_, err = backend.GetDraftTransaction(context.Background(), "draft-id")
+	require.ErrorIs(t, err, ErrNotSupportedInServerMode)
+	_, err = backend.GetDraftTransactions(context.Background(), "xpub-id", nil, nil, nil)
+	require.ErrorIs(t, err, ErrNotSupportedInServerMode)
+	_, err = backend.GetDraftTransactionsCount(context.Background(), "xpub-id", nil, nil)
+	require.ErrorIs(t, err, ErrNotSupportedInServerMode)
+}
+
+func TestServerBackend_FindDestination(t *testing.T) {
+	t.Parallel()
+
+	adminXpriv, _, err := bitcoin.GenerateHDKeyPair(bitcoin.SecureSeedLength)
+	require.NoError(t, err)
+
+	t.Run("searches the destinations of all xpubs", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusOK, []*bux.Destination{{XpubID: "xpub-id"}}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, AdminKey: adminXpriv}, "test")
+		require.NoError(t, err)
+
+		var destination *bux.Destination
+		destination, err = backend.FindDestination(context.Background(), "76a914")
+		require.NoError(t, err)
+		require.NotNil(t, destination)
+		assert.Equal(t, "xpub-id", destination.XpubID)
+		assert.Equal(t, serverRouteAdminDestinations, req.URL.Path)
+		assert.Equal(t, map[string]interface{}{"locking_script": "76a914"}, body["conditions"])
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, []*bux.Destination{}, &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, AdminKey: adminXpriv}, "test")
+		require.NoError(t, err)
+
+		var destination *bux.Destination
+		destination, err = backend.FindDestination(context.Background(), "76a914")
+		require.NoError(t, err)
+		assert.Nil(t, destination)
+	})
+}
+
+func TestServerBackend_Destinations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("creates an external p2pkh destination", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusCreated, &bux.Destination{ID: "destination-id"}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL}, "test")
+		require.NoError(t, err)
+
+		var destination *bux.Destination
+		destination, err = backend.NewDestination(
+			context.Background(), testXpub, utils.ChainExternal, utils.ScriptTypePubKeyHash, false,
+			bux.Metadata{"name": "test"},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "destination-id", destination.ID)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, serverRouteDestination, req.URL.Path)
+		assert.Equal(t, map[string]interface{}{"name": "test"}, body["metadata"])
+	})
+
+	t.Run("other chains and types are not supported", func(t *testing.T) {
+		backend, err := newServerBackend(&ServerConfig{URL: "http://localhost:3003/v1"}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.NewDestination(
+			context.Background(), testXpub, utils.ChainInternal, utils.ScriptTypePubKeyHash, false, nil,
+		)
+		require.ErrorIs(t, err, ErrNotSupportedInServerMode)
+		_, err = backend.NewDestination(
+			context.Background(), testXpub, utils.ChainExternal, utils.ScriptTypeMultiSig, false, nil,
+		)
+		require.ErrorIs(t, err, ErrNotSupportedInServerMode)
+		_, err = backend.NewDestination(
+			context.Background(), testXpub, utils.ChainExternal, utils.ScriptTypePubKeyHash, true, nil,
+		)
+		require.ErrorIs(t, err, ErrNotSupportedInServerMode)
+	})
+
+	t.Run("searches with the conditions and params", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusOK, []*bux.Destination{{Address: "address"}}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		var destinations []*bux.Destination
+		destinations, err = backend.GetDestinations(
+			context.Background(), testXpubID, &bux.Metadata{"key": "value"},
+			map[string]interface{}{"chain": utils.ChainInternal}, &datastore.QueryParams{Page: 1, PageSize: 100},
+		)
+		require.NoError(t, err)
+		require.Len(t, destinations, 1)
+		assert.Equal(t, serverRouteDestinationSearch, req.URL.Path)
+		assert.Equal(t, map[string]interface{}{"chain": float64(1)}, body["conditions"])
+		assert.Equal(t, map[string]interface{}{"key": "value"}, body["metadata"])
+		assert.Equal(t, map[string]interface{}{"page": float64(1), "page_size": float64(100)}, body["params"])
+	})
+
+	t.Run("count", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, 100, &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		var count int64
+		count, err = backend.GetDestinationsCount(context.Background(), testXpubID, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), count)
+		assert.Equal(t, serverRouteDestinationCount, req.URL.Path)
+	})
+}
+
+func TestServerBackend_UpdateMetadata(t *testing.T) {
+	t.Parallel()
+
+	t.Run("xpub", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusOK, &bux.Xpub{ID: "xpub-id"}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL}, "test")
+		require.NoError(t, err)
+
+		var xpub *bux.Xpub
+		xpub, err = backend.UpdateXpubMetadata(context.Background(), testXpub, "xpub-id", bux.Metadata{"name": nil})
+		require.NoError(t, err)
+		assert.Equal(t, "xpub-id", xpub.ID)
+		assert.Equal(t, http.MethodPatch, req.Method)
+		assert.Equal(t, serverRouteXpub, req.URL.Path)
+		assert.Equal(t, testXpub, req.Header.Get(bux.AuthHeader))
+		assert.Equal(t, map[string]interface{}{"name": nil}, body["metadata"])
+	})
+
+	t.Run("destination by address", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusOK, &bux.Destination{ID: "destination-id"}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.UpdateDestinationMetadata(
+			context.Background(), testXpubID, "1CfaQw9udYNPccssFJFZ94DN8MqNZm9nGt", bux.Metadata{"name": "test"},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, http.MethodPatch, req.Method)
+		assert.Equal(t, serverRouteDestination, req.URL.Path)
+		assert.Equal(t, "1CfaQw9udYNPccssFJFZ94DN8MqNZm9nGt", body["address"])
+		assert.Equal(t, map[string]interface{}{"name": "test"}, body["metadata"])
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusOK, &bux.Transaction{DraftID: "draft-id"}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.UpdateTransactionMetadata(context.Background(), testXpubID, "tx-id", bux.Metadata{"name": "test"})
+		require.NoError(t, err)
+		assert.Equal(t, http.MethodPatch, req.Method)
+		assert.Equal(t, serverRouteTransaction, req.URL.Path)
+		assert.Equal(t, "tx-id", body["id"])
+		assert.Equal(t, map[string]interface{}{"name": "test"}, body["metadata"])
+	})
+}
+
+func TestServerBackend_RecordTransaction(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records the transaction hex and draft", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusCreated, &bux.Transaction{DraftID: "draft-id"}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL}, "test")
+		require.NoError(t, err)
+
+		var tx *bux.Transaction
+		tx, err = backend.RecordTransaction(context.Background(), testXpub, "0100", "draft-id", nil)
+		require.NoError(t, err)
+		require.NotNil(t, tx)
+		assert.Equal(t, "draft-id", tx.DraftID)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, serverRouteTransactionRecord, req.URL.Path)
+		assert.Equal(t, testXpub, req.Header.Get(bux.AuthHeader))
+		assert.Equal(t, "0100", body["hex"])
+		assert.Equal(t, "draft-id", body["reference_id"])
+	})
+}
+
+func TestServerBackend_GetTransactions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("searches with the conditions and params", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusOK, []*bux.Transaction{{BlockHeight: 700000}}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		var transactions []*bux.Transaction
+		transactions, err = backend.GetTransactions(
+			context.Background(), testXpubID, &bux.Metadata{"key": "value"},
+			map[string]interface{}{"direction": "incoming"},
+			&datastore.QueryParams{Page: 2, PageSize: 10},
+		)
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		assert.Equal(t, uint64(700000), transactions[0].BlockHeight)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, serverRouteTransactionSearch, req.URL.Path)
+		assert.Equal(t, map[string]interface{}{"direction": "incoming"}, body["conditions"])
+		assert.Equal(t, map[string]interface{}{"key": "value"}, body["metadata"])
+		assert.Equal(t, map[string]interface{}{"page": float64(2), "page_size": float64(10)}, body["params"])
+	})
+
+	t.Run("count", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, 42, &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		var count int64
+		count, err = backend.GetTransactionsCount(context.Background(), testXpubID, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), count)
+		assert.Equal(t, serverRouteTransactionCount, req.URL.Path)
+	})
+}
+
+func TestServerBackend_Paymails(t *testing.T) {
+	t.Parallel()
+
+	adminXpriv, adminXpub, err := bitcoin.GenerateHDKeyPair(bitcoin.SecureSeedLength)
+	require.NoError(t, err)
+
+	t.Run("creates the paymail using the admin key", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusCreated, &bux.PaymailAddress{Alias: "test", Domain: "domain.com"}, &req, &body)
+		backend, backendErr := newServerBackend(&ServerConfig{URL: server.URL, AdminKey: adminXpriv}, "test")
+		require.NoError(t, backendErr)
+
+		paymail, newErr := backend.NewPaymail(
+			context.Background(), testXpub, "test@domain.com", "Test", "https://domain.com/avatar.png", nil,
+		)
+		require.NoError(t, newErr)
+		assert.Equal(t, "test", paymail.Alias)
+		assert.Equal(t, serverRouteAdminPaymailCreate, req.URL.Path)
+		assert.Equal(t, adminXpub, req.Header.Get(bux.AuthHeader))
+		assert.Equal(t, testXpub, body["key"])
+		assert.Equal(t, "test@domain.com", body["address"])
+		assert.Equal(t, "Test", body["public_name"])
+		assert.Equal(t, "https://domain.com/avatar.png", body["avatar"])
+	})
+
+	t.Run("searches the paymails of the xpub", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusOK, []*bux.PaymailAddress{{Alias: "test"}}, &req, &body)
+		backend, backendErr := newServerBackend(&ServerConfig{URL: server.URL, AdminKey: adminXpriv}, "test")
+		require.NoError(t, backendErr)
+
+		paymails, searchErr := backend.GetPaymails(context.Background(), "xpub-id", nil, nil)
+		require.NoError(t, searchErr)
+		require.Len(t, paymails, 1)
+		assert.Equal(t, serverRouteAdminPaymailSearch, req.URL.Path)
+		assert.Equal(t, map[string]interface{}{"deleted_at": nil, "xpub_id": "xpub-id"}, body["conditions"])
+	})
+
+	t.Run("updating the metadata is not supported", func(t *testing.T) {
+		backend, backendErr := newServerBackend(&ServerConfig{URL: "http://localhost:3003/v1"}, "test")
+		require.NoError(t, backendErr)
+
+		_, updateErr := backend.UpdatePaymailMetadata(context.Background(), "test@domain.com", bux.Metadata{"key": "value"})
+		require.ErrorIs(t, updateErr, ErrNotSupportedInServerMode)
+	})
+}
+
+func TestServerBackend_AccessKeys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("creates the access key for the xpub", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusCreated, &bux.AccessKey{ID: "key-id", Key: "private-key"}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL}, "test")
+		require.NoError(t, err)
+
+		var accessKey *bux.AccessKey
+		accessKey, err = backend.NewAccessKey(context.Background(), testXpub, bux.Metadata{"app": "test"})
+		require.NoError(t, err)
+		assert.Equal(t, "private-key", accessKey.Key)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, serverRouteAccessKey, req.URL.Path)
+		assert.Equal(t, testXpub, req.Header.Get(bux.AuthHeader))
+		assert.Equal(t, map[string]interface{}{"app": "test"}, body["metadata"])
+	})
+
+	t.Run("searches the access keys with the conditions", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusOK, []*bux.AccessKey{{ID: "key-id"}}, &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		var accessKeys []*bux.AccessKey
+		accessKeys, err = backend.GetAccessKeys(
+			context.Background(), testXpubID, nil, map[string]interface{}{"revoked_at": nil},
+			&datastore.QueryParams{Page: 1, PageSize: 10},
+		)
+		require.NoError(t, err)
+		require.Len(t, accessKeys, 1)
+		assert.Equal(t, serverRouteAccessKeySearch, req.URL.Path)
+		assert.Equal(t, map[string]interface{}{"revoked_at": nil}, body["conditions"])
+	})
+
+	t.Run("revokes the access key", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusOK, &bux.AccessKey{ID: "key-id"}, &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.RevokeAccessKey(context.Background(), testXpub, "key-id")
+		require.NoError(t, err)
+		assert.Equal(t, http.MethodDelete, req.Method)
+		assert.Equal(t, serverRouteAccessKey, req.URL.Path)
+		assert.Equal(t, "key-id", req.URL.Query().Get("id"))
+	})
+
+	t.Run("missing access key", func(t *testing.T) {
+		var req *http.Request
+		server := newTestServer(t, http.StatusNotFound, "access key not found", &req, nil)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		_, err = backend.GetAccessKey(context.Background(), "", "key-id")
+		require.Error(t, err)
+		assert.Equal(t, exitCodeNotFound, exitCode(err))
+	})
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/mrz1836/go-whatsonchain"
+)
+
+// Default cache TTLs for the WhatsOnChain lookups (0 = no expiration)
+const (
+	defaultCacheAddressBalanceTTL         = 1 * time.Minute
+	defaultCacheAddressInfoTTL            = 5 * time.Minute
+	defaultCacheRawTransactionTTL         = 0
+	defaultCacheTransactionTTL            = 0
+	defaultCacheUnconfirmedTransactionTTL = 30 * time.Second
+)
+
+// Cache key prefixes for the WhatsOnChain lookups
+const (
+	cacheKeyAddressBalance = "woc-address-balance-"
+	cacheKeyAddressInfo    = "woc-address-info-"
+	cacheKeyRawTransaction = "woc-raw-tx-"
+	cacheKeyTransaction    = "woc-tx-"
+)
+
+// cacheStore is the local key/value store used for caching (database.DB)
+type cacheStore interface {
+	Get(key string) (string, error)
+	Set(key, value string, ttl time.Duration) error
+}
+
+// cachedWhatsOnChain is a WhatsOnChain client that caches lookups in the local database
+//
+// Only the lookups used by the commands are cached, all other methods use the embedded client
+type cachedWhatsOnChain struct {
+	whatsonchain.ClientInterface
+	config *CacheConfig
+	store  cacheStore
+}
+
+// newCachedWhatsOnChain will wrap the WhatsOnChain client with the local cache
+func newCachedWhatsOnChain(client whatsonchain.ClientInterface, store cacheStore,
+	config *CacheConfig) *cachedWhatsOnChain {
+	if config == nil {
+		config = new(CacheConfig)
+	}
+	return &cachedWhatsOnChain{ClientInterface: client, config: config, store: store}
+}
+
+// ttl will return the configured TTL, the default if not set, or false if caching is disabled (negative)
+func (c *cachedWhatsOnChain) ttl(configured, defaultTTL time.Duration) (time.Duration, bool) {
+	if configured < 0 {
+		return 0, false
+	} else if configured == 0 {
+		return defaultTTL, true
+	}
+	return configured, true
+}
+
+// get will get the value from the cache, returns false if not found
+func (c *cachedWhatsOnChain) get(key string) (string, bool) {
+	value, err := c.store.Get(key)
+	if err != nil {
+		verboseLog(func() {
+			chalker.Log(chalker.WARN, fmt.Sprintf("cache error for %s: %s", key, err.Error()))
+		})
+		return "", false
+	} else if len(value) == 0 {
+		verboseLog(func() {
+			chalker.Log(chalker.INFO, "cache miss: "+key)
+		})
+		return "", false
+	}
+	verboseLog(func() {
+		chalker.Log(chalker.INFO, "cache hit: "+key)
+	})
+	return value, true
+}
+
+// set will store the value in the cache (errors are only logged, the cache is optional)
+func (c *cachedWhatsOnChain) set(key, value string, ttl time.Duration) {
+	if err := c.store.Set(key, value, ttl); err != nil {
+		verboseLog(func() {
+			chalker.Log(chalker.WARN, fmt.Sprintf("cache error for %s: %s", key, err.Error()))
+		})
+	}
+}
+
+// getModel will get a JSON model from the cache, returns false if not found
+func (c *cachedWhatsOnChain) getModel(key string, model interface{}) bool {
+	value, found := c.get(key)
+	if !found {
+		return false
+	}
+	return json.Unmarshal([]byte(value), model) == nil
+}
+
+// setModel will store a JSON model in the cache
+func (c *cachedWhatsOnChain) setModel(key string, model interface{}, ttl time.Duration) {
+	if b, err := json.Marshal(model); err == nil {
+		c.set(key, string(b), ttl)
+	}
+}
+
+// AddressBalance will get the address balance (cached)
+func (c *cachedWhatsOnChain) AddressBalance(ctx context.Context,
+	address string) (balance *whatsonchain.AddressBalance, err error) {
+	ttl, enabled := c.ttl(c.config.AddressBalanceTTL, defaultCacheAddressBalanceTTL)
+	if !enabled {
+		return c.ClientInterface.AddressBalance(ctx, address)
+	}
+	if c.getModel(cacheKeyAddressBalance+address, &balance) {
+		return
+	}
+	if balance, err = c.ClientInterface.AddressBalance(ctx, address); err == nil && balance != nil {
+		c.setModel(cacheKeyAddressBalance+address, balance, ttl)
+	}
+	return
+}
+
+// AddressInfo will get the address info (cached)
+func (c *cachedWhatsOnChain) AddressInfo(ctx context.Context,
+	address string) (info *whatsonchain.AddressInfo, err error) {
+	ttl, enabled := c.ttl(c.config.AddressInfoTTL, defaultCacheAddressInfoTTL)
+	if !enabled {
+		return c.ClientInterface.AddressInfo(ctx, address)
+	}
+	if c.getModel(cacheKeyAddressInfo+address, &info) {
+		return
+	}
+	if info, err = c.ClientInterface.AddressInfo(ctx, address); err == nil && info != nil {
+		c.setModel(cacheKeyAddressInfo+address, info, ttl)
+	}
+	return
+}
+
+// GetRawTransactionData will get the raw transaction hex (cached, the hex never changes)
+func (c *cachedWhatsOnChain) GetRawTransactionData(ctx context.Context, hash string) (txHex string, err error) {
+	ttl, enabled := c.ttl(c.config.RawTransactionTTL, defaultCacheRawTransactionTTL)
+	if !enabled {
+		return c.ClientInterface.GetRawTransactionData(ctx, hash)
+	}
+	var found bool
+	if txHex, found = c.get(cacheKeyRawTransaction + hash); found {
+		return
+	}
+	if txHex, err = c.ClientInterface.GetRawTransactionData(ctx, hash); err == nil && len(txHex) > 0 {
+		c.set(cacheKeyRawTransaction+hash, txHex, ttl)
+	}
+	return
+}
+
+// GetTxByHash will get the transaction info (cached, unconfirmed transactions use a shorter TTL)
+func (c *cachedWhatsOnChain) GetTxByHash(ctx context.Context, hash string) (txInfo *whatsonchain.TxInfo, err error) {
+	confirmedTTL, confirmedEnabled := c.ttl(c.config.TransactionTTL, defaultCacheTransactionTTL)
+	unconfirmedTTL, unconfirmedEnabled := c.ttl(
+		c.config.UnconfirmedTransactionTTL, defaultCacheUnconfirmedTransactionTTL,
+	)
+	if !confirmedEnabled && !unconfirmedEnabled {
+		return c.ClientInterface.GetTxByHash(ctx, hash)
+	}
+	if c.getModel(cacheKeyTransaction+hash, &txInfo) {
+		return
+	}
+	if txInfo, err = c.ClientInterface.GetTxByHash(ctx, hash); err != nil || txInfo == nil {
+		return
+	}
+
+	// Confirmed transactions do not change (cached forever by default)
+	if txInfo.Confirmations > 0 && confirmedEnabled {
+		c.setModel(cacheKeyTransaction+hash, txInfo, confirmedTTL)
+	} else if txInfo.Confirmations <= 0 && unconfirmedEnabled {
+		c.setModel(cacheKeyTransaction+hash, txInfo, unconfirmedTTL)
+	}
+	return
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mrz1836/go-whatsonchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory cache store for testing
+type memoryStore struct {
+	values map[string]string
+	ttls   map[string]time.Duration
+}
+
+// newMemoryStore will return a new in-memory cache store
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: map[string]string{}, ttls: map[string]time.Duration{}}
+}
+
+// Get will get a value
+func (m *memoryStore) Get(key string) (string, error) {
+	return m.values[key], nil
+}
+
+// Set will set a value
+func (m *memoryStore) Set(key, value string, ttl time.Duration) error {
+	m.values[key] = value
+	m.ttls[key] = ttl
+	return nil
+}
+
+// wocStub is a WhatsOnChain client stub that counts the lookups
+type wocStub struct {
+	whatsonchain.ClientInterface
+	calls         int
+	confirmations int64
+}
+
+// AddressBalance returns a fixed balance
+func (w *wocStub) AddressBalance(_ context.Context, _ string) (*whatsonchain.AddressBalance, error) {
+	w.calls++
+	return &whatsonchain.AddressBalance{Confirmed: 1000, Unconfirmed: 50}, nil
+}
+
+// GetRawTransactionData returns a fixed hex
+func (w *wocStub) GetRawTransactionData(_ context.Context, _ string) (string, error) {
+	w.calls++
+	return "0100", nil
+}
+
+// GetTxByHash returns a fixed transaction
+func (w *wocStub) GetTxByHash(_ context.Context, hash string) (*whatsonchain.TxInfo, error) {
+	w.calls++
+	return &whatsonchain.TxInfo{TxID: hash, Confirmations: w.confirmations}, nil
+}
+
+func TestCachedWhatsOnChain_AddressBalance(t *testing.T) {
+	t.Parallel()
+
+	t.Run("second lookup is a cache hit", func(t *testing.T) {
+		stub, store := new(wocStub), newMemoryStore()
+		client := newCachedWhatsOnChain(stub, store, nil)
+
+		balance, err := client.AddressBalance(context.Background(), "1address")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), balance.Confirmed)
+
+		balance, err = client.AddressBalance(context.Background(), "1address")
+		require.NoError(t, err)
+		assert.Equal(t, int64(50), balance.Unconfirmed)
+		assert.Equal(t, 1, stub.calls)
+		assert.Equal(t, defaultCacheAddressBalanceTTL, store.ttls[cacheKeyAddressBalance+"1address"])
+	})
+
+	t.Run("negative ttl disables the cache", func(t *testing.T) {
+		stub, store := new(wocStub), newMemoryStore()
+		client := newCachedWhatsOnChain(stub, store, &CacheConfig{AddressBalanceTTL: -1})
+
+		_, err := client.AddressBalance(context.Background(), "1address")
+		require.NoError(t, err)
+		_, err = client.AddressBalance(context.Background(), "1address")
+		require.NoError(t, err)
+		assert.Equal(t, 2, stub.calls)
+		assert.Empty(t, store.values)
+	})
+}
+
+func TestCachedWhatsOnChain_GetRawTransactionData(t *testing.T) {
+	t.Parallel()
+
+	t.Run("raw hex never expires by default", func(t *testing.T) {
+		stub, store := new(wocStub), newMemoryStore()
+		client := newCachedWhatsOnChain(stub, store, nil)
+
+		txHex, err := client.GetRawTransactionData(context.Background(), "txid")
+		require.NoError(t, err)
+		assert.Equal(t, "0100", txHex)
+
+		txHex, err = client.GetRawTransactionData(context.Background(), "txid")
+		require.NoError(t, err)
+		assert.Equal(t, "0100", txHex)
+		assert.Equal(t, 1, stub.calls)
+		assert.Equal(t, time.Duration(0), store.ttls[cacheKeyRawTransaction+"txid"])
+	})
+}
+
+func TestCachedWhatsOnChain_GetTxByHash(t *testing.T) {
+	t.Parallel()
+
+	t.Run("confirmed transaction is cached forever", func(t *testing.T) {
+		stub, store := &wocStub{confirmations: 6}, newMemoryStore()
+		client := newCachedWhatsOnChain(stub, store, nil)
+
+		_, err := client.GetTxByHash(context.Background(), "txid")
+		require.NoError(t, err)
+
+		var txInfo *whatsonchain.TxInfo
+		txInfo, err = client.GetTxByHash(context.Background(), "txid")
+		require.NoError(t, err)
+		assert.Equal(t, "txid", txInfo.TxID)
+		assert.Equal(t, 1, stub.calls)
+		assert.Equal(t, time.Duration(0), store.ttls[cacheKeyTransaction+"txid"])
+	})
+
+	t.Run("unconfirmed transaction uses a short ttl", func(t *testing.T) {
+		stub, store := new(wocStub), newMemoryStore()
+		client := newCachedWhatsOnChain(stub, store, &CacheConfig{UnconfirmedTransactionTTL: 10 * time.Second})
+
+		_, err := client.GetTxByHash(context.Background(), "txid")
+		require.NoError(t, err)
+		assert.Equal(t, 10*time.Second, store.ttls[cacheKeyTransaction+"txid"])
+	})
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/BuxOrg/bux/chainstate"
+	"github.com/tonicpow/go-minercraft"
+)
+
+// minerAPITypeMAPI is the API type for the miners using the Merchant API (the only type supported by minercraft)
+const minerAPITypeMAPI = "mapi"
+
+// chainstateProviders are the providers that can be excluded
+var chainstateProviders = []string{chainstate.ProviderMAPI, chainstate.ProviderNowNodes, chainstate.ProviderWhatsOnChain}
+
+// newMiners will return the known miners (minercraft defaults) and the custom miners, with the tokens from the config
+//
+// Custom miners replace a known miner with the same name, the names are not case-sensitive
+func newMiners(config *ChainstateConfig) ([]*minercraft.Miner, error) {
+	miners, err := minercraft.DefaultMiners()
+	if err != nil {
+		return nil, fmt.Errorf("error loading the default miners: %w", err)
+	}
+
+	// Add the custom miners
+	for index, custom := range config.Miners {
+		if custom == nil || len(custom.Name) == 0 {
+			return nil, fmt.Errorf("%w: chainstate.miners[%d].name is required", ErrInvalidConfig, index)
+		} else if _, err = url.ParseRequestURI(custom.URL); err != nil || !strings.HasPrefix(custom.URL, "http") {
+			return nil, fmt.Errorf("%w: chainstate.miners[%d].url is not a valid url: %s", ErrInvalidConfig, index, custom.URL)
+		} else if len(custom.MinerID) == 0 {
+			return nil, fmt.Errorf("%w: chainstate.miners[%d].miner_id is required", ErrInvalidConfig, index)
+		} else if len(custom.APIType) > 0 && !strings.EqualFold(custom.APIType, minerAPITypeMAPI) {
+			return nil, fmt.Errorf("%w: chainstate.miners[%d].api_type %s is not supported (use: %s)",
+				ErrInvalidConfig, index, custom.APIType, minerAPITypeMAPI)
+		}
+		miner := &minercraft.Miner{
+			MinerID: custom.MinerID,
+			Name:    custom.Name,
+			Token:   custom.Token,
+			URL:     strings.TrimSuffix(custom.URL, "/"),
+		}
+		if existing := minerByName(miners, custom.Name); existing != nil {
+			*existing = *miner
+		} else {
+			miners = append(miners, miner)
+		}
+	}
+
+	// Set the tokens (the TAAL API key is the token for Taal)
+	tokens := make(map[string]string, len(config.MinerTokens)+1)
+	if len(config.TaalAPIKey) > 0 {
+		tokens[minercraft.MinerTaal] = config.TaalAPIKey
+	}
+	for name, token := range config.MinerTokens {
+		tokens[name] = token
+	}
+	for name, token := range tokens {
+		miner := minerByName(miners, name)
+		if miner == nil {
+			return nil, fmt.Errorf("%w: chainstate.miner_tokens has an unknown miner: %s", ErrInvalidConfig, name)
+		} else if len(token) > 0 {
+			miner.Token = token
+		}
+	}
+	return miners, nil
+}
+
+// minerByName will return the miner by name (not case-sensitive, the config keys are lowercase)
+func minerByName(miners []*minercraft.Miner, name string) *minercraft.Miner {
+	for index := range miners {
+		if strings.EqualFold(miners[index].Name, name) {
+			return miners[index]
+		}
+	}
+	return nil
+}
+
+// selectMiners will return the miners by name, unknown miners are an error (or a warning if lenient)
+func selectMiners(names []string, miners []*minercraft.Miner, lenient bool) ([]*minercraft.Miner, error) {
+	selected := make([]*minercraft.Miner, 0, len(names))
+	for _, name := range names {
+		miner := minerByName(miners, name)
+		if miner == nil && !lenient {
+			return nil, fmt.Errorf("%w: %s (use: %s, or add it to chainstate.miners)",
+				ErrUnknownMiner, name, strings.Join(minerNames(miners), ", "))
+		} else if miner == nil {
+			chalker.LogTo(os.Stderr, chalker.WARN, fmt.Sprintf("%s: %s (ignored)", ErrUnknownMiner.Error(), name))
+			continue
+		}
+		selected = append(selected, miner)
+	}
+	return selected, nil
+}
+
+// minerNames will return the names of the miners
+func minerNames(miners []*minercraft.Miner) []string {
+	names := make([]string, 0, len(miners))
+	for _, miner := range miners {
+		names = append(names, miner.Name)
+	}
+	return names
+}
+
+// defaultMinerNames will return the miners that BUX uses by default (all known miners for broadcasting,
+// only the miners that support querying for querying)
+func defaultMinerNames(isBroadcast bool) []string {
+	if !isBroadcast {
+		return []string{minercraft.MinerTaal, minercraft.MinerMempool}
+	}
+	miners, _ := minercraft.DefaultMiners()
+	return minerNames(miners)
+}
+
+// excludedProviders will return the excluded chainstate providers (NowNodes is excluded without an API key)
+func excludedProviders(config *ChainstateConfig) ([]string, error) {
+	excluded := make([]string, 0, len(config.ExcludedProviders)+1)
+	for _, provider := range config.ExcludedProviders {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		known := false
+		for _, name := range chainstateProviders {
+			known = known || provider == name
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: chainstate.excluded_providers has an unknown provider: %s (use: %s)",
+				ErrInvalidConfig, provider, strings.Join(chainstateProviders, ", "))
+		}
+		excluded = append(excluded, provider)
+	}
+
+	// NowNodes needs an API key
+	if len(config.NowNodesAPIKey) == 0 {
+		found := false
+		for _, provider := range excluded {
+			found = found || provider == chainstate.ProviderNowNodes
+		}
+		if !found {
+			excluded = append(excluded, chainstate.ProviderNowNodes)
+		}
+	}
+	return excluded, nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/BuxOrg/bux/chainstate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tonicpow/go-minercraft"
+)
+
+func TestNewMiners(t *testing.T) {
+	t.Parallel()
+
+	t.Run("default miners", func(t *testing.T) {
+		miners, err := newMiners(&ChainstateConfig{})
+		require.NoError(t, err)
+		defaults, _ := minercraft.DefaultMiners()
+		assert.Equal(t, defaults, miners)
+	})
+
+	t.Run("tokens", func(t *testing.T) {
+		miners, err := newMiners(&ChainstateConfig{
+			MinerTokens: map[string]string{"gorillapool": "gorilla-token", "matterpool": ""},
+			TaalAPIKey:  "taal-token",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "taal-token", minerByName(miners, minercraft.MinerTaal).Token)
+		assert.Equal(t, "gorilla-token", minerByName(miners, minercraft.MinerGorillaPool).Token)
+		assert.Empty(t, minerByName(miners, minercraft.MinerMatterpool).Token)
+
+		// The miner tokens replace the TAAL API key
+		miners, err = newMiners(&ChainstateConfig{
+			MinerTokens: map[string]string{"Taal": "new-token"},
+			TaalAPIKey:  "taal-token",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "new-token", minerByName(miners, minercraft.MinerTaal).Token)
+	})
+
+	t.Run("custom miners", func(t *testing.T) {
+		miners, err := newMiners(&ChainstateConfig{
+			MinerTokens: map[string]string{"local": "local-token"},
+			Miners: []*MinerConfig{
+				{APIType: "MAPI", MinerID: "local-id", Name: "Local", URL: "http://localhost:8080/"},
+				{MinerID: "taal-id", Name: "taal", URL: "https://mapi.example.com"},
+			},
+		})
+		require.NoError(t, err)
+		defaults, _ := minercraft.DefaultMiners()
+		require.Len(t, miners, len(defaults)+1)
+
+		local := minerByName(miners, "local")
+		require.NotNil(t, local)
+		assert.Equal(t, "http://localhost:8080", local.URL)
+		assert.Equal(t, "local-id", local.MinerID)
+		assert.Equal(t, "local-token", local.Token)
+
+		taal := minerByName(miners, minercraft.MinerTaal)
+		assert.Equal(t, "https://mapi.example.com", taal.URL)
+		assert.Equal(t, "taal-id", taal.MinerID)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		for name, config := range map[string]*ChainstateConfig{
+			"unknown token": {MinerTokens: map[string]string{"unknown": "token"}},
+			"missing name":  {Miners: []*MinerConfig{{MinerID: "id", URL: "http://localhost"}}},
+			"invalid url":   {Miners: []*MinerConfig{{MinerID: "id", Name: "local", URL: "localhost"}}},
+			"missing id":    {Miners: []*MinerConfig{{Name: "local", URL: "http://localhost"}}},
+			"api type": {Miners: []*MinerConfig{
+				{APIType: "arc", MinerID: "id", Name: "local", URL: "http://localhost"},
+			}},
+		} {
+			_, err := newMiners(config)
+			require.ErrorIs(t, err, ErrInvalidConfig, name)
+		}
+	})
+}
+
+func TestExcludedProviders(t *testing.T) {
+	t.Parallel()
+
+	excluded, err := excludedProviders(&ChainstateConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{chainstate.ProviderNowNodes}, excluded)
+
+	excluded, err = excludedProviders(&ChainstateConfig{ExcludedProviders: []string{"WhatsOnChain"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{chainstate.ProviderWhatsOnChain, chainstate.ProviderNowNodes}, excluded)
+
+	excluded, err = excludedProviders(&ChainstateConfig{NowNodesAPIKey: "key"})
+	require.NoError(t, err)
+	assert.Empty(t, excluded)
+
+	excluded, err = excludedProviders(&ChainstateConfig{
+		ExcludedProviders: []string{chainstate.ProviderNowNodes}, NowNodesAPIKey: "key",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{chainstate.ProviderNowNodes}, excluded)
+
+	_, err = excludedProviders(&ChainstateConfig{ExcludedProviders: []string{"unknown"}})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestDefaultMinerNames(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{minercraft.MinerTaal, minercraft.MinerMempool}, defaultMinerNames(false))
+	assert.Contains(t, defaultMinerNames(true), minercraft.MinerGorillaPool)
+}
@@ -0,0 +1,683 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed" // for the default config
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BuxOrg/bux/taskmanager"
+	"github.com/fatih/color"
+	"github.com/mrz1836/go-cachestore"
+	"github.com/mrz1836/go-datastore"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// commands for config
+const configCommandName = "config"
+const configCommandList = "list"
+const configCommandUse = "use"
+const configCommandShow = "show"
+const configCommandValidate = "validate"
+const configCommandInit = "init"
+
+// defaultConfig is the default configuration (used for new profiles, see: config init)
+//
+//go:embed config-example.json
+var defaultConfig []byte
+
+// Configuration profiles (stored in the application directory)
+const (
+	configProfileDefault    = "default"        // Default profile (config.json)
+	configProfileActiveFile = "active_profile" // File with the name of the active profile (see: config use)
+	configProfilesFolder    = "profiles"       // Folder for the named profiles (profiles/<name>.json)
+	configRedactedValue     = "********"       // Replaces the secrets when showing the config
+)
+
+// profileEnv is the environment variable for the profile (instead of the --profile flag)
+var profileEnv = strings.ToUpper(applicationName) + "_PROFILE"
+
+// profileNameRegex is the allowed format for the profile names
+var profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// configSecretKeys are the (partial) config keys that hold secrets (redacted by config show)
+var configSecretKeys = []string{"_key", "password", "secret", "token", "xpriv"}
+
+// returnConfigCmd returns the config command
+func returnConfigCmd(app *App) (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
+		Use:   configCommandName,
+		Short: "manage the configuration profiles",
+		Long: color.GreenString(`
+_________   ________     _______    ___________ .___    ________
+\_   ___ \  \_____  \    \      \   \_   _____/ |   |  /  _____/
+/    \  \/   /   |   \   /   |   \   |    __)   |   | /   \  ___
+\     \____ /    |    \ /    |    \  |     \    |   | \    \_\  \
+ \______  / \_______  / \____|__  /  \___  /    |___|  \______  /
+        \/          \/          \/       \/                   \/`) + `
+` + color.YellowString(`
+This command is for the configuration profiles (IE: a local SQLite wallet, a staging database and a production server).
+
+The default profile is `+configFileDefault+`.json, named profiles are stored in the `+configProfilesFolder+` folder (`+configProfilesFolder+`/<name>.json).
+The profile is selected with --`+flagProfile+`, the `+profileEnv+` environment variable, or the active profile (`+configCommandName+` `+configCommandUse+`).
+
+init: creates a new profile, prompts for the values or uses the flags (`+configCommandName+` `+configCommandInit+` [profile])
+list: lists the profiles (`+configCommandName+` `+configCommandList+`)
+use: sets the active profile (`+configCommandName+` `+configCommandUse+` <profile>)
+show: shows the profile configuration, secrets are redacted (`+configCommandName+` `+configCommandShow+` [profile])
+validate: validates the profile configuration (`+configCommandName+` `+configCommandValidate+` [profile])
+`),
+		Example: applicationName + " " + configCommandName + " " + configCommandUse + " staging",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("%s %w, IE: %s, etc.", configCommandName, ErrSubcommandIsRequired, configCommandInit+", "+
+					configCommandList+", "+configCommandUse+", "+configCommandShow+", "+configCommandValidate)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// The profile from the args, or the selected profile
+			name := activeProfileName(app.applicationDirectory)
+			if len(args) > 1 {
+				name = args[1]
+			}
+
+			// Switch on the subcommand
+			if args[0] == configCommandInit { // Create a new profile
+
+				// New profiles are created from the flags (the default profile unless a profile is provided)
+				name = configProfileDefault
+				if len(args) > 1 {
+					name = args[1]
+				}
+				path, err := profilePath(app.applicationDirectory, name)
+				if err != nil {
+					return err
+				}
+				options := &configInitOptions{
+					cachestore:  configCachestore,
+					datastore:   configDatastore,
+					mode:        configMode,
+					mongoURI:    configMongoURI,
+					redisURL:    configRedisURL,
+					serverURL:   configServerURL,
+					sqlHost:     configSQLHost,
+					taskManager: configTaskManager,
+				}
+
+				// Prompt for the values that were not set using the flags
+				if !configNonInteractive && term.IsTerminal(int(os.Stdin.Fd())) {
+					if err = promptConfigInitOptions(
+						options, cmd.Flags().Changed, bufio.NewReader(os.Stdin), os.Stderr,
+					); err != nil {
+						return err
+					}
+				}
+
+				// Create the config (validated) and save the profile
+				var content []byte
+				if content, err = newProfileConfig(options); err != nil {
+					return err
+				} else if err = writeConfigFile(path, content, configForce); err != nil {
+					return err
+				}
+
+				// Display the new profile
+				return displayModel(&ConfigProfile{
+					Active: name == activeProfileName(app.applicationDirectory),
+					Name:   name,
+					Path:   path,
+				})
+			} else if args[0] == configCommandList { // List the profiles
+
+				profiles, err := listProfiles(app.applicationDirectory, activeProfileName(app.applicationDirectory))
+				if err != nil {
+					return err
+				}
+				return displayModel(profiles)
+			} else if args[0] == configCommandUse { // Set the active profile
+
+				// Check if the profile is provided
+				if len(args) < 2 {
+					return ErrProfileIsRequired
+				}
+
+				// Set the active profile (the profile must exist)
+				path, err := profileConfigPath(app.applicationDirectory, name)
+				if err != nil {
+					return err
+				} else if err = setActiveProfile(app.applicationDirectory, name); err != nil {
+					return err
+				}
+
+				// Display the active profile
+				return displayModel(&ConfigProfile{Active: true, Name: name, Path: path})
+			} else if args[0] == configCommandShow { // Show the profile configuration (redacted)
+
+				// Load the profile
+				v, path, err := loadProfile(app.applicationDirectory, name, len(args) < 2)
+				if err != nil {
+					return err
+				}
+
+				// Display the redacted settings
+				return displayModel(&ConfigProfileSettings{
+					Name:     name,
+					Path:     path,
+					Settings: redactSettings(v.AllSettings()),
+				})
+			} else if args[0] == configCommandValidate { // Validate the profile configuration
+
+				// Load the profile
+				v, path, err := loadProfile(app.applicationDirectory, name, len(args) < 2)
+				if err != nil {
+					return err
+				}
+
+				// Validate the config
+				validation := &ConfigValidation{Errors: []string{}, Name: name, Path: path, Valid: true}
+				config := new(Config)
+				if err = v.Unmarshal(config); err != nil {
+					validation.Errors = append(validation.Errors, err.Error())
+				}
+				for _, problem := range validateConfig(config) {
+					validation.Errors = append(validation.Errors, problem.Error())
+				}
+				validation.Valid = len(validation.Errors) == 0
+
+				// Display the result
+				if err = displayModel(validation); err != nil {
+					return err
+				} else if !validation.Valid {
+					return fmt.Errorf("%w: %d problem(s) in profile %s", ErrInvalidConfig, len(validation.Errors), name)
+				}
+				return nil
+			}
+
+			return ErrUnknownSubcommand
+		},
+	}
+
+	// Flags for the new profile (config init)
+	newCmd.Flags().StringVar(&configMode, flagMode, modeDatabase, "Mode: "+modeDatabase+" or "+modeServer)
+	newCmd.Flags().StringVar(&configDatastore, flagDatastore, datastore.SQLite.String(),
+		"Datastore engine: "+strings.Join(configDatastoreEngines, ", "))
+	newCmd.Flags().StringVar(&configCachestore, flagCachestore, cachestore.FreeCache.String(),
+		"Cachestore engine: "+strings.Join(configCachestoreEngines, ", "))
+	newCmd.Flags().StringVar(&configTaskManager, flagTaskManager, taskmanager.FactoryMemory.String(),
+		"Task manager factory: "+strings.Join(configTaskManagerFactories, ", "))
+	newCmd.Flags().StringVar(&configServerURL, flagServerURL, "", "BUX server url (server mode)")
+	newCmd.Flags().StringVar(&configSQLHost, flagSQLHost, "", "SQL host (mysql and postgresql)")
+	newCmd.Flags().StringVar(&configMongoURI, flagMongoURI, "", "MongoDB uri (mongodb)")
+	newCmd.Flags().StringVar(&configRedisURL, flagRedisURL, "", "Redis url (redis cachestore or task manager)")
+	newCmd.Flags().BoolVar(&configForce, flagForce, false, "Overwrite the profile if it exists")
+	newCmd.Flags().BoolVar(&configNonInteractive, flagNonInteractive, false, "Do not prompt, use the flags")
+	return
+}
+
+// preParseConfigFlags will parse the --config and --profile flags before the config is loaded
+//
+// The config is loaded before cobra parses the flags, all the other flags and arguments are ignored
+func preParseConfigFlags(args []string) {
+	flags := pflag.NewFlagSet(applicationName, pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.Usage = func() {}
+	flags.SetOutput(new(strings.Builder))
+	flags.StringVar(&configFile, flagConfig, configFile, "")
+	flags.StringVar(&profileName, flagProfile, profileName, "")
+	_ = flags.Parse(args)
+}
+
+// activeProfileName will return the selected profile: --profile, the environment, the active profile or the default
+func activeProfileName(dir string) string {
+	if len(profileName) > 0 {
+		return profileName
+	} else if name := os.Getenv(profileEnv); len(name) > 0 {
+		return name
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, configProfileActiveFile)); err == nil { //nolint:gosec // application directory
+		if name := strings.TrimSpace(string(data)); len(name) > 0 {
+			return name
+		}
+	}
+	return configProfileDefault
+}
+
+// setActiveProfile will store the active profile (used when --profile and the environment are not set)
+func setActiveProfile(dir, name string) error {
+	if err := os.WriteFile(filepath.Join(dir, configProfileActiveFile), []byte(name+"\n"), 0o600); err != nil {
+		return fmt.Errorf("error setting the active profile: %w", err)
+	}
+	return nil
+}
+
+// profilePath will return the config file for the profile (default is config.json, the others are in profiles/)
+func profilePath(dir, name string) (string, error) {
+	if !profileNameRegex.MatchString(name) {
+		return "", fmt.Errorf("%w: %s (use letters, numbers, dash, dot or underscore)", ErrInvalidProfileName, name)
+	} else if name == configProfileDefault {
+		return filepath.Join(dir, configFileDefault+".json"), nil
+	}
+	return filepath.Join(dir, configProfilesFolder, name+".json"), nil
+}
+
+// profileConfigPath will return the config file for the profile (the file must exist)
+func profileConfigPath(dir, name string) (string, error) {
+	path, err := profilePath(dir, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s (%s)", ErrProfileNotFound, name, path)
+	} else if err != nil {
+		return "", fmt.Errorf("error reading profile %s: %w", name, err)
+	}
+	return path, nil
+}
+
+// listProfiles will return the default profile and the named profiles (sorted by name)
+func listProfiles(dir, active string) ([]*ConfigProfile, error) {
+	profiles := make([]*ConfigProfile, 0)
+	if path, err := profileConfigPath(dir, configProfileDefault); err == nil {
+		profiles = append(profiles, &ConfigProfile{Active: active == configProfileDefault,
+			Name: configProfileDefault, Path: path})
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, configProfilesFolder, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing profiles: %w", err)
+	}
+	sort.Strings(files)
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if name == configProfileDefault || !profileNameRegex.MatchString(name) {
+			continue
+		}
+		profiles = append(profiles, &ConfigProfile{Active: active == name, Name: name, Path: path})
+	}
+	return profiles, nil
+}
+
+// writeConfigFile will write the config file (only readable by the user, it can hold secrets)
+func writeConfigFile(path string, content []byte, overwrite bool) error {
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return fmt.Errorf("%w: %s (use --%s to overwrite)", ErrProfileAlreadyExists, path, flagForce)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("error creating config folder: %w", err)
+	} else if err = os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	return nil
+}
+
+// loadProfile will read the profile config (with the environment overrides) into a new viper instance
+//
+// The custom config file (--config) is used instead of the profile if it is set and the profile is the selected one
+func loadProfile(dir, name string, selected bool) (*viper.Viper, string, error) {
+	path := configFile
+	if len(path) == 0 || !selected {
+		var err error
+		if path, err = profileConfigPath(dir, name); err != nil {
+			return nil, "", err
+		}
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
+	v.SetEnvPrefix(applicationName)
+	v.AutomaticEnv()
+	if err := v.ReadInConfig(); err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrFailedToReadConfig, err.Error())
+	}
+	return v, path, nil
+}
+
+// configInitOptions are the values for a new profile (config init)
+type configInitOptions struct {
+	cachestore  string
+	datastore   string
+	mode        string
+	mongoURI    string
+	redisURL    string
+	serverURL   string
+	sqlHost     string
+	taskManager string
+}
+
+// Supported values for a new profile (config init)
+var (
+	configCachestoreEngines    = []string{cachestore.FreeCache.String(), cachestore.Redis.String()}
+	configDatastoreEngines     = []string{datastore.SQLite.String(), datastore.PostgreSQL.String(), datastore.MySQL.String(), datastore.MongoDB.String()}
+	configModes                = []string{modeDatabase, modeServer}
+	configTaskManagerFactories = []string{taskmanager.FactoryMemory.String(), taskmanager.FactoryRedis.String()}
+)
+
+// promptConfigInitOptions will prompt for the options that were not set using the flags (changed)
+func promptConfigInitOptions(options *configInitOptions, changed func(string) bool, reader *bufio.Reader,
+	out io.Writer) (err error) {
+
+	// prompt will ask for the value unless the flag was set (an empty answer keeps the current value)
+	prompt := func(value *string, flag, label string, choices []string) error {
+		if changed(flag) {
+			return nil
+		}
+		*value, err = promptValue(reader, out, label, *value, choices)
+		return err
+	}
+
+	// Server mode only needs the server url
+	if err = prompt(&options.mode, flagMode, "Mode", configModes); err != nil {
+		return err
+	} else if options.mode == modeServer {
+		return prompt(&options.serverURL, flagServerURL, "BUX server url", nil)
+	}
+
+	// Database mode: datastore, cachestore, task manager and the connections
+	if err = prompt(&options.datastore, flagDatastore, "Datastore engine", configDatastoreEngines); err != nil {
+		return err
+	} else if err = prompt(&options.cachestore, flagCachestore, "Cachestore engine",
+		configCachestoreEngines); err != nil {
+		return err
+	} else if err = prompt(&options.taskManager, flagTaskManager, "Task manager factory",
+		configTaskManagerFactories); err != nil {
+		return err
+	}
+	switch options.datastore {
+	case datastore.PostgreSQL.String(), datastore.MySQL.String():
+		err = prompt(&options.sqlHost, flagSQLHost, "SQL host", nil)
+	case datastore.MongoDB.String():
+		err = prompt(&options.mongoURI, flagMongoURI, "MongoDB uri", nil)
+	}
+	if err == nil && (options.cachestore == cachestore.Redis.String() ||
+		options.taskManager == taskmanager.FactoryRedis.String()) {
+		err = prompt(&options.redisURL, flagRedisURL, "Redis url", nil)
+	}
+	return err
+}
+
+// promptValue will prompt for a value (one of the choices if set), an empty answer returns the current value
+func promptValue(reader *bufio.Reader, out io.Writer, label, value string, choices []string) (string, error) {
+	for {
+		if len(choices) > 0 {
+			_, _ = fmt.Fprintf(out, "%s (%s) [%s]: ", label, strings.Join(choices, ", "), value)
+		} else {
+			_, _ = fmt.Fprintf(out, "%s [%s]: ", label, value)
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil && len(line) == 0 {
+			return "", fmt.Errorf("error reading input: %w", err)
+		}
+		answer := strings.TrimSpace(line)
+		if len(answer) == 0 {
+			answer = value
+		}
+		if len(choices) == 0 {
+			return answer, nil
+		}
+		for _, choice := range choices {
+			if strings.EqualFold(answer, choice) {
+				return choice, nil
+			}
+		}
+		_, _ = fmt.Fprintf(out, "invalid value: %s\n", answer)
+	}
+}
+
+// newProfileConfig will create the config for a new profile from the default config and the options
+//
+// The config is validated, a profile that cannot be loaded is not created
+func newProfileConfig(options *configInitOptions) ([]byte, error) {
+	settings := make(map[string]map[string]interface{})
+	var root map[string]interface{}
+	if err := json.Unmarshal(defaultConfig, &root); err != nil {
+		return nil, fmt.Errorf("error reading default config: %w", err)
+	}
+	for key, value := range root {
+		if section, ok := value.(map[string]interface{}); ok {
+			settings[key] = section
+		}
+	}
+
+	// set will set the value in the section (empty values keep the default)
+	set := func(section, key, value string) {
+		if len(value) > 0 {
+			settings[section][key] = value
+		}
+	}
+	root["mode"] = options.mode
+	set("cachestore", "engine", options.cachestore)
+	set("datastore", "engine", options.datastore)
+	set("mongodb", "uri", options.mongoURI)
+	set("redis", "url", options.redisURL)
+	set("server", "url", options.serverURL)
+	set("sql", "host", options.sqlHost)
+	set("task_manager", "factory", options.taskManager)
+
+	content, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error creating config: %w", err)
+	}
+
+	// Validate the new config
+	v := viper.New()
+	v.SetConfigType("json")
+	config := new(Config)
+	if err = v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToReadConfig, err.Error())
+	} else if err = v.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToReadConfig, err.Error())
+	} else if err = configProblemsError(validateConfig(config)); err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+// validateConfig will check the config for the selected mode and return the problems found
+//
+// The problems name the config key (IE: redis.url) so the config can be fixed before BUX is loaded
+func validateConfig(config *Config) (problems []error) {
+	switch config.Mode {
+	case "":
+		return []error{ErrModeIsRequired}
+	case modeServer:
+		if config.Server == nil || len(config.Server.URL) == 0 {
+			problems = append(problems, fmt.Errorf("%w (server.url)", ErrServerURLIsRequired))
+		} else if _, err := url.ParseRequestURI(config.Server.URL); err != nil {
+			problems = append(problems, fmt.Errorf("%w: server.url is not a valid url: %s",
+				ErrInvalidConfig, config.Server.URL))
+		}
+	case modeDatabase:
+		problems = validateDatabaseConfig(config)
+	default:
+		problems = append(problems, fmt.Errorf("%w: %s (use: %s or %s)", ErrUnknownMode, config.Mode,
+			modeDatabase, modeServer))
+	}
+	return
+}
+
+// validateDatabaseConfig will check the datastore, cachestore, task manager and chainstate config (database mode)
+func validateDatabaseConfig(config *Config) (problems []error) {
+	redisRequiredBy := ""
+
+	// Datastore (and the config for the engine)
+	if config.Datastore == nil || len(config.Datastore.Engine) == 0 {
+		problems = append(problems, fmt.Errorf("%w: datastore.engine is required (use: %s, %s, %s or %s)",
+			ErrInvalidConfig, datastore.SQLite, datastore.PostgreSQL, datastore.MySQL, datastore.MongoDB))
+	} else {
+		switch config.Datastore.Engine {
+		case datastore.SQLite:
+			if config.SQLite == nil {
+				problems = append(problems, fmt.Errorf("%w: sqlite section is required when datastore.engine is %s",
+					ErrInvalidConfig, datastore.SQLite))
+			}
+		case datastore.MySQL, datastore.PostgreSQL:
+			if config.SQL == nil || len(config.SQL.Host) == 0 {
+				problems = append(problems, fmt.Errorf("%w: sql.host is required when datastore.engine is %s",
+					ErrInvalidConfig, config.Datastore.Engine))
+			}
+			if config.SQL == nil || len(config.SQL.Name) == 0 {
+				problems = append(problems, fmt.Errorf("%w: sql.name is required when datastore.engine is %s",
+					ErrInvalidConfig, config.Datastore.Engine))
+			}
+		case datastore.MongoDB:
+			if config.Mongo == nil || len(config.Mongo.URI) == 0 {
+				problems = append(problems, fmt.Errorf("%w: mongodb.uri is required when datastore.engine is %s",
+					ErrInvalidConfig, datastore.MongoDB))
+			} else if !strings.HasPrefix(config.Mongo.URI, "mongodb://") &&
+				!strings.HasPrefix(config.Mongo.URI, "mongodb+srv://") {
+				problems = append(problems, fmt.Errorf("%w: mongodb.uri must start with mongodb:// or mongodb+srv://",
+					ErrInvalidConfig))
+			}
+			if config.Mongo == nil || len(config.Mongo.DatabaseName) == 0 {
+				problems = append(problems, fmt.Errorf("%w: mongodb.database_name is required when datastore.engine is %s",
+					ErrInvalidConfig, datastore.MongoDB))
+			}
+		default:
+			problems = append(problems, fmt.Errorf("%w: datastore.engine %s is not supported (use: %s, %s, %s or %s)",
+				ErrInvalidConfig, config.Datastore.Engine, datastore.SQLite, datastore.PostgreSQL, datastore.MySQL,
+				datastore.MongoDB))
+		}
+	}
+
+	// Cachestore
+	if config.Cachestore == nil || len(config.Cachestore.Engine) == 0 {
+		problems = append(problems, fmt.Errorf("%w: cachestore.engine is required (use: %s or %s)",
+			ErrInvalidConfig, cachestore.FreeCache, cachestore.Redis))
+	} else if config.Cachestore.Engine == cachestore.Redis {
+		redisRequiredBy = "cachestore.engine is " + cachestore.Redis.String()
+	} else if config.Cachestore.Engine != cachestore.FreeCache {
+		problems = append(problems, fmt.Errorf("%w: cachestore.engine %s is not supported (use: %s or %s)",
+			ErrInvalidConfig, config.Cachestore.Engine, cachestore.FreeCache, cachestore.Redis))
+	}
+
+	// Task manager
+	if config.TaskManager == nil || config.TaskManager.Engine != taskmanager.TaskQ {
+		problems = append(problems, fmt.Errorf("%w: task_manager.engine must be %s", ErrInvalidConfig, taskmanager.TaskQ))
+	} else if config.TaskManager.Factory == taskmanager.FactoryRedis {
+		if len(redisRequiredBy) == 0 {
+			redisRequiredBy = "task_manager.factory is " + taskmanager.FactoryRedis.String()
+		}
+	} else if config.TaskManager.Factory != taskmanager.FactoryMemory {
+		problems = append(problems, fmt.Errorf("%w: task_manager.factory must be %s or %s",
+			ErrInvalidConfig, taskmanager.FactoryMemory, taskmanager.FactoryRedis))
+	}
+
+	// Redis (cachestore or task manager)
+	if len(redisRequiredBy) > 0 {
+		if config.Redis == nil || len(config.Redis.URL) == 0 {
+			problems = append(problems, fmt.Errorf("%w: redis.url is required when %s", ErrInvalidConfig, redisRequiredBy))
+		} else if !strings.HasPrefix(config.Redis.URL, "redis://") && !strings.HasPrefix(config.Redis.URL, "rediss://") {
+			problems = append(problems, fmt.Errorf("%w: redis.url must start with redis:// or rediss://", ErrInvalidConfig))
+		}
+	}
+
+	// Chainstate
+	if config.Chainstate == nil {
+		problems = append(problems, fmt.Errorf("%w: chainstate section is required", ErrInvalidConfig))
+	} else {
+		if _, err := excludedProviders(config.Chainstate); err != nil {
+			problems = append(problems, err)
+		}
+		if miners, err := newMiners(config.Chainstate); err != nil {
+			problems = append(problems, err)
+		} else if !config.Chainstate.LenientMiners {
+			if _, err = selectMiners(config.Chainstate.MinersBroadcast, miners, false); err != nil {
+				problems = append(problems, fmt.Errorf("chainstate.miners_broadcast: %w", err))
+			}
+			if _, err = selectMiners(config.Chainstate.MinersQuery, miners, false); err != nil {
+				problems = append(problems, fmt.Errorf("chainstate.miners_query: %w", err))
+			}
+		}
+	}
+	return
+}
+
+// configProblemsError will return the problems as a single error (the first problem is wrapped for the exit code)
+func configProblemsError(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	} else if len(problems) == 1 {
+		return problems[0]
+	}
+	messages := make([]string, 0, len(problems)-1)
+	for _, problem := range problems[1:] {
+		messages = append(messages, problem.Error())
+	}
+	return fmt.Errorf("%w; %s", problems[0], strings.Join(messages, "; "))
+}
+
+// redactSettings will replace the secrets (keys, passwords and credentials in urls) in the settings
+func redactSettings(settings map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		redacted[key] = redactValue(key, value)
+	}
+	return redacted
+}
+
+// redactValue will redact the value, all the values in a secret section are redacted (IE: miner_tokens)
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if isSecretKey(key) {
+			redacted := make(map[string]interface{}, len(v))
+			for name, secret := range v {
+				redacted[name] = redactValue(key, secret)
+			}
+			return redacted
+		}
+		return redactSettings(v)
+	case []interface{}:
+		redacted := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			redacted = append(redacted, redactValue(key, item))
+		}
+		return redacted
+	case string:
+		return redactSetting(key, v)
+	}
+	return value
+}
+
+// isSecretKey will return true if the config key holds a secret
+func isSecretKey(key string) bool {
+	for _, secret := range configSecretKeys {
+		if strings.Contains(strings.ToLower(key), secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSetting will redact the value if the key is a secret, or remove the password from a url
+func redactSetting(key, value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	if isSecretKey(key) {
+		return configRedactedValue
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if password, hasPassword := u.User.Password(); hasPassword && len(password) > 0 {
+			u.User = url.User(u.User.Username()) // The username is escaped, the first @ is the user info
+			return strings.Replace(u.String(), "@", ":"+configRedactedValue+"@", 1)
+		}
+	}
+	return value
+}
@@ -6,9 +6,11 @@ import (
 	"github.com/BuxOrg/bux"
 	"github.com/BuxOrg/bux-cli/database"
 	"github.com/BuxOrg/bux/taskmanager"
+	"github.com/BuxOrg/bux/utils"
 	"github.com/mrz1836/go-cachestore"
 	"github.com/mrz1836/go-datastore"
 	"github.com/mrz1836/go-whatsonchain"
+	"github.com/tonicpow/go-minercraft"
 )
 
 // Version of the application
@@ -16,38 +18,156 @@ var Version = "v0.1.4"
 
 // Default flag values for various commands
 var (
-	applicationDirectory string // Folder path for the application resources
-	configFile           string // cmd: root
-	disableCache         bool   // cmd: root
-	draftID              string // cmd: tx
-	flushCache           bool   // cmd: root
-	generateDocs         bool   // cmd: root
-	metadata             string // cmd: tx, xpub, destination
-	txConfig             string // cmd: tx
-	txHex                string // cmd: tx
-	txID                 string // cmd: tx
-	verbose              bool   // cmd: root
-	wocEnabled           bool   // cmd: tx
-	xpubID               string // cmd: destination
-	xpriv                string // cmd: tx
+	accessKeyStatus      string   // cmd: accesskey
+	addressOnly          bool     // cmd: destination
+	applicationDirectory string   // Folder path for the application resources
+	configFile           string   // cmd: root
+	configCachestore     string   // cmd: config
+	configDatastore      string   // cmd: config
+	configForce          bool     // cmd: config
+	configMode           string   // cmd: config
+	configMongoURI       string   // cmd: config
+	configNonInteractive bool     // cmd: config
+	configRedisURL       string   // cmd: config
+	configServerURL      string   // cmd: config
+	configSQLHost        string   // cmd: config
+	configTaskManager    string   // cmd: config
+	decodeOffline        bool     // cmd: tx
+	deriveChain          uint32   // cmd: xpriv, xpub
+	deriveIndex          uint32   // cmd: xpriv, xpub
+	derivePath           string   // cmd: xpriv, xpub
+	deriveRange          string   // cmd: xpriv, xpub
+	destinationChain     string   // cmd: destination
+	destinationCount     int      // cmd: destination
+	destinationFilter    string   // cmd: utxo
+	destinationMonitor   bool     // cmd: destination
+	destinationType      string   // cmd: destination
+	disableCache         bool     // cmd: root
+	draftExpired         bool     // cmd: draft
+	draftID              string   // cmd: tx
+	draftStatus          string   // cmd: draft
+	flushCache           bool     // cmd: root
+	fromDate             string   // cmd: tx
+	fromHeight           uint64   // cmd: tx
+	generateDocs         bool     // cmd: root
+	keyName              string   // cmd: tx, xpub
+	maxSatoshis          uint64   // cmd: utxo
+	metadata             string   // cmd: tx, xpub, destination, utxo, paymail, accesskey
+	metadataDelete       []string // cmd: tx, xpub, destination, paymail
+	metadataFile         string   // cmd: tx, xpub, destination, utxo, paymail, accesskey
+	metadataReplace      bool     // cmd: tx, xpub, destination, paymail
+	mnemonicEnabled      bool     // cmd: xpriv
+	mnemonicPassphrase   string   // cmd: xpriv
+	mnemonicWords        int      // cmd: xpriv
+	minSatoshis          uint64   // cmd: utxo
+	orderBy              string   // cmd: tx, utxo, paymail, accesskey, destination
+	outFile              string   // cmd: tx
+	outputFormat         string   // cmd: root
+	page                 int      // cmd: tx, utxo, paymail, accesskey, destination
+	pageSize             int      // cmd: tx, utxo, paymail, accesskey, destination
+	paymailAvatar        string   // cmd: paymail
+	paymailPublicName    string   // cmd: paymail
+	profileName          string   // cmd: root
+	sortDirection        string   // cmd: tx, utxo, paymail, accesskey, destination
+	toDate               string   // cmd: tx
+	toHeight             uint64   // cmd: tx
+	txChangeDestinations int      // cmd: tx
+	txConfig             string   // cmd: tx
+	txDirection          string   // cmd: tx
+	txFeePerByte         float64  // cmd: tx
+	txHex                string   // cmd: tx
+	txHexFile            string   // cmd: tx
+	txRecordBulk         bool     // cmd: tx
+	txID                 string   // cmd: tx
+	txOpReturns          []string // cmd: tx
+	txRecipients         []string // cmd: tx
+	txSatoshis           []uint   // cmd: tx
+	txSendAll            bool     // cmd: tx
+	txStatus             string   // cmd: tx
+	utxoStatus           string   // cmd: utxo
+	verbose              bool     // cmd: root
+	wocEnabled           bool     // cmd: tx
+	workerOnce           bool     // cmd: worker
+	workerTaskNames      []string // cmd: worker
+	xpubID               string   // cmd: destination, accesskey
+	xpubKey              string   // cmd: accesskey
+	xpriv                string   // cmd: tx
 )
 
 // Flags for the application
 const (
+	flagAddressOnly    = "address-only"
+	flagAvatar         = "avatar"
+	flagBulk           = "bulk"
+	flagCachestore     = "cachestore"
+	flagChain          = "chain"
+	flagChangeDests    = "change-destinations"
+	flagConfig         = "config"
+	flagCount          = "count"
+	flagDatastore      = "datastore"
+	flagDeleteKey      = "delete-key"
+	flagDestination    = "destination"
+	flagDirection      = "direction"
+	flagExpired        = "expired"
+	flagFeePerByte     = "fee-per-byte"
+	flagForce          = "force"
+	flagFromDate       = "from-date"
+	flagFromHeight     = "from-height"
+	flagIndex          = "index"
+	flagKey            = "key"
+	flagMaxSatoshis    = "max-sats"
 	flagMetadata       = "metadata"
+	flagMetadataFile   = "metadata-file"
 	flagMetadataShort  = "m"
+	flagMinSatoshis    = "min-sats"
+	flagMnemonic       = "mnemonic"
+	flagMode           = "mode"
+	flagMongoURI       = "mongo-uri"
+	flagMonitor        = "monitor"
+	flagNonInteractive = "non-interactive"
+	flagOffline        = "offline"
+	flagOnce           = "once"
+	flagOpReturn       = "op-return"
+	flagOrderBy        = "order-by"
+	flagOut            = "out"
+	flagOutput         = "output"
+	flagOutputShort    = "o"
+	flagPage           = "page"
+	flagPageSize       = "page-size"
+	flagPassphrase     = "passphrase"
+	flagPath           = "path"
+	flagProfile        = "profile"
+	flagPublicName     = "public-name"
+	flagRange          = "range"
+	flagRedisURL       = "redis-url"
+	flagReplace        = "replace"
+	flagSatoshis       = "sats"
+	flagSendAll        = "send-all"
+	flagServerURL      = "server-url"
+	flagSort           = "sort"
+	flagSQLHost        = "sql-host"
+	flagStatus         = "status"
+	flagTaskManager    = "task-manager"
+	flagToDate         = "to-date"
+	flagTasks          = "tasks"
+	flagTo             = "to"
+	flagToHeight       = "to-height"
 	flagTxConfig       = "txconfig"
 	flagTxConfigShort  = "c"
 	flagTxDraftID      = "draft"
 	flagTxDraftIDShort = "d"
 	flagTxHex          = "hex"
+	flagTxHexFile      = "hex-file"
 	flagTxHexShort     = "x"
 	flagTxID           = "txid"
 	flagTxIDShort      = "i"
+	flagType           = "type"
 	flagWoc            = "woc"
 	flagWocShort       = "w"
+	flagWords          = "words"
 	flagXpriv          = "xpriv"
 	flagXprivShort     = "p"
+	flagXpub           = "xpub"
 	flagXpubID         = "xpubid"
 	flagXpubIDShort    = "x"
 )
@@ -67,14 +187,18 @@ type (
 	// App is the main application struct
 	// This is used to pass around the application configuration and services
 	App struct {
-		applicationDirectory string              // Folder path for the application resources
-		bux                  bux.ClientInterface // BUX Client
-		config               *Config             // Application configuration
-		database             *database.DB        // CLI Application database (internal buxcli DB)
+		applicationDirectory string                  // Folder path for the application resources
+		backend              Backend                 // BUX backend (database or server)
+		bux                  bux.ClientInterface     // BUX Client (database mode only)
+		config               *Config                 // Application configuration
+		cronService          taskmanager.CronService // Custom cron service for the task manager (worker)
+		database             *database.DB            // CLI Application database (internal buxcli DB)
+		offline              bool                    // Offline command (no config, BUX or CLI database)
 	}
 
 	// Config is the configuration for the application and BUX
 	Config struct {
+		Cache       *CacheConfig             `json:"cache" mapstructure:"cache"`               // Local cache config (WhatsOnChain lookups)
 		Cachestore  *CachestoreConfig        `json:"cachestore" mapstructure:"cachestore"`     // Cachestore config
 		Chainstate  *ChainstateConfig        `json:"chainstate" mapstructure:"chainstate"`     // Chainstate config
 		Datastore   *DatastoreConfig         `json:"datastore" mapstructure:"datastore"`       // Datastore config
@@ -82,12 +206,23 @@ type (
 		Mode        string                   `json:"mode" mapstructure:"mode"`                 // Mode is either database or server
 		Mongo       *datastore.MongoDBConfig `json:"mongodb" mapstructure:"mongodb"`           // MongoDB config
 		Redis       *RedisConfig             `json:"redis" mapstructure:"redis"`               // Redis config
+		Server      *ServerConfig            `json:"server" mapstructure:"server"`             // BUX server config (server mode)
 		SQL         *datastore.SQLConfig     `json:"sql" mapstructure:"sql"`                   // SQL config (MySQL, Postgres, etc)
 		SQLite      *datastore.SQLiteConfig  `json:"sqlite" mapstructure:"sqlite"`             // SQLite config
 		TaskManager *TaskManagerConfig       `json:"task_manager" mapstructure:"task_manager"` // TaskManager config
 		Verbose     bool                     `json:"verbose" mapstructure:"verbose"`           // Verbose mode (also enables debug)
 	}
 
+	// CacheConfig is the configuration for the local cache of WhatsOnChain lookups
+	// A TTL of 0 uses the default, a negative TTL disables caching for that lookup
+	CacheConfig struct {
+		AddressBalanceTTL         time.Duration `json:"address_balance_ttl" mapstructure:"address_balance_ttl"`                 // Address balance (default: 1m)
+		AddressInfoTTL            time.Duration `json:"address_info_ttl" mapstructure:"address_info_ttl"`                       // Address info (default: 5m)
+		RawTransactionTTL         time.Duration `json:"raw_transaction_ttl" mapstructure:"raw_transaction_ttl"`                 // Raw transaction hex (default: never expires)
+		TransactionTTL            time.Duration `json:"transaction_ttl" mapstructure:"transaction_ttl"`                         // Confirmed transaction (default: never expires)
+		UnconfirmedTransactionTTL time.Duration `json:"unconfirmed_transaction_ttl" mapstructure:"unconfirmed_transaction_ttl"` // Unconfirmed transaction (default: 30s)
+	}
+
 	// CachestoreConfig is the configuration for the cachestore
 	CachestoreConfig struct {
 		Engine cachestore.Engine `json:"engine" mapstructure:"engine"` // Cache engine to use (redis, freecache)
@@ -95,13 +230,28 @@ type (
 
 	// ChainstateConfig is a configuration for the chainstate
 	ChainstateConfig struct {
-		BroadcastInstantly bool     `json:"broadcast_instantly" mapstructure:"broadcast_instantly"` // true for broadcasting instantly
-		Broadcasting       bool     `json:"broadcast" mapstructure:"broadcast"`                     // true for broadcasting
-		MinersBroadcast    []string `json:"miners_broadcast" mapstructure:"miners_broadcast"`       // Miners for broadcasting
-		MinersQuery        []string `json:"miners_query" mapstructure:"miners_query"`               // Miners for querying
-		P2P                bool     `json:"p2p" mapstructure:"p2p"`                                 // true for p2p
-		SyncOnChain        bool     `json:"sync_on_chain" mapstructure:"sync_on_chain"`             // true for syncing on chain
-		TaalAPIKey         string   `json:"taal_api_key" mapstructure:"taal_api_key"`               // Taal API key
+		BroadcastInstantly bool              `json:"broadcast_instantly" mapstructure:"broadcast_instantly"`   // true for broadcasting instantly
+		Broadcasting       bool              `json:"broadcast" mapstructure:"broadcast"`                       // true for broadcasting
+		ExcludedProviders  []string          `json:"excluded_providers" mapstructure:"excluded_providers"`     // Providers to exclude (mapi, nownodes, whatsonchain)
+		LenientMiners      bool              `json:"lenient_miners" mapstructure:"lenient_miners"`             // true to warn (instead of error) on unknown miners
+		MinerTokens        map[string]string `json:"miner_tokens" mapstructure:"miner_tokens"`                 // Tokens by miner name (IE: taal: <token>)
+		Miners             []*MinerConfig    `json:"miners" mapstructure:"miners"`                             // Custom miners (used by name in the miner lists)
+		MinersBroadcast    []string          `json:"miners_broadcast" mapstructure:"miners_broadcast"`         // Miners for broadcasting
+		MinersQuery        []string          `json:"miners_query" mapstructure:"miners_query"`                 // Miners for querying
+		NowNodesAPIKey     string            `json:"nownodes_api_key" mapstructure:"nownodes_api_key"`         // NowNodes API key (excluded without a key)
+		P2P                bool              `json:"p2p" mapstructure:"p2p"`                                   // true for p2p
+		SyncOnChain        bool              `json:"sync_on_chain" mapstructure:"sync_on_chain"`               // true for syncing on chain
+		TaalAPIKey         string            `json:"taal_api_key" mapstructure:"taal_api_key"`                 // Taal API key (same as the Taal miner token)
+		WhatsOnChainAPIKey string            `json:"whatsonchain_api_key" mapstructure:"whatsonchain_api_key"` // WhatsOnChain API key
+	}
+
+	// MinerConfig is a custom miner definition for the chainstate
+	MinerConfig struct {
+		APIType string `json:"api_type" mapstructure:"api_type"` // API type of the miner (mapi)
+		MinerID string `json:"miner_id" mapstructure:"miner_id"` // Miner ID (public key)
+		Name    string `json:"name" mapstructure:"name"`         // Name of the miner (IE: used in miners_broadcast)
+		Token   string `json:"token" mapstructure:"token"`       // Token for the miner API (optional)
+		URL     string `json:"url" mapstructure:"url"`           // URL of the miner API (IE: https://merchantapi.taal.com)
 	}
 
 	// DatastoreConfig is a configuration for the datastore
@@ -123,6 +273,17 @@ type (
 		UseTLS                bool          `json:"use_tls" mapstructure:"use_tls"`                                 // Flag for using TLS
 	}
 
+	// ServerConfig is a configuration for connecting to a remote BUX server
+	ServerConfig struct {
+		AccessKey   string        `json:"access_key" mapstructure:"access_key"`     // Access key (private key hex) for authentication
+		AdminKey    string        `json:"admin_key" mapstructure:"admin_key"`       // Admin xpriv for admin requests (IE: new xpub)
+		SignRequest bool          `json:"sign_request" mapstructure:"sign_request"` // true for signing requests with the xpriv
+		Timeout     time.Duration `json:"timeout" mapstructure:"timeout"`           // Timeout for requests to the server
+		URL         string        `json:"url" mapstructure:"url"`                   // URL of the server (IE: http://localhost:3003/v1)
+		Xpriv       string        `json:"xpriv" mapstructure:"xpriv"`               // Xpriv for authentication (and signing)
+		Xpub        string        `json:"xpub" mapstructure:"xpub"`                 // Xpub for authentication (signing disabled)
+	}
+
 	// TaskManagerConfig is a configuration for the taskmanager
 	TaskManagerConfig struct {
 		Engine    taskmanager.Engine  `json:"engine" mapstructure:"engine"`         // taskq, machinery
@@ -136,20 +297,269 @@ type (
 		FullKey string `json:"full_key" mapstructure:"full_key"`
 	}
 
+	// DraftDetails is a draft transaction with the utxos that it reserves
+	DraftDetails struct {
+		Draft         *bux.DraftTransaction `json:"draft" mapstructure:"draft"`
+		Expired       bool                  `json:"expired" mapstructure:"expired"` // Open, but past the expiry
+		ReservedUtxos []*bux.Utxo           `json:"reserved_utxos" mapstructure:"reserved_utxos"`
+	}
+
+	// DecodedTransaction is a decoded transaction (the fee is set if the inputs are resolved)
+	DecodedTransaction struct {
+		Fee         *uint64          `json:"fee,omitempty" mapstructure:"fee"`
+		FeeRate     float64          `json:"fee_rate,omitempty" mapstructure:"fee_rate"` // Satoshis per byte
+		Inputs      []*DecodedInput  `json:"inputs" mapstructure:"inputs"`
+		LockTime    uint32           `json:"lock_time" mapstructure:"lock_time"`
+		Outputs     []*DecodedOutput `json:"outputs" mapstructure:"outputs"`
+		Size        int              `json:"size" mapstructure:"size"`
+		TotalInput  *uint64          `json:"total_input,omitempty" mapstructure:"total_input"`
+		TotalOutput uint64           `json:"total_output" mapstructure:"total_output"`
+		TxID        string           `json:"tx_id" mapstructure:"tx_id"`
+		Version     uint32           `json:"version" mapstructure:"version"`
+	}
+
+	// DecodedInput is an input of a decoded transaction (the previous output is set if it is resolved)
+	DecodedInput struct {
+		Address       string            `json:"address,omitempty" mapstructure:"address"`
+		Destination   *KnownDestination `json:"destination,omitempty" mapstructure:"destination"`
+		Index         int               `json:"index" mapstructure:"index"`
+		PreviousIndex uint32            `json:"previous_index" mapstructure:"previous_index"`
+		PreviousTxID  string            `json:"previous_tx_id" mapstructure:"previous_tx_id"`
+		Satoshis      *uint64           `json:"satoshis,omitempty" mapstructure:"satoshis"`
+		ScriptType    string            `json:"script_type,omitempty" mapstructure:"script_type"`
+		Sequence      uint32            `json:"sequence" mapstructure:"sequence"`
+	}
+
+	// DecodedOutput is an output of a decoded transaction
+	DecodedOutput struct {
+		Address     string              `json:"address,omitempty" mapstructure:"address"`
+		Destination *KnownDestination   `json:"destination,omitempty" mapstructure:"destination"`
+		Index       int                 `json:"index" mapstructure:"index"`
+		OpReturn    []*OpReturnProtocol `json:"op_return,omitempty" mapstructure:"op_return"`
+		Satoshis    uint64              `json:"satoshis" mapstructure:"satoshis"`
+		ScriptType  string              `json:"script_type" mapstructure:"script_type"`
+	}
+
+	// KnownDestination is a BUX destination of a decoded input or output
+	KnownDestination struct {
+		Chain  uint32 `json:"chain" mapstructure:"chain"`
+		ID     string `json:"id" mapstructure:"id"`
+		Num    uint32 `json:"num" mapstructure:"num"`
+		XpubID string `json:"xpub_id" mapstructure:"xpub_id"`
+	}
+
+	// OpReturnProtocol is the OP_RETURN data of a protocol (MAP, B or data), the protocols are separated by a pipe
+	OpReturnProtocol struct {
+		Command  string            `json:"command,omitempty" mapstructure:"command"` // MAP command (IE: SET)
+		Data     map[string]string `json:"data,omitempty" mapstructure:"data"`       // MAP keys, B media type etc.
+		Parts    []*OpReturnPart   `json:"parts,omitempty" mapstructure:"parts"`
+		Protocol string            `json:"protocol" mapstructure:"protocol"`
+	}
+
+	// OpReturnPart is a part (push data) of the OP_RETURN data (UTF-8 if it is readable text)
+	OpReturnPart struct {
+		Hex  string `json:"hex" mapstructure:"hex"`
+		UTF8 string `json:"utf8,omitempty" mapstructure:"utf8"`
+	}
+
+	// RecordResult is the result of recording a transaction from a line of a hex file (bulk)
+	RecordResult struct {
+		Error    string `json:"error,omitempty" mapstructure:"error"`
+		Line     int    `json:"line" mapstructure:"line"`
+		Recorded bool   `json:"recorded" mapstructure:"recorded"`
+		TxID     string `json:"tx_id,omitempty" mapstructure:"tx_id"`
+	}
+
+	// SignedTransaction is a draft transaction signed offline (recorded with: transaction record --draft)
+	SignedTransaction struct {
+		DraftID string `json:"draft_id" mapstructure:"draft_id"`
+		Hex     string `json:"hex" mapstructure:"hex"`
+		TxID    string `json:"tx_id" mapstructure:"tx_id"`
+		XpubID  string `json:"xpub_id" mapstructure:"xpub_id"`
+	}
+
+	// XpubSummary is the summary of a xpub (balances, destinations, utxos, transactions and derivation)
+	XpubSummary struct {
+		CurrentBalance       uint64          `json:"current_balance" mapstructure:"current_balance"`
+		ExternalDestinations int64           `json:"external_destinations" mapstructure:"external_destinations"`
+		IncomingSatoshis     uint64          `json:"incoming_satoshis" mapstructure:"incoming_satoshis"`
+		InternalDestinations int64           `json:"internal_destinations" mapstructure:"internal_destinations"`
+		LastTransactionAt    *time.Time      `json:"last_transaction_at,omitempty" mapstructure:"last_transaction_at"`
+		NextExternalNum      uint32          `json:"next_external_num" mapstructure:"next_external_num"`
+		NextInternalNum      uint32          `json:"next_internal_num" mapstructure:"next_internal_num"`
+		OutgoingSatoshis     uint64          `json:"outgoing_satoshis" mapstructure:"outgoing_satoshis"`
+		TransactionCount     int64           `json:"transaction_count" mapstructure:"transaction_count"`
+		UtxoCount            int64           `json:"utxo_count" mapstructure:"utxo_count"`
+		UtxoSatoshis         uint64          `json:"utxo_satoshis" mapstructure:"utxo_satoshis"`
+		WOC                  *XpubWOCBalance `json:"woc,omitempty" mapstructure:"woc"`
+		XpubID               string          `json:"xpub_id" mapstructure:"xpub_id"`
+	}
+
+	// XpubWOCBalance is the balance of the xpub destinations on WhatsOnChain (cross-checked with BUX)
+	XpubWOCBalance struct {
+		Confirmed     int64                 `json:"confirmed" mapstructure:"confirmed"`
+		Discrepancies []*BalanceDiscrepancy `json:"discrepancies" mapstructure:"discrepancies"`
+		Matches       bool                  `json:"matches" mapstructure:"matches"`
+		Unconfirmed   int64                 `json:"unconfirmed" mapstructure:"unconfirmed"`
+	}
+
+	// BalanceDiscrepancy is an address with a different balance in BUX (unspent utxos) and on WhatsOnChain
+	BalanceDiscrepancy struct {
+		Address     string `json:"address" mapstructure:"address"`
+		BuxSatoshis uint64 `json:"bux_satoshis" mapstructure:"bux_satoshis"`
+		WOCSatoshis int64  `json:"woc_satoshis" mapstructure:"woc_satoshis"`
+	}
+
 	// Keys is a struct for the private keys, wif, xpriv and xpub
 	Keys struct {
+		Mnemonic   string `json:"mnemonic,omitempty" mapstructure:"mnemonic"`
 		PrivateKey string `json:"private_key" mapstructure:"private_key"`
 		WIF        string `json:"wif" mapstructure:"wif"`
 		Xpriv      string `json:"xpriv" mapstructure:"xpriv"`
 		Xpub       string `json:"xpub" mapstructure:"xpub"`
 	}
 
+	// DerivedKey is a child key derived from an xpriv or xpub (the xpriv and WIF are only set for an xpriv)
+	DerivedKey struct {
+		Address   string `json:"address" mapstructure:"address"`
+		Path      string `json:"path" mapstructure:"path"`
+		PublicKey string `json:"public_key" mapstructure:"public_key"`
+		WIF       string `json:"wif,omitempty" mapstructure:"wif"`
+		Xpriv     string `json:"xpriv,omitempty" mapstructure:"xpriv"`
+		Xpub      string `json:"xpub" mapstructure:"xpub"`
+	}
+
+	// KeystoreKey is a struct for a named xpriv in the local keystore (the xpriv is stored encrypted)
+	KeystoreKey struct {
+		CreatedAt time.Time `json:"created_at" mapstructure:"created_at"`
+		Name      string    `json:"name" mapstructure:"name"`
+		Xpub      string    `json:"xpub" mapstructure:"xpub"`
+	}
+
+	// ConfigProfile is a named configuration profile (default is config.json, the others are in profiles/)
+	ConfigProfile struct {
+		Active bool   `json:"active" mapstructure:"active"`
+		Name   string `json:"name" mapstructure:"name"`
+		Path   string `json:"path" mapstructure:"path"`
+	}
+
+	// ConfigProfileSettings is the profile configuration (the secrets are redacted)
+	ConfigProfileSettings struct {
+		Name     string                 `json:"name" mapstructure:"name"`
+		Path     string                 `json:"path" mapstructure:"path"`
+		Settings map[string]interface{} `json:"settings" mapstructure:"settings"`
+	}
+
+	// ConfigValidation is the result of validating a profile configuration
+	ConfigValidation struct {
+		Errors []string `json:"errors" mapstructure:"errors"`
+		Name   string   `json:"name" mapstructure:"name"`
+		Path   string   `json:"path" mapstructure:"path"`
+		Valid  bool     `json:"valid" mapstructure:"valid"`
+	}
+
+	// MinerInfo is a configured miner (used for broadcasting and/or querying)
+	MinerInfo struct {
+		Broadcast bool   `json:"broadcast" mapstructure:"broadcast"`
+		HasToken  bool   `json:"has_token" mapstructure:"has_token"`
+		MinerID   string `json:"miner_id" mapstructure:"miner_id"`
+		Name      string `json:"name" mapstructure:"name"`
+		Query     bool   `json:"query" mapstructure:"query"`
+		URL       string `json:"url" mapstructure:"url"`
+	}
+
+	// MinerCheck is the result of checking a miner (reachability, latency, fee quote and policy)
+	MinerCheck struct {
+		*MinerInfo
+		Error     string             `json:"error,omitempty" mapstructure:"error"`
+		Fees      []*MinerFee        `json:"fees" mapstructure:"fees"`
+		LatencyMS int64              `json:"latency_ms" mapstructure:"latency_ms"`
+		Policy    *minercraft.Policy `json:"policy,omitempty" mapstructure:"policy"`
+		Reachable bool               `json:"reachable" mapstructure:"reachable"`
+	}
+
+	// MinerFee is a fee from the miner fee quote (satoshis per byte)
+	MinerFee struct {
+		FeeType   string  `json:"fee_type" mapstructure:"fee_type"`
+		MiningFee float64 `json:"mining_fee" mapstructure:"mining_fee"`
+		RelayFee  float64 `json:"relay_fee" mapstructure:"relay_fee"`
+	}
+
+	// MinerFeeEstimate is the fee of an estimated transaction at the quoted rate of a miner (standard mining fee)
+	MinerFeeEstimate struct {
+		Error   string  `json:"error,omitempty" mapstructure:"error"`
+		Fee     uint64  `json:"fee" mapstructure:"fee"`
+		FeeRate float64 `json:"fee_rate" mapstructure:"fee_rate"` // Satoshis per byte
+		Name    string  `json:"name" mapstructure:"name"`
+	}
+
+	// TransactionEstimate is the estimate of a new transaction (the draft is canceled, no utxos are reserved)
+	TransactionEstimate struct {
+		ChangeOutputs  []*EstimateOutput   `json:"change_outputs" mapstructure:"change_outputs"`
+		ChangeSatoshis uint64              `json:"change_satoshis" mapstructure:"change_satoshis"`
+		Fee            uint64              `json:"fee" mapstructure:"fee"`
+		FeeUnit        *utils.FeeUnit      `json:"fee_unit" mapstructure:"fee_unit"`
+		Inputs         []*EstimateInput    `json:"inputs" mapstructure:"inputs"`
+		MinerFees      []*MinerFeeEstimate `json:"miner_fees" mapstructure:"miner_fees"`
+		Outputs        []*EstimateOutput   `json:"outputs" mapstructure:"outputs"`
+		Size           uint64              `json:"size" mapstructure:"size"` // Estimated size of the signed transaction
+		TotalInput     uint64              `json:"total_input" mapstructure:"total_input"`
+		TotalOutput    uint64              `json:"total_output" mapstructure:"total_output"`
+	}
+
+	// EstimateInput is a utxo that is selected for an estimated transaction
+	EstimateInput struct {
+		Address       string `json:"address" mapstructure:"address"`
+		OutputIndex   uint32 `json:"output_index" mapstructure:"output_index"`
+		Satoshis      uint64 `json:"satoshis" mapstructure:"satoshis"`
+		TransactionID string `json:"transaction_id" mapstructure:"transaction_id"`
+	}
+
+	// EstimateOutput is an output of an estimated transaction (recipient, OP_RETURN or change)
+	EstimateOutput struct {
+		Satoshis   uint64 `json:"satoshis" mapstructure:"satoshis"`
+		ScriptType string `json:"script_type,omitempty" mapstructure:"script_type"`
+		To         string `json:"to,omitempty" mapstructure:"to"`
+	}
+
 	// Transaction is a struct for the bux model and whatsonchain transaction
 	Transaction struct {
 		Bux *bux.Transaction     `json:"bux" mapstructure:"bux"`
 		WOC *whatsonchain.TxInfo `json:"woc,omitempty" mapstructure:"woc"`
 	}
 
+	// AccessKey is a struct for the bux access key model
+	// The private key (bux.AccessKey.Key) is only set when the access key is created
+	AccessKey struct {
+		Bux     *bux.AccessKey `json:"bux" mapstructure:"bux"`
+		Revoked bool           `json:"revoked" mapstructure:"revoked"`
+	}
+
+	// Paymail is a struct for the bux paymail address model
+	Paymail struct {
+		Bux     *bux.PaymailAddress `json:"bux" mapstructure:"bux"`
+		Deleted bool                `json:"deleted,omitempty" mapstructure:"deleted"`
+	}
+
+	// UtxoSummary is a summary of the utxos for an xpub (count, total and value histogram)
+	UtxoSummary struct {
+		Count            int64                  `json:"count" mapstructure:"count"`
+		Histogram        []*UtxoHistogramBucket `json:"histogram" mapstructure:"histogram"`
+		ReservedCount    int64                  `json:"reserved_count" mapstructure:"reserved_count"`
+		ReservedSatoshis uint64                 `json:"reserved_satoshis" mapstructure:"reserved_satoshis"`
+		Status           string                 `json:"status" mapstructure:"status"`
+		TotalSatoshis    uint64                 `json:"total_satoshis" mapstructure:"total_satoshis"`
+		XpubID           string                 `json:"xpub_id" mapstructure:"xpub_id"`
+	}
+
+	// UtxoHistogramBucket is a range of utxo values (satoshis) in the utxo summary
+	UtxoHistogramBucket struct {
+		Count    int64  `json:"count" mapstructure:"count"`
+		Max      uint64 `json:"max" mapstructure:"max"`
+		Min      uint64 `json:"min" mapstructure:"min"`
+		Satoshis uint64 `json:"satoshis" mapstructure:"satoshis"`
+	}
+
 	// Destination is a struct for the bux model and whatsonchain destination
 	Destination struct {
 		Bux        *bux.Destination             `json:"bux" mapstructure:"bux"`
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitcoinschema/go-bitcoin/v2"
+	"github.com/libsv/go-bk/bec"
+	"github.com/libsv/go-bk/bip32"
+	"github.com/libsv/go-bk/chaincfg"
+	"github.com/libsv/go-bk/wif"
+	"github.com/spf13/cobra"
+)
+
+// deriveRangeMax is the maximum number of keys derived with --range
+const deriveRangeMax = 10000
+
+// addDeriveFlags will add the derivation flags (path, chain, index and range) to the command
+func addDeriveFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&derivePath, flagPath, "", "Derivation path, IE: m/0/5 (hardened: m/44'/0)")
+	cmd.Flags().Uint32Var(&deriveChain, flagChain, 0, "Derivation chain: 0 = external, 1 = internal (change)")
+	cmd.Flags().Uint32Var(&deriveIndex, flagIndex, 0, "Derivation index (num) on the chain")
+	cmd.Flags().StringVar(&deriveRange, flagRange, "", "Range of indexes to derive, IE: 0-99")
+}
+
+// getDerivationPaths will return the derivation paths from the flags
+//
+// The --path flag cannot be combined with --chain or --index, the --range replaces the index
+// (or is added to the end of the --path)
+func getDerivationPaths(cmd *cobra.Command) ([][]uint32, error) {
+	pathSet := cmd.Flags().Changed(flagPath)
+	if pathSet && (cmd.Flags().Changed(flagChain) || cmd.Flags().Changed(flagIndex)) {
+		return nil, fmt.Errorf("%w: --%s cannot be combined with --%s or --%s",
+			ErrInvalidFlag, flagPath, flagChain, flagIndex)
+	} else if len(deriveRange) > 0 && cmd.Flags().Changed(flagIndex) {
+		return nil, fmt.Errorf("%w: --%s cannot be combined with --%s", ErrInvalidFlag, flagRange, flagIndex)
+	}
+
+	// Get the base path (the index is added below)
+	var base []uint32
+	if pathSet {
+		var err error
+		if base, err = parseDerivationPath(derivePath); err != nil {
+			return nil, err
+		} else if len(deriveRange) == 0 {
+			return [][]uint32{base}, nil
+		}
+	} else {
+		base = []uint32{deriveChain}
+		if len(deriveRange) == 0 {
+			return [][]uint32{{deriveChain, deriveIndex}}, nil
+		}
+	}
+
+	// Add the range of indexes to the base path
+	from, to, err := parseIndexRange(deriveRange)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([][]uint32, 0, to-from+1)
+	for index := from; index <= to; index++ {
+		path := make([]uint32, len(base), len(base)+1)
+		copy(path, base)
+		paths = append(paths, append(path, index))
+	}
+	return paths, nil
+}
+
+// parseDerivationPath will parse the path (IE: m/0/5 or m/44'/0) into the child indexes
+func parseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.Trim(strings.TrimSpace(path), "/"), "/")
+	if len(parts) > 0 && (parts[0] == "m" || parts[0] == "M") {
+		parts = parts[1:]
+	}
+
+	indexes := make([]uint32, 0, len(parts))
+	for _, part := range parts {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") || strings.HasSuffix(part, "H")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || index >= bip32.HardenedKeyStart {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidDerivationPath, path)
+		}
+		if hardened {
+			index += bip32.HardenedKeyStart
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// formatDerivationPath will return the path for the child indexes (IE: m/0/5)
+func formatDerivationPath(indexes []uint32) string {
+	var builder strings.Builder
+	builder.WriteString("m")
+	for _, index := range indexes {
+		if index >= bip32.HardenedKeyStart {
+			builder.WriteString(fmt.Sprintf("/%d'", index-bip32.HardenedKeyStart))
+		} else {
+			builder.WriteString(fmt.Sprintf("/%d", index))
+		}
+	}
+	return builder.String()
+}
+
+// parseIndexRange will parse the range (IE: 0-99 or 5) into the first and last index
+func parseIndexRange(value string) (from, to uint32, err error) {
+	first, last, found := strings.Cut(strings.TrimSpace(value), "-")
+	if !found {
+		last = first
+	}
+
+	var fromValue, toValue uint64
+	if fromValue, err = strconv.ParseUint(strings.TrimSpace(first), 10, 32); err != nil {
+		return 0, 0, fmt.Errorf("%w: %s, use the format: <from>-<to>", ErrInvalidRange, value)
+	} else if toValue, err = strconv.ParseUint(strings.TrimSpace(last), 10, 32); err != nil {
+		return 0, 0, fmt.Errorf("%w: %s, use the format: <from>-<to>", ErrInvalidRange, value)
+	} else if fromValue > toValue {
+		return 0, 0, fmt.Errorf("%w: index %d is after %d", ErrInvalidRange, fromValue, toValue)
+	} else if toValue >= bip32.HardenedKeyStart {
+		return 0, 0, fmt.Errorf("%w: index %d is a hardened index", ErrInvalidRange, toValue)
+	} else if toValue-fromValue+1 > deriveRangeMax {
+		return 0, 0, fmt.Errorf("%w: %s is more than %d keys", ErrInvalidRange, value, deriveRangeMax)
+	}
+	return uint32(fromValue), uint32(toValue), nil
+}
+
+// deriveKeys will derive the child keys for the paths
+func deriveKeys(hdKey *bip32.ExtendedKey, paths [][]uint32) ([]*DerivedKey, error) {
+	keys := make([]*DerivedKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := deriveKey(hdKey, path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// deriveKey will derive the child key for the path, the same way BUX derives the destination addresses
+// (compressed public key, IE: m/<chain>/<num> from the xpub)
+func deriveKey(hdKey *bip32.ExtendedKey, path []uint32) (*DerivedKey, error) {
+
+	// Derive the child key
+	child := hdKey
+	for _, index := range path {
+		var err error
+		if child, err = child.Child(index); err != nil {
+			return nil, fmt.Errorf("error deriving %s: %w", formatDerivationPath(path), err)
+		}
+	}
+	key := &DerivedKey{Path: formatDerivationPath(path)}
+
+	// Get the public key and the address
+	pubKey, err := child.ECPubKey()
+	if err != nil {
+		return nil, fmt.Errorf("error getting public key for %s: %w", key.Path, err)
+	}
+	key.PublicKey = hex.EncodeToString(pubKey.SerialiseCompressed())
+	if key.Address, err = bitcoin.GetAddressStringFromHDKey(child); err != nil {
+		return nil, fmt.Errorf("error getting address for %s: %w", key.Path, err)
+	}
+	if key.Xpub, err = bitcoin.GetExtendedPublicKey(child); err != nil {
+		return nil, fmt.Errorf("error getting xpub for %s: %w", key.Path, err)
+	}
+
+	// Private keys also get the xpriv and WIF (compressed, to match the address)
+	if child.IsPrivate() {
+		key.Xpriv = child.String()
+
+		var privateKey *bec.PrivateKey
+		if privateKey, err = child.ECPrivKey(); err != nil {
+			return nil, fmt.Errorf("error getting private key for %s: %w", key.Path, err)
+		}
+		var wifKey *wif.WIF
+		if wifKey, err = wif.NewWIF(privateKey, &chaincfg.MainNet, true); err != nil {
+			return nil, fmt.Errorf("error generating WIF for %s: %w", key.Path, err)
+		}
+		key.WIF = wifKey.String()
+	}
+	return key, nil
+}
+
+// displayDerivedKeys will display the key (single path) or the list of keys (range)
+func displayDerivedKeys(keys []*DerivedKey) error {
+	if len(keys) == 1 && len(deriveRange) == 0 {
+		return displayModel(keys[0])
+	}
+	return displayModel(keys)
+}
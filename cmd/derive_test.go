@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/BuxOrg/bux/utils"
+	"github.com/libsv/go-bk/bip32"
+	"github.com/libsv/go-bk/chaincfg"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDerivationPath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]uint32{
+		"m/0/5":     {0, 5},
+		"0/5":       {0, 5},
+		"m":         {},
+		"m/44'/0h/": {bip32.HardenedKeyStart + 44, bip32.HardenedKeyStart},
+	}
+	for path, expected := range tests {
+		indexes, err := parseDerivationPath(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, indexes, path)
+	}
+
+	for _, path := range []string{"m/a/5", "m/-1", "m/2147483648", "m//5"} {
+		_, err := parseDerivationPath(path)
+		require.ErrorIs(t, err, ErrInvalidDerivationPath, path)
+	}
+
+	assert.Equal(t, "m/44'/0/5", formatDerivationPath([]uint32{bip32.HardenedKeyStart + 44, 0, 5}))
+}
+
+func TestParseIndexRange(t *testing.T) {
+	t.Parallel()
+
+	from, to, err := parseIndexRange("0-99")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), from)
+	assert.Equal(t, uint32(99), to)
+
+	from, to, err = parseIndexRange("5")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(5), from)
+	assert.Equal(t, uint32(5), to)
+
+	for _, value := range []string{"", "a-b", "10-5", "0-10000", "0-2147483648"} {
+		_, _, err = parseIndexRange(value)
+		require.ErrorIs(t, err, ErrInvalidRange, value)
+	}
+}
+
+func TestGetDerivationPaths(t *testing.T) {
+	newTestCmd := func(args ...string) *cobra.Command {
+		derivePath, deriveChain, deriveIndex, deriveRange = "", 0, 0, ""
+		cmd := &cobra.Command{}
+		addDeriveFlags(cmd)
+		require.NoError(t, cmd.Flags().Parse(args))
+		return cmd
+	}
+
+	paths, err := getDerivationPaths(newTestCmd("--chain=1", "--index=5"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]uint32{{1, 5}}, paths)
+
+	paths, err = getDerivationPaths(newTestCmd("--chain=1", "--range=3-4"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]uint32{{1, 3}, {1, 4}}, paths)
+
+	paths, err = getDerivationPaths(newTestCmd("--path=m/0'/1", "--range=0-1"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]uint32{{bip32.HardenedKeyStart, 1, 0}, {bip32.HardenedKeyStart, 1, 1}}, paths)
+
+	_, err = getDerivationPaths(newTestCmd("--path=m/0/1", "--chain=1"))
+	require.ErrorIs(t, err, ErrInvalidFlag)
+
+	_, err = getDerivationPaths(newTestCmd("--index=1", "--range=0-1"))
+	require.ErrorIs(t, err, ErrInvalidFlag)
+
+	derivePath, deriveChain, deriveIndex, deriveRange = "", 0, 0, ""
+}
+
+func TestDeriveKey(t *testing.T) {
+	t.Parallel()
+
+	// BIP32 test vector 1 (https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#test-vector-1)
+	t.Run("bip32 test vector", func(t *testing.T) {
+		seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+		require.NoError(t, err)
+		var master *bip32.ExtendedKey
+		master, err = bip32.NewMaster(seed, &chaincfg.MainNet)
+		require.NoError(t, err)
+
+		var key *DerivedKey
+		key, err = deriveKey(master, []uint32{bip32.HardenedKeyStart, 1})
+		require.NoError(t, err)
+		assert.Equal(t, "m/0'/1", key.Path)
+		assert.Equal(t, "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQ"+
+			"aXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs", key.Xpriv)
+		assert.Equal(t, "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMas"+
+			"h7SyYq527Hqck2AxYysAA7xmALppuCkwQ", key.Xpub)
+	})
+
+	// The addresses must match the BUX destinations (chain/num from the xpub)
+	t.Run("matches bux destinations", func(t *testing.T) {
+		xprivKey, err := bip32.NewKeyFromString(testMnemonicVectors[0].xpriv)
+		require.NoError(t, err)
+		var xpubKey *bip32.ExtendedKey
+		xpubKey, err = xprivKey.Neuter()
+		require.NoError(t, err)
+
+		for _, chain := range []uint32{utils.ChainExternal, utils.ChainInternal} {
+			var expected string
+			expected, err = utils.DeriveAddress(xpubKey, chain, 5)
+			require.NoError(t, err)
+
+			var fromXpub, fromXpriv *DerivedKey
+			fromXpub, err = deriveKey(xpubKey, []uint32{chain, 5})
+			require.NoError(t, err)
+			fromXpriv, err = deriveKey(xprivKey, []uint32{chain, 5})
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, fromXpub.Address)
+			assert.Equal(t, expected, fromXpriv.Address)
+			assert.Equal(t, fromXpub.Xpub, fromXpriv.Xpub)
+			assert.Equal(t, fromXpub.PublicKey, fromXpriv.PublicKey)
+			assert.Empty(t, fromXpub.WIF)
+			assert.Empty(t, fromXpub.Xpriv)
+			assert.NotEmpty(t, fromXpriv.WIF)
+		}
+	})
+
+	t.Run("hardened from xpub", func(t *testing.T) {
+		xprivKey, err := bip32.NewKeyFromString(testMnemonicVectors[0].xpriv)
+		require.NoError(t, err)
+		var xpubKey *bip32.ExtendedKey
+		xpubKey, err = xprivKey.Neuter()
+		require.NoError(t, err)
+
+		_, err = deriveKey(xpubKey, []uint32{bip32.HardenedKeyStart})
+		require.ErrorIs(t, err, bip32.ErrDeriveHardFromPublic)
+	})
+}
@@ -2,12 +2,15 @@ package cmd
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/BuxOrg/bux"
-	"github.com/BuxOrg/bux-cli/chalker"
 	"github.com/BuxOrg/bux/utils"
 	"github.com/fatih/color"
+	"github.com/mrz1836/go-datastore"
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +18,31 @@ import (
 const destinationCommandName = "destination"
 const destinationCommandNew = "new"
 const destinationCommandGet = "get"
+const destinationCommandList = "list"
+const destinationCommandUpdateMetadata = "update-metadata"
+
+// destination chains (BIP32 chain of the xpub)
+const (
+	destinationChainExternal = "external" // Receive addresses (m/0/n)
+	destinationChainInternal = "internal" // Change addresses (m/1/n)
+)
+
+// maxDestinationCount is the maximum number of destinations created at once
+const maxDestinationCount = 1000
+
+// destinationTypes are the short names for the destination types (BUX locking script types)
+//
+// All the types can be used as a list filter, BUX only creates p2pkh destinations (see: newDestinationType)
+var destinationTypes = []struct {
+	name       string
+	scriptType string
+}{
+	{"p2pkh", utils.ScriptTypePubKeyHash},
+	{"p2pk", utils.ScriptTypePubKey},
+	{"multisig", utils.ScriptTypeMultiSig},
+	{"nonstandard", utils.ScriptTypeNonStandard},
+	{"stas", utils.ScriptTypeTokenStas},
+}
 
 // returnDestinationCmd returns the destination command
 func returnDestinationCmd(app *App) (newCmd *cobra.Command) {
@@ -31,40 +59,38 @@ ________  ___________ ____________________.___ _______      ________________.___
 ` + color.YellowString(`
 This command is for destination (address, locking script) related commands.
 
-new: creates a new destination in BUX (`+destinationCommandName+` new <xpub>)
+new: creates new destinations in BUX (`+destinationCommandName+` new <xpub> --`+flagCount+`=100 --`+flagChain+`=external --`+flagType+`=p2pkh --`+flagMonitor+`)
 get: gets an existing destination in BUX (`+destinationCommandName+` get <destination_id | address | locking_script> -x=<xpub_id>)
+update-metadata: updates (merges) the metadata of a destination (`+destinationCommandName+` `+destinationCommandUpdateMetadata+` <destination_id | address | locking_script> -x=<xpub_id> -m=<metadata_json>)
+list: returns the destinations for an xpub (`+destinationCommandName+` list <xpub_id> --`+flagChain+`=internal --`+flagAddressOnly+`)
 `),
 		Aliases: []string{"address"},
 		Example: applicationName + " " + destinationCommandName + " " + destinationCommandNew + " <xpub>",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) < 1 {
-				return chalker.Error(destinationCommandName + " requires a subcommand, IE: " + destinationCommandNew + ", etc.")
+				return fmt.Errorf("%s %w, IE: %s, etc.", destinationCommandName, ErrSubcommandIsRequired,
+					destinationCommandNew+", "+destinationCommandGet+", "+destinationCommandList)
 			}
 			return nil
 		},
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 
 			// Initialize the BUX client
-			deferFunc := app.InitializeBUX()
+			deferFunc, err := app.InitializeBUX()
+			if err != nil {
+				return err
+			}
 			defer deferFunc()
 
 			// Parse Metadata
-			var err error
-			if metadata, err = cmd.Flags().GetString(flagMetadata); err != nil {
-				displayError(errors.New("error parsing metadata: " + err.Error()))
-				return
+			var metaData bux.Metadata
+			if metaData, err = getMetadataFlag(cmd); err != nil {
+				return err
 			}
 
 			// Get the optional woc flag from the flags
 			if wocEnabled, err = cmd.Flags().GetBool(flagWoc); err != nil {
-				displayError(errors.New("error getting woc flag: " + err.Error()))
-				return
-			}
-
-			// Not a valid subcommand
-			if args[0] != destinationCommandNew && args[0] != destinationCommandGet {
-				displayError(ErrUnknownSubcommand)
-				return
+				return fmt.Errorf("error getting woc flag: %w", err)
 			}
 
 			// Switch on the subcommand
@@ -72,45 +98,140 @@ get: gets an existing destination in BUX (`+destinationCommandName+` get <destin
 
 				// Check if xpub is provided
 				if len(args) < 2 {
-					displayError(ErrXpubIsRequired)
-					return
+					return ErrXpubIsRequired
 				}
 
-				// Create the destination
-				var destination *Destination
-				destination, err = newDestination(context.Background(), app, args[1], metadata)
-				if err != nil {
-					displayError(errors.New("error creating destination: " + err.Error()))
-					return
+				// Check the count, the chain and the type
+				if destinationCount < 1 || destinationCount > maxDestinationCount {
+					return fmt.Errorf("%w: %d (1-%d)", ErrInvalidCount, destinationCount, maxDestinationCount)
+				}
+				var chain uint32
+				if chain, err = parseDestinationChain(destinationChain); err != nil {
+					return err
+				}
+				var scriptType string
+				if scriptType, err = newDestinationType(destinationType); err != nil {
+					return err
 				}
 
-				// Display the destination
-				displayModel(destination)
+				// Create the destinations (the destinations that were created are displayed if one fails)
+				var destinations []*Destination
+				destinations, err = newDestinations(
+					context.Background(), app, args[1], destinationCount, chain, scriptType, destinationMonitor, metaData,
+				)
+				if err != nil && len(destinations) == 0 {
+					return fmt.Errorf("error creating destination: %w", err)
+				} else if err != nil {
+					err = fmt.Errorf("error creating destination %d of %d: %w",
+						len(destinations)+1, destinationCount, err)
+				}
+
+				// Display the destinations (a single destination is displayed as a model)
+				var displayErr error
+				if addressOnly {
+					displayErr = displayAddresses(os.Stdout, outputFormat, destinations)
+				} else if destinationCount == 1 {
+					displayErr = displayModel(destinations[0])
+				} else {
+					displayErr = displayModel(destinations)
+				}
+				if err != nil {
+					return err
+				}
+				return displayErr
 
 			} else if args[0] == destinationCommandGet { // Get a destination
 
+				// Check if the destination is provided
+				if len(args) < 2 {
+					return ErrDestinationIsRequired
+				}
+
 				// Check if xpub id is provided
 				if len(xpubID) <= 0 {
-					displayError(ErrXpubIDIsRequired)
-					return
+					return ErrXpubIDIsRequired
 				}
 
 				// Get the destination
 				var destination *Destination
 				destination, err = getDestination(context.Background(), app, args[1], xpubID, wocEnabled)
 				if err != nil {
-					displayError(errors.New("error getting destination: " + err.Error()))
-					return
+					return fmt.Errorf("error getting destination: %w", err)
 				}
 
 				// Display the destination
-				displayModel(destination)
+				return displayModel(destination)
+
+			} else if args[0] == destinationCommandList { // List the destinations
+
+				// Check if xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubIDIsRequired
+				}
+
+				// Build the filters (only the flags that are set) and the pagination
+				var monitored *bool
+				if cmd.Flags().Changed(flagMonitor) {
+					monitored = &destinationMonitor
+				}
+				var conditions map[string]interface{}
+				if conditions, err = destinationConditions(
+					changedValue(cmd, flagChain, destinationChain), changedValue(cmd, flagType, destinationType), monitored,
+				); err != nil {
+					return err
+				}
+				var queryParams *datastore.QueryParams
+				if queryParams, err = newQueryParams(page, pageSize, orderBy, sortDirection); err != nil {
+					return err
+				}
+
+				// Get the destinations
+				var destinations []*Destination
+				var total int64
+				if destinations, total, err = listDestinations(
+					context.Background(), app, args[1], metaData, conditions, queryParams,
+				); err != nil {
+					return fmt.Errorf("error getting destinations: %w", err)
+				}
+
+				// Display the destinations
+				displayPage(queryParams, len(destinations), total)
+				if addressOnly {
+					return displayAddresses(os.Stdout, outputFormat, destinations)
+				}
+				return displayModel(destinations)
+
+			} else if args[0] == destinationCommandUpdateMetadata { // Update the metadata of a destination
+
+				// Check if the destination is provided
+				if len(args) < 2 {
+					return ErrDestinationIsRequired
+				}
+
+				// Check if xpub id is provided
+				if len(xpubID) <= 0 {
+					return ErrXpubIDIsRequired
+				}
+
+				// Update the metadata
+				var destination *Destination
+				if destination, err = updateDestinationMetadata(
+					context.Background(), app, args[1], xpubID, metaData, metadataDelete, metadataReplace,
+				); err != nil {
+					return fmt.Errorf("error updating destination metadata: %w", err)
+				}
+
+				// Display the destination
+				return displayModel(destination)
 			}
+
+			return ErrUnknownSubcommand
 		},
 	}
 
-	// Set the metadata flag
-	newCmd.Flags().StringVarP(&metadata, flagMetadata, flagMetadataShort, "", "Model Metadata")
+	// Set the metadata flags
+	addMetadataFlag(newCmd)
+	addMetadataUpdateFlags(newCmd)
 
 	// Set the xpub id flag
 	newCmd.Flags().StringVarP(&xpubID, flagXpubID, flagXpubIDShort, "", "Xpub ID")
@@ -121,41 +242,210 @@ get: gets an existing destination in BUX (`+destinationCommandName+` get <destin
 		"Optional flag to use WhatsOnChain for additional address data",
 	)
 
+	// Set the new destination flags (chain, type and monitor are also filters for list)
+	newCmd.Flags().IntVar(&destinationCount, flagCount, 1,
+		fmt.Sprintf("Number of destinations to create (1-%d)", maxDestinationCount))
+	newCmd.Flags().StringVar(&destinationChain, flagChain, destinationChainExternal,
+		"Chain: "+destinationChainExternal+" (receive) or "+destinationChainInternal+" (change)")
+	newCmd.Flags().StringVar(&destinationType, flagType, destinationTypes[0].name,
+		"Destination type: "+destinationTypes[0].name+" for new, or "+strings.Join(destinationTypeNames(), ", ")+
+			" as a list filter")
+	newCmd.Flags().BoolVar(&destinationMonitor, flagMonitor, false,
+		"Monitor the destination for incoming transactions (database mode only)")
+
+	// Set the list flags
+	newCmd.Flags().BoolVar(&addressOnly, flagAddressOnly, false, "Only display the addresses (one per line)")
+	addPaginationFlags(newCmd)
+
 	return
 }
 
-// newDestination creates a new destination
+// parseDestinationChain will return the BIP32 chain (external or internal, also 0 or 1)
+func parseDestinationChain(chain string) (uint32, error) {
+	switch strings.ToLower(chain) {
+	case destinationChainExternal, "0":
+		return utils.ChainExternal, nil
+	case destinationChainInternal, "1":
+		return utils.ChainInternal, nil
+	}
+	return 0, fmt.Errorf("%w: %s (use: %s or %s)", ErrInvalidDestinationChain, chain,
+		destinationChainExternal, destinationChainInternal)
+}
+
+// parseDestinationType will return the BUX locking script type (IE: p2pkh is pubkeyhash)
+func parseDestinationType(destinationType string) (string, error) {
+	for _, t := range destinationTypes {
+		if strings.EqualFold(destinationType, t.name) || strings.EqualFold(destinationType, t.scriptType) {
+			return t.scriptType, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s (use: %s)", ErrInvalidDestinationType, destinationType,
+		strings.Join(destinationTypeNames(), ", "))
+}
+
+// newDestinationType will return the BUX locking script type for new destinations (only p2pkh is supported)
+func newDestinationType(destinationType string) (string, error) {
+	scriptType, err := parseDestinationType(destinationType)
+	if err != nil {
+		return "", err
+	} else if scriptType != utils.ScriptTypePubKeyHash {
+		return "", fmt.Errorf("%w: %s, only %s destinations can be created (the other types are list filters)",
+			bux.ErrUnsupportedDestinationType, destinationType, destinationTypes[0].name)
+	}
+	return scriptType, nil
+}
+
+// destinationTypeNames will return the short names of the destination types
+func destinationTypeNames() []string {
+	names := make([]string, 0, len(destinationTypes))
+	for _, t := range destinationTypes {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+// changedValue will return the value of the flag if it was set (empty otherwise)
+func changedValue(cmd *cobra.Command, flag, value string) string {
+	if cmd.Flags().Changed(flag) {
+		return value
+	}
+	return ""
+}
+
+// destinationConditions will build the conditions for listing destinations (empty or nil = not filtered)
+func destinationConditions(chain, destinationType string, monitored *bool) (map[string]interface{}, error) {
+	conditions := make(map[string]interface{})
+	if len(chain) > 0 {
+		chainNum, err := parseDestinationChain(chain)
+		if err != nil {
+			return nil, err
+		}
+		conditions["chain"] = chainNum
+	}
+	if len(destinationType) > 0 {
+		scriptType, err := parseDestinationType(destinationType)
+		if err != nil {
+			return nil, err
+		}
+		conditions["type"] = scriptType
+	}
+	if monitored != nil && *monitored {
+		conditions["monitor"] = map[string]interface{}{conditionExists: true}
+	} else if monitored != nil {
+		conditions["monitor"] = nil
+	}
+	return conditions, nil
+}
+
+// newDestinations creates new destinations for the xpub (stops at the first error)
 // app: the app
 // xpubKey: the xpub key
-func newDestination(ctx context.Context, app *App,
-	xpubKey, metadata string) (destination *Destination, err error) {
+func newDestinations(ctx context.Context, app *App, xpubKey string, count int, chain uint32,
+	destinationType string, monitor bool, metaData bux.Metadata) (destinations []*Destination, err error) {
 
-	destination = new(Destination)
+	destinations = make([]*Destination, 0, count)
+	for i := 0; i < count; i++ {
 
-	var xpub *bux.Xpub
-	xpub, err = app.bux.GetXpub(ctx, xpubKey)
-	if err != nil {
-		return
+		// Create the destination
+		destination := new(Destination)
+		if destination.Bux, err = app.backend.NewDestination(
+			ctx, xpubKey, chain, destinationType, monitor, metaData,
+		); err != nil {
+			return
+		}
+		destinations = append(destinations, destination)
 	}
-	if xpub == nil {
-		err = errors.New("xpub not found")
+
+	return
+}
+
+// listDestinations gets a page of destinations for the xpub and the total count matching the filters
+func listDestinations(ctx context.Context, app *App, xpubID string, metaData bux.Metadata,
+	conditions map[string]interface{},
+	queryParams *datastore.QueryParams) (destinations []*Destination, total int64, err error) {
+
+	// Get the destinations
+	var results []*bux.Destination
+	if results, err = app.backend.GetDestinations(
+		ctx, xpubID, metadataConditions(metaData), conditions, queryParams,
+	); err != nil {
 		return
 	}
+	destinations = make([]*Destination, 0, len(results))
+	for _, result := range results {
+		destinations = append(destinations, &Destination{Bux: result})
+	}
+
+	// Get the total count
+	total, err = app.backend.GetDestinationsCount(ctx, xpubID, metadataConditions(metaData), conditions)
+	return
+}
 
-	// Get the metadata if provided
-	modelOps := app.bux.DefaultModelOptions()
-	if len(metadata) > 0 {
-		modelOps = append(modelOps, bux.WithMetadataFromJSON([]byte(metadata)))
+// getAllDestinations gets all the destinations for the xpub (page by page)
+func getAllDestinations(ctx context.Context, app *App, xpubID string) (destinations []*bux.Destination, err error) {
+	for currentPage := 1; ; currentPage++ {
+		var results []*bux.Destination
+		if results, err = app.backend.GetDestinations(
+			ctx, xpubID, nil, nil, allPagesQueryParams(currentPage),
+		); err != nil {
+			return
+		}
+		destinations = append(destinations, results...)
+		if len(results) < maxPageSize {
+			return
+		}
 	}
+}
 
-	// Create the destination
-	destination.Bux, err = app.bux.NewDestination(
-		ctx, xpubKey, utils.ChainExternal, utils.ScriptTypePubKeyHash, false, modelOps...,
-	)
+// updateDestinationMetadata updates the metadata of a destination by ID, address or locking script
+//
+// The metadata is merged, unless replace is set (the current metadata is needed to remove the other keys)
+func updateDestinationMetadata(ctx context.Context, app *App, idOrAddressOrScript, xpubID string,
+	metaData bux.Metadata, deleteKeys []string, replace bool) (destination *Destination, err error) {
+
+	// Get the current metadata (to replace it)
+	var current bux.Metadata
+	if replace {
+		var existing *bux.Destination
+		if existing, err = app.backend.GetDestination(ctx, xpubID, idOrAddressOrScript); err != nil {
+			return
+		} else if existing == nil {
+			return nil, bux.ErrMissingDestination
+		}
+		current = existing.Metadata
+	}
 
+	// Update the metadata
+	var update bux.Metadata
+	if update, err = metadataUpdate(current, metaData, deleteKeys, replace); err != nil {
+		return
+	}
+	destination = new(Destination)
+	destination.Bux, err = app.backend.UpdateDestinationMetadata(ctx, xpubID, idOrAddressOrScript, update)
 	return
 }
 
+// displayAddresses will display only the addresses of the destinations
+//
+// The addresses are plain lines (IE: for scripts), or a list for the structured formats (json and yaml)
+func displayAddresses(w io.Writer, format string, destinations []*Destination) error {
+	addresses := make([]string, 0, len(destinations))
+	for _, destination := range destinations {
+		if destination != nil && destination.Bux != nil {
+			addresses = append(addresses, destination.Bux.Address)
+		}
+	}
+	if format == outputFormatJSON || format == outputFormatYAML {
+		return renderModel(w, format, addresses)
+	}
+	for _, address := range addresses {
+		if _, err := fmt.Fprintln(w, address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // getDestination gets a destination by ID, address or locking script
 // app: the app
 // idOrAddressOrScript: the destination ID, address or locking script
@@ -166,33 +456,21 @@ func getDestination(ctx context.Context, app *App, idOrAddressOrScript, xpubID s
 	destination = new(Destination)
 
 	// Get the destination by ID, address or locking script
-	destination.Bux, err = app.bux.GetDestinationByID(ctx, xpubID, idOrAddressOrScript)
-	if err != nil && !errors.Is(err, bux.ErrMissingDestination) {
+	if destination.Bux, err = app.backend.GetDestination(ctx, xpubID, idOrAddressOrScript); err != nil {
 		return
 	}
 
-	// If destination is nil, try to get it by address or locking script
-	if destination.Bux == nil {
-		destination.Bux, err = app.bux.GetDestinationByAddress(ctx, xpubID, idOrAddressOrScript)
-		if err != nil && errors.Is(err, bux.ErrMissingDestination) {
-			destination.Bux, err = app.bux.GetDestinationByLockingScript(ctx, xpubID, idOrAddressOrScript)
-			if err != nil {
-				err = errors.New("error finding destination: " + err.Error())
-			}
-		}
-	}
-
 	// If destination is not nil and WhatsOnChain is enabled, get the address data
 	if destination.Bux != nil && len(destination.Bux.Address) > 0 && wocEnabled {
 
 		// Get the address info from WhatsOnChain
-		destination.WOCInfo, err = app.bux.Chainstate().WhatsOnChain().AddressInfo(ctx, destination.Bux.Address)
+		destination.WOCInfo, err = app.WhatsOnChain().AddressInfo(ctx, destination.Bux.Address)
 		if err != nil {
 			return
 		}
 
 		// Get the balance from WhatsOnChain
-		destination.WOCBalance, err = app.bux.Chainstate().WhatsOnChain().AddressBalance(ctx, destination.Bux.Address)
+		destination.WOCBalance, err = app.WhatsOnChain().AddressBalance(ctx, destination.Bux.Address)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDestinationChain(t *testing.T) {
+	t.Parallel()
+
+	for value, expected := range map[string]uint32{
+		"external": utils.ChainExternal, "Internal": utils.ChainInternal, "0": utils.ChainExternal, "1": utils.ChainInternal,
+	} {
+		chain, err := parseDestinationChain(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, chain, value)
+	}
+
+	_, err := parseDestinationChain("change")
+	require.ErrorIs(t, err, ErrInvalidDestinationChain)
+}
+
+func TestParseDestinationType(t *testing.T) {
+	t.Parallel()
+
+	for value, expected := range map[string]string{
+		"p2pkh": utils.ScriptTypePubKeyHash, "P2PK": utils.ScriptTypePubKey, "multisig": utils.ScriptTypeMultiSig,
+		utils.ScriptTypePubKeyHash: utils.ScriptTypePubKeyHash,
+	} {
+		scriptType, err := parseDestinationType(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, scriptType, value)
+	}
+
+	_, err := parseDestinationType("p2sh")
+	require.ErrorIs(t, err, ErrInvalidDestinationType)
+}
+
+func TestNewDestinationType(t *testing.T) {
+	t.Parallel()
+
+	scriptType, err := newDestinationType("p2pkh")
+	require.NoError(t, err)
+	assert.Equal(t, utils.ScriptTypePubKeyHash, scriptType)
+
+	// The other types are only list filters
+	for _, value := range []string{"p2pk", "multisig", "nonstandard", "stas"} {
+		_, err = newDestinationType(value)
+		require.ErrorIs(t, err, bux.ErrUnsupportedDestinationType, value)
+		assert.Equal(t, exitCodeNotSupported, exitCode(err), value)
+	}
+
+	_, err = newDestinationType("p2sh")
+	require.ErrorIs(t, err, ErrInvalidDestinationType)
+}
+
+func TestDestinationConditions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("all", func(t *testing.T) {
+		conditions, err := destinationConditions("", "", nil)
+		require.NoError(t, err)
+		assert.Empty(t, conditions)
+	})
+
+	t.Run("chain, type and monitored", func(t *testing.T) {
+		monitored := true
+		conditions, err := destinationConditions("internal", "p2pkh", &monitored)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"chain":   utils.ChainInternal,
+			"monitor": map[string]interface{}{conditionExists: true},
+			"type":    utils.ScriptTypePubKeyHash,
+		}, conditions)
+	})
+
+	t.Run("not monitored", func(t *testing.T) {
+		monitored := false
+		conditions, err := destinationConditions("", "", &monitored)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"monitor": nil}, conditions)
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		_, err := destinationConditions("change", "", nil)
+		require.ErrorIs(t, err, ErrInvalidDestinationChain)
+		_, err = destinationConditions("", "unknown", nil)
+		require.ErrorIs(t, err, ErrInvalidDestinationType)
+	})
+}
+
+func TestDisplayAddresses(t *testing.T) {
+	t.Parallel()
+
+	destinations := []*Destination{
+		{Bux: &bux.Destination{Address: "1CfaQw9udYNPccssFJFZ94DN8MqNZm9nGt"}},
+		{Bux: &bux.Destination{Address: "1HfBZmr1tH4JPBChpaA5BZbMxNWG4R6UHP"}},
+	}
+
+	t.Run("one per line", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, displayAddresses(&b, outputFormatPretty, destinations))
+		assert.Equal(t, "1CfaQw9udYNPccssFJFZ94DN8MqNZm9nGt\n1HfBZmr1tH4JPBChpaA5BZbMxNWG4R6UHP\n", b.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, displayAddresses(&b, outputFormatJSON, destinations))
+		assert.JSONEq(t, `["1CfaQw9udYNPccssFJFZ94DN8MqNZm9nGt","1HfBZmr1tH4JPBChpaA5BZbMxNWG4R6UHP"]`, b.String())
+	})
+}
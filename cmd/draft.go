@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/fatih/color"
+	"github.com/mrz1836/go-datastore"
+	"github.com/spf13/cobra"
+)
+
+// commands for draft transactions
+const draftCommandCancel = "cancel"
+const draftCommandGet = "get"
+const draftCommandList = "list"
+const draftCommandName = "draft"
+
+// returnDraftCmd returns the draft transaction command
+func returnDraftCmd(app *App) (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
+		Use:   draftCommandName,
+		Short: "list, inspect and cancel draft transactions in BUX",
+		Long: color.GreenString(`
+________    __________     _____    ___________ ___________
+\______ \   \______   \   /  _  \   \_   _____/ \__    ___/
+ |    |  \   |       _/  /  /_\  \   |    __)     |    |
+ |    '   \  |    |   \ /    |    \  |     \      |    |
+/_______  /  |____|_  / \____|__  /  \___  /      |____|
+        \/          \/          \/       \/`) + `
+` + color.YellowString(`
+This command is for draft transaction related commands.
+A draft reserves its utxos until it is recorded, canceled or expired (by the draft clean up task).
+
+list: returns the draft transactions for an xpub (`+draftCommandName+` `+draftCommandList+` <xpub_id> --status=draft --expired)
+get: gets a draft transaction, the configuration and the reserved utxos (`+draftCommandName+` `+draftCommandGet+` <draft_id>)
+cancel: cancels an open draft transaction and releases the reserved utxos (`+draftCommandName+` `+draftCommandCancel+` <draft_id>)
+`),
+		Aliases: []string{"drafts"},
+		Example: applicationName + " " + draftCommandName + " " + draftCommandList + " <xpub_id>",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("%s %w, IE: %s, etc.", draftCommandName, ErrSubcommandIsRequired, draftCommandList)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Initialize the BUX client
+			deferFunc, err := app.InitializeBUX()
+			if err != nil {
+				return err
+			}
+			defer deferFunc()
+
+			// Parse Metadata
+			var metaData bux.Metadata
+			if metaData, err = getMetadataFlag(cmd); err != nil {
+				return err
+			}
+
+			// Switch on the subcommand
+			if args[0] == draftCommandList { // List the draft transactions
+
+				// Check if xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubIDIsRequired
+				}
+
+				// Build the filters and the pagination
+				var conditions map[string]interface{}
+				if conditions, err = draftConditions(draftStatus, draftExpired, time.Now()); err != nil {
+					return err
+				}
+				var queryParams *datastore.QueryParams
+				if queryParams, err = newQueryParams(page, pageSize, orderBy, sortDirection); err != nil {
+					return err
+				}
+
+				// Get the draft transactions
+				var drafts []*bux.DraftTransaction
+				var total int64
+				if drafts, total, err = listDrafts(
+					context.Background(), app, args[1], metaData, conditions, queryParams,
+				); err != nil {
+					return fmt.Errorf("error getting draft transactions: %w", err)
+				}
+
+				// Display the draft transactions
+				displayPage(queryParams, len(drafts), total)
+				return displayModel(drafts)
+
+			} else if args[0] == draftCommandGet { // Get a draft transaction
+
+				// Check if draft id is provided
+				if len(args) < 2 {
+					return ErrDraftIDIsRequired
+				}
+
+				// Get the draft and the reserved utxos
+				var details *DraftDetails
+				if details, err = getDraftDetails(context.Background(), app, args[1], time.Now()); err != nil {
+					return fmt.Errorf("error getting draft transaction: %w", err)
+				}
+
+				// Display the draft transaction
+				return displayModel(details)
+
+			} else if args[0] == draftCommandCancel { // Cancel a draft transaction
+
+				// Check if draft id is provided
+				if len(args) < 2 {
+					return ErrDraftIDIsRequired
+				}
+
+				// Count the reserved utxos (before they are released)
+				var details *DraftDetails
+				if details, err = getDraftDetails(context.Background(), app, args[1], time.Now()); err != nil {
+					return fmt.Errorf("error getting draft transaction: %w", err)
+				}
+
+				// Cancel the draft (releases the utxos)
+				var draft *bux.DraftTransaction
+				if draft, err = app.backend.CancelDraftTransaction(context.Background(), args[1]); err != nil {
+					return fmt.Errorf("error canceling draft transaction: %w", err)
+				}
+
+				// Display the canceled draft
+				chalker.LogTo(os.Stderr, chalker.SUCCESS, fmt.Sprintf(
+					"Draft %s canceled, %d utxo(s) released", draft.ID, len(details.ReservedUtxos)))
+				return displayModel(draft)
+			}
+
+			return ErrUnknownSubcommand
+		},
+	}
+
+	// Set the metadata flag
+	addMetadataFlag(newCmd)
+
+	// Set the list filters
+	newCmd.Flags().StringVar(&draftStatus, flagStatus, "", "Filter by status: "+strings.Join([]string{
+		string(bux.DraftStatusDraft), string(bux.DraftStatusCanceled),
+		string(bux.DraftStatusExpired), string(bux.DraftStatusComplete),
+	}, ", "))
+	newCmd.Flags().BoolVar(&draftExpired, flagExpired, false,
+		"Only drafts that are past the expiry (open drafts still reserve the utxos until cleaned up)")
+	addPaginationFlags(newCmd)
+
+	return
+}
+
+// draftConditions will build the conditions for listing draft transactions from the status and expiry filters
+func draftConditions(status string, expired bool, now time.Time) (map[string]interface{}, error) {
+	conditions := make(map[string]interface{})
+	switch bux.DraftStatus(status) {
+	case "":
+	case bux.DraftStatusDraft, bux.DraftStatusCanceled, bux.DraftStatusExpired, bux.DraftStatusComplete:
+		conditions["status"] = status
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDraftStatus, status)
+	}
+	if expired {
+		conditions["expires_at"] = map[string]interface{}{conditionLessEq: nullTime(now)}
+	}
+	return conditions, nil
+}
+
+// getDraftDetails gets a draft transaction by ID and the utxos that are reserved by the draft
+func getDraftDetails(ctx context.Context, app *App, id string, now time.Time) (*DraftDetails, error) {
+	draft, err := app.backend.GetDraftTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	details := &DraftDetails{
+		Draft:   draft,
+		Expired: draft.Status == bux.DraftStatusDraft && !draft.ExpiresAt.IsZero() && draft.ExpiresAt.Before(now),
+	}
+	if details.ReservedUtxos, err = getAllUtxos(
+		ctx, app, draft.XpubID, nil, map[string]interface{}{"draft_id": draft.ID},
+	); err != nil {
+		return nil, fmt.Errorf("error getting reserved utxos: %w", err)
+	}
+	if details.ReservedUtxos == nil {
+		details.ReservedUtxos = make([]*bux.Utxo, 0)
+	}
+	return details, nil
+}
+
+// listDrafts gets a page of draft transactions for the xpub and the total count matching the filters
+func listDrafts(ctx context.Context, app *App, xpubID string, metaData bux.Metadata,
+	conditions map[string]interface{},
+	queryParams *datastore.QueryParams) (drafts []*bux.DraftTransaction, total int64, err error) {
+
+	// Get the draft transactions
+	if drafts, err = app.backend.GetDraftTransactions(
+		ctx, xpubID, metadataConditions(metaData), conditions, queryParams,
+	); err != nil {
+		return
+	}
+
+	// Get the total count
+	total, err = app.backend.GetDraftTransactionsCount(ctx, xpubID, metadataConditions(metaData), conditions)
+	return
+}
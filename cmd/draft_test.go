@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BuxOrg/bux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDraftConditions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("all", func(t *testing.T) {
+		conditions, err := draftConditions("", false, now)
+		require.NoError(t, err)
+		assert.Empty(t, conditions)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		conditions, err := draftConditions(string(bux.DraftStatusCanceled), false, now)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"status": string(bux.DraftStatusCanceled)}, conditions)
+	})
+
+	t.Run("open and expired", func(t *testing.T) {
+		conditions, err := draftConditions(string(bux.DraftStatusDraft), true, now)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"expires_at": map[string]interface{}{conditionLessEq: nullTime(now)},
+			"status":     string(bux.DraftStatusDraft),
+		}, conditions)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := draftConditions("revoked", false, now)
+		require.ErrorIs(t, err, ErrInvalidDraftStatus)
+	})
+}
@@ -1,6 +1,12 @@
 package cmd
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+)
 
 // ErrModelIsNil is returned when a model is nil
 var ErrModelIsNil = errors.New("model is nil")
@@ -11,8 +17,20 @@ var ErrModeIsRequired = errors.New("mode is required")
 // ErrUnknownMode is returned when a mode is unknown
 var ErrUnknownMode = errors.New("unknown mode")
 
-// ErrServerModeIsNotImplemented is returned when a server mode is not implemented
-var ErrServerModeIsNotImplemented = errors.New("server mode is not implemented")
+// ErrServerURLIsRequired is returned when the server url is missing in server mode
+var ErrServerURLIsRequired = errors.New("server url is required in server mode")
+
+// ErrServerAuthIsRequired is returned when no server authentication (xpriv, xpub or access key) is found
+var ErrServerAuthIsRequired = errors.New("server authentication is required (xpriv, xpub or access key)")
+
+// ErrServerXpubMismatch is returned when the xpub (or xpub id) is not the xpub that is authenticated on the server
+var ErrServerXpubMismatch = errors.New("xpub id does not match the server authentication")
+
+// ErrServerAdminKeyIsRequired is returned when an admin request is made without the admin key
+var ErrServerAdminKeyIsRequired = errors.New("server admin key is required for this command")
+
+// ErrNotSupportedInServerMode is returned when a command is only available in database mode
+var ErrNotSupportedInServerMode = errors.New("command is not supported in server mode")
 
 // ErrFailedToLoadBux is returned when bux fails to load
 var ErrFailedToLoadBux = errors.New("failed to load bux")
@@ -26,11 +44,300 @@ var ErrXpubIDIsRequired = errors.New("xpub id is required")
 // ErrXprivIsRequired is returned when a xpriv is required
 var ErrXprivIsRequired = errors.New("xpriv is required")
 
+// ErrMnemonicIsRequired is returned when a mnemonic is required
+var ErrMnemonicIsRequired = errors.New("mnemonic is required")
+
+// ErrInvalidMnemonicWords is returned when the number of words for the mnemonic is not allowed
+var ErrInvalidMnemonicWords = errors.New("invalid number of mnemonic words, use 12, 15, 18, 21 or 24")
+
+// ErrInvalidMnemonic is returned when the mnemonic has an unknown word or the checksum does not match
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// ErrInvalidXpriv is returned when the xpriv cannot be parsed (or is not a private key)
+var ErrInvalidXpriv = errors.New("invalid xpriv")
+
+// ErrKeyNameIsRequired is returned when the keystore key name is required
+var ErrKeyNameIsRequired = errors.New("key name is required")
+
+// ErrInvalidKeyName is returned when the keystore key name is not allowed
+var ErrInvalidKeyName = errors.New("invalid key name")
+
+// ErrKeyAlreadyExists is returned when the keystore already has a key with the name
+var ErrKeyAlreadyExists = errors.New("key already exists in the keystore")
+
+// ErrKeyNotFound is returned when the key is not found in the keystore
+var ErrKeyNotFound = errors.New("key not found in the keystore")
+
+// ErrKeyPassphraseIsRequired is returned when the keystore passphrase is missing
+var ErrKeyPassphraseIsRequired = errors.New("keystore passphrase is required")
+
+// ErrKeyPassphraseMismatch is returned when the passphrase confirmation does not match
+var ErrKeyPassphraseMismatch = errors.New("keystore passphrases do not match")
+
+// ErrInvalidKeyPassphrase is returned when the key cannot be decrypted with the passphrase
+var ErrInvalidKeyPassphrase = errors.New("invalid keystore passphrase")
+
+// ErrInvalidDerivationPath is returned when the derivation path cannot be parsed
+var ErrInvalidDerivationPath = errors.New("invalid derivation path, use the format: m/0/5")
+
+// ErrProfileIsRequired is when the config profile is missing
+var ErrProfileIsRequired = errors.New("profile name is required")
+
+// ErrInvalidProfileName is when the config profile name is invalid
+var ErrInvalidProfileName = errors.New("invalid profile name")
+
+// ErrProfileNotFound is when the config profile does not exist
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ErrProfileAlreadyExists is when creating a config profile that already exists
+var ErrProfileAlreadyExists = errors.New("profile already exists")
+
+// ErrInvalidConfig is when the config is missing required values
+var ErrInvalidConfig = errors.New("invalid config")
+
+// ErrUnknownMiner is when a miner in the config is not a known or custom miner
+var ErrUnknownMiner = errors.New("unknown miner")
+
+// ErrMinerUnreachable is when a miner did not return a fee quote or policy (see: miners check)
+var ErrMinerUnreachable = errors.New("miner is unreachable")
+
+// ErrFailedToReadConfig is returned when the config file cannot be read
+var ErrFailedToReadConfig = errors.New("failed to read config")
+
+// ErrInvalidPage is returned when the page is not valid
+var ErrInvalidPage = errors.New("invalid page, must be 1 or greater")
+
+// ErrInvalidPageSize is returned when the page size is not valid
+var ErrInvalidPageSize = errors.New("invalid page size")
+
+// ErrInvalidSortDirection is returned when the sort direction is not valid
+var ErrInvalidSortDirection = errors.New("invalid sort direction")
+
+// ErrInvalidDate is returned when a date cannot be parsed
+var ErrInvalidDate = errors.New("invalid date")
+
+// ErrInvalidRange is returned when the start of a range is after the end
+var ErrInvalidRange = errors.New("invalid range")
+
+// ErrInvalidTransactionStatus is returned when the transaction status filter is not valid
+var ErrInvalidTransactionStatus = errors.New("invalid transaction status")
+
+// ErrInvalidTransactionDirection is returned when the transaction direction filter is not valid
+var ErrInvalidTransactionDirection = errors.New("invalid transaction direction")
+
+// ErrInvalidUtxoStatus is returned when the utxo status filter is not valid
+var ErrInvalidUtxoStatus = errors.New("invalid utxo status")
+
+// ErrInvalidUtxoPointer is returned when the utxo is not in the format <txid:vout>
+var ErrInvalidUtxoPointer = errors.New("invalid utxo, use the format: <txid:vout>")
+
+// ErrUtxoIsRequired is returned when the utxo (txid:vout) is required
+var ErrUtxoIsRequired = errors.New("utxo is required, use the format: <txid:vout>")
+
+// ErrMetadataIsRequired is returned when the metadata is required
+var ErrMetadataIsRequired = errors.New("metadata is required, IE: -m='{\"key\":\"value\"}'")
+
+// ErrPaymailIsRequired is returned when the paymail address is required
+var ErrPaymailIsRequired = errors.New("paymail address is required, IE: alias@domain.com")
+
+// ErrSubcommandIsRequired is returned when a command is called without a subcommand
+var ErrSubcommandIsRequired = errors.New("requires a subcommand")
+
+// ErrDestinationIsRequired is returned when a destination (id, address or locking script) is required
+var ErrDestinationIsRequired = errors.New("destination id, address or locking script is required")
+
+// ErrAccessKeyIsRequired is returned when the access key id is required
+var ErrAccessKeyIsRequired = errors.New("access key id is required")
+
+// ErrInvalidAccessKeyStatus is returned when the access key status filter is not valid
+var ErrInvalidAccessKeyStatus = errors.New("invalid access key status")
+
+// ErrTransactionIDIsRequired is returned when the transaction id is required
+var ErrTransactionIDIsRequired = errors.New("transaction id is required, use -i=<tx_id>")
+
+// ErrTransactionConfigIsRequired is returned when there is nothing to send in the transaction
+var ErrTransactionConfigIsRequired = errors.New("transaction config is required, use --to, --op-return or --txconfig")
+
+// ErrInvalidTransactionConfig is returned when the transaction config (or the flags) are not valid
+var ErrInvalidTransactionConfig = errors.New("invalid transaction config")
+
+// ErrInvalidRecipient is returned when the recipient is not an address, paymail, $handle or locking script
+var ErrInvalidRecipient = errors.New("invalid recipient, use an address, paymail, $handle or locking script (hex)")
+
+// ErrInvalidSatoshis is returned when the satoshis for the recipients are not valid
+var ErrInvalidSatoshis = errors.New("invalid satoshis")
+
+// ErrInvalidFeePerByte is returned when the fee per byte is not valid
+var ErrInvalidFeePerByte = errors.New("invalid fee per byte")
+
+// ErrNoTasksFound is returned when no tasks are registered in BUX
+var ErrNoTasksFound = errors.New("no tasks found")
+
+// ErrUnknownTask is returned when a task (--tasks) is not registered in BUX
+var ErrUnknownTask = errors.New("unknown task")
+
+// ErrTaskFailed is returned when one or more tasks failed (worker --once)
+var ErrTaskFailed = errors.New("task failed")
+
+// ErrInvalidDestinationChain is returned when the destination chain is not internal or external
+var ErrInvalidDestinationChain = errors.New("invalid destination chain")
+
+// ErrInvalidDestinationType is returned when the destination type (locking script type) is unknown
+var ErrInvalidDestinationType = errors.New("invalid destination type")
+
+// ErrInvalidCount is returned when the number of models to create is out of range
+var ErrInvalidCount = errors.New("invalid count")
+
+// ErrInvalidFlag is returned when a flag cannot be parsed
+var ErrInvalidFlag = errors.New("invalid flag")
+
 // ErrUnknownSubcommand is returned when a subcommand is unknown
 var ErrUnknownSubcommand = errors.New("unknown subcommand")
 
 // ErrXpubOrXpubIDIsRequired is returned when a xpub or xpub id is required
 var ErrXpubOrXpubIDIsRequired = errors.New("xpub or xpub id is required")
 
+// ErrXpubNotFound is returned when a xpub is not found
+var ErrXpubNotFound = errors.New("xpub not found")
+
 // ErrNoXpubsFound is returned when no xpubs are found
 var ErrNoXpubsFound = errors.New("no xpubs found")
+
+// ErrUnknownOutputFormat is returned when the output format is not supported
+var ErrUnknownOutputFormat = errors.New("unknown output format")
+
+// ErrDraftFileIsRequired is returned when the draft file (exported with transaction new --out) is missing
+var ErrDraftFileIsRequired = errors.New("draft file is required")
+
+// ErrInvalidDraft is returned when a draft transaction cannot be signed (IE: invalid hex or inputs, not open)
+var ErrInvalidDraft = errors.New("invalid draft transaction")
+
+// ErrDraftKeyMismatch is returned when the draft inputs do not belong to the signing key
+var ErrDraftKeyMismatch = errors.New("draft inputs do not belong to the key")
+
+// ErrDraftIDIsRequired is returned when the draft transaction id is required
+var ErrDraftIDIsRequired = errors.New("draft id is required")
+
+// ErrInvalidDraftStatus is returned when the draft status filter is not valid
+var ErrInvalidDraftStatus = errors.New("invalid draft status")
+
+// ErrTransactionIDOrHexIsRequired is returned when recording without a transaction id, hex or hex file
+var ErrTransactionIDOrHexIsRequired = errors.New("transaction id or hex is required, use -i=<tx_id>, " +
+	"--hex=<tx_hex> or --hex-file=<file>")
+
+// ErrTransactionIsRequired is returned when the transaction (hex or txid) to decode is missing
+var ErrTransactionIsRequired = errors.New("transaction hex or txid is required")
+
+// ErrInvalidTransactionHex is returned when the transaction hex cannot be parsed
+var ErrInvalidTransactionHex = errors.New("invalid transaction hex")
+
+// ErrRecordFailed is returned when one or more transactions failed to record (bulk)
+var ErrRecordFailed = errors.New("failed to record transaction")
+
+// Exit codes for the application (returned to the shell when a command fails)
+//
+//	0  success
+//	1  general error (not classified below)
+//	2  usage error: missing or unknown subcommand, argument or flag
+//	3  configuration error: missing or invalid config (mode, server url, credentials, task manager, profile)
+//	4  connection error: BUX could not be loaded, or the server is unavailable
+//	5  not found: xpub, destination, transaction, utxo, draft, access key or keystore key does not exist
+//	6  invalid input: the data was rejected (IE: invalid hex, locking script, paymail or keystore passphrase)
+//	7  insufficient funds: not enough utxos to fund the transaction
+//	8  not supported: the command is not available in the current mode
+//	9  unauthorized: the server rejected the credentials
+const (
+	exitCodeSuccess           = 0
+	exitCodeGeneral           = 1
+	exitCodeUsage             = 2
+	exitCodeConfig            = 3
+	exitCodeConnection        = 4
+	exitCodeNotFound          = 5
+	exitCodeInvalidInput      = 6
+	exitCodeInsufficientFunds = 7
+	exitCodeNotSupported      = 8
+	exitCodeUnauthorized      = 9
+)
+
+// exitCodes maps the known errors to their exit code
+var exitCodes = []struct {
+	code int
+	errs []error
+}{
+	{exitCodeUsage, []error{
+		ErrAccessKeyIsRequired, ErrDestinationIsRequired, ErrDraftFileIsRequired, ErrDraftIDIsRequired,
+		ErrInvalidAccessKeyStatus, ErrInvalidCount, ErrInvalidDate, ErrInvalidDestinationChain,
+		ErrInvalidDestinationType, ErrInvalidDraftStatus, ErrInvalidFeePerByte, ErrInvalidFlag,
+		ErrInvalidMnemonicWords, ErrInvalidPage, ErrInvalidPageSize, ErrInvalidProfileName, ErrInvalidRange,
+		ErrInvalidRecipient, ErrInvalidSatoshis, ErrInvalidSortDirection, ErrInvalidTransactionConfig,
+		ErrInvalidTransactionDirection, ErrInvalidTransactionStatus, ErrInvalidUtxoPointer,
+		ErrInvalidUtxoStatus, ErrMetadataIsRequired, ErrMnemonicIsRequired, ErrPaymailIsRequired,
+		ErrProfileIsRequired, ErrSubcommandIsRequired, ErrTransactionConfigIsRequired,
+		ErrTransactionIDIsRequired, ErrTransactionIDOrHexIsRequired, ErrTransactionIsRequired,
+		ErrUnknownOutputFormat, ErrUnknownSubcommand, ErrUnknownTask, ErrUtxoIsRequired, ErrXprivIsRequired,
+		ErrXpubIDIsRequired, ErrXpubIsRequired, ErrXpubOrXpubIDIsRequired,
+	}},
+	{exitCodeConfig, []error{
+		ErrFailedToReadConfig, ErrInvalidConfig, ErrModeIsRequired, ErrNoTasksFound, ErrProfileNotFound,
+		ErrServerAdminKeyIsRequired, ErrServerAuthIsRequired, ErrServerURLIsRequired, ErrUnknownMiner,
+		ErrUnknownMode, bux.ErrTaskManagerNotLoaded,
+	}},
+	{exitCodeConnection, []error{
+		ErrFailedToLoadBux, ErrMinerUnreachable,
+	}},
+	{exitCodeNotFound, []error{
+		ErrKeyNotFound, ErrNoXpubsFound, ErrXpubNotFound, bux.ErrDraftNotFound, bux.ErrMissingAccessKey,
+		bux.ErrMissingDestination, bux.ErrMissingPaymail, bux.ErrMissingRequiredXpub, bux.ErrMissingTransaction,
+		bux.ErrMissingUtxo, bux.ErrMissingXpub, bux.ErrUnknownAccessKey,
+	}},
+	{exitCodeInvalidInput, []error{
+		ErrDraftKeyMismatch, ErrInvalidDraft, ErrInvalidKeyPassphrase, ErrInvalidMnemonic,
+		ErrInvalidTransactionHex, ErrInvalidXpriv, ErrKeyAlreadyExists, ErrProfileAlreadyExists,
+		bux.ErrInvalidLockingScript, bux.ErrInvalidOpReturnOutput, bux.ErrInvalidScriptOutput,
+		bux.ErrInvalidTransactionID, bux.ErrMissingFieldHex, bux.ErrMissingTransactionOutputs,
+		bux.ErrMissingTxHex, bux.ErrOutputValueTooHigh, bux.ErrOutputValueTooLow,
+		bux.ErrPaymailAddressIsInvalid, bux.ErrTransactionFeeInvalid, bux.ErrUnknownLockingScript,
+		utils.ErrXpubInvalidLength, utils.ErrXpubNoMatch,
+	}},
+	{exitCodeInsufficientFunds, []error{
+		bux.ErrMissingUTXOsSpendable, bux.ErrNotEnoughUtxos,
+	}},
+	{exitCodeNotSupported, []error{
+		ErrNotSupportedInServerMode, bux.ErrUnsupportedDestinationType,
+	}},
+	{exitCodeUnauthorized, []error{
+		ErrServerXpubMismatch,
+	}},
+}
+
+// exitCode will return the exit code for the error (see the table above)
+func exitCode(err error) int {
+	if err == nil {
+		return exitCodeSuccess
+	}
+
+	// Errors from the BUX server are mapped using the status code
+	var srvErr *serverError
+	if errors.As(err, &srvErr) {
+		switch {
+		case srvErr.StatusCode == http.StatusUnauthorized || srvErr.StatusCode == http.StatusForbidden:
+			return exitCodeUnauthorized
+		case srvErr.StatusCode == http.StatusNotFound:
+			return exitCodeNotFound
+		case srvErr.StatusCode >= http.StatusInternalServerError:
+			return exitCodeConnection
+		case srvErr.StatusCode >= http.StatusBadRequest:
+			return exitCodeInvalidInput
+		}
+	}
+
+	// Known errors
+	for _, mapping := range exitCodes {
+		for _, target := range mapping.errs {
+			if errors.Is(err, target) {
+				return mapping.code
+			}
+		}
+	}
+	return exitCodeGeneral
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"no error", nil, exitCodeSuccess},
+		{"unknown error", errors.New("something went wrong"), exitCodeGeneral},
+		{"missing subcommand", fmt.Errorf("%s %w", xpubCommandName, ErrSubcommandIsRequired), exitCodeUsage},
+		{"invalid flag", fmt.Errorf("%w: unknown flag: --foo", ErrInvalidFlag), exitCodeUsage},
+		{"unknown output format", ErrUnknownOutputFormat, exitCodeUsage},
+		{"missing mode", ErrModeIsRequired, exitCodeConfig},
+		{"missing server url", ErrServerURLIsRequired, exitCodeConfig},
+		{"failed to load bux", fmt.Errorf("%w: connection refused", ErrFailedToLoadBux), exitCodeConnection},
+		{"missing destination", fmt.Errorf("error getting destination: %w", bux.ErrMissingDestination), exitCodeNotFound},
+		{"xpub not found", ErrXpubNotFound, exitCodeNotFound},
+		{"not enough utxos", fmt.Errorf("error creating draft transaction: %w", bux.ErrNotEnoughUtxos), exitCodeInsufficientFunds},
+		{"not supported", ErrNotSupportedInServerMode, exitCodeNotSupported},
+		{"server unauthorized", &serverError{StatusCode: http.StatusUnauthorized}, exitCodeUnauthorized},
+		{"server not found", fmt.Errorf("error getting xpub: %w", &serverError{StatusCode: http.StatusNotFound}), exitCodeNotFound},
+		{"server bad request", &serverError{StatusCode: http.StatusBadRequest}, exitCodeInvalidInput},
+		{"server unavailable", &serverError{StatusCode: http.StatusBadGateway}, exitCodeConnection},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, exitCode(test.err))
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/bitcoinschema/go-bitcoin/v2"
+	"github.com/fatih/color"
+	"github.com/libsv/go-bk/bip32"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// commands for keystore
+const keystoreCommandName = "keystore"
+const keystoreCommandAdd = "add"
+const keystoreCommandList = "list"
+const keystoreCommandRemove = "remove"
+const keystoreCommandExport = "export"
+
+// Keystore settings (the file is stored in the application directory)
+const (
+	keystoreFileName = "keystore.json" // File name of the keystore
+	keystoreKDF      = "scrypt"        // Key derivation function for the passphrase
+	keystoreKeyLen   = 32              // AES-256
+	keystoreSaltLen  = 16              // Random salt for each key
+	keystoreScryptN  = 1 << 15         // Scrypt CPU/memory cost
+	keystoreScryptP  = 1               // Scrypt parallelization
+	keystoreScryptR  = 8               // Scrypt block size
+	keystoreVersion  = 1               // Version of the keystore file format
+)
+
+// ttyPath is the controlling terminal, the passphrase is prompted on it when stdin is piped
+const ttyPath = "/dev/tty"
+
+// keyPassphraseEnv is the environment variable for the keystore passphrase (instead of the prompt)
+var keyPassphraseEnv = strings.ToUpper(applicationName) + "_KEY_PASSPHRASE"
+
+// keyNameRegex is the allowed format for the key names
+var keyNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// keystore is the local file with the encrypted xprivs
+type keystore struct {
+	Keys    []*keystoreEntry `json:"keys"`
+	Version int              `json:"version"`
+}
+
+// keystoreEntry is an encrypted xpriv in the keystore
+type keystoreEntry struct {
+	KeystoreKey
+	Ciphertext string          `json:"ciphertext"` // AES-GCM encrypted xpriv (hex), the name is the additional data
+	KDF        string          `json:"kdf"`        // Key derivation function (scrypt)
+	KDFParams  *keystoreParams `json:"kdf_params"` // Key derivation parameters
+	Nonce      string          `json:"nonce"`      // AES-GCM nonce (hex)
+}
+
+// keystoreParams are the scrypt parameters used to derive the encryption key from the passphrase
+type keystoreParams struct {
+	N    int    `json:"n"`
+	P    int    `json:"p"`
+	R    int    `json:"r"`
+	Salt string `json:"salt"` // hex
+}
+
+// returnKeystoreCmd returns the keystore command
+func returnKeystoreCmd() (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
+		Use:   keystoreCommandName,
+		Short: "store xpriv keys encrypted with a passphrase",
+		Long: color.GreenString(`
+ ____  __. ___________ _____.___.   _________ ___________ ________    __________  ___________
+|    |/ _| \_   _____/ \__  |   |  /   _____/ \__    ___/ \_____  \   \______   \ \_   _____/
+|      <    |    __)_   /   |   |  \_____  \    |    |     /   |   \   |       _/  |    __)_
+|    |  \   |        \  \____   |  /        \   |    |    /    |    \  |    |   \  |        \
+|____|__ \ /_______  /  / ______| /_______  /   |____|    \_______  /  |____|_  / /_______  /
+        \/         \/   \/                \/                      \/          \/          \/`) + `
+` + color.YellowString(`
+This command stores xpriv keys in a local keystore, encrypted with a passphrase (scrypt + AES-GCM).
+Use --`+flagKey+`=<name> on the commands that require an xpriv, so it is not in the shell history or process list.
+
+The passphrase is prompted (on the terminal, also when the xpriv is piped), or read from the `+keyPassphraseEnv+`
+environment variable (IE: in scripts without a terminal).
+
+add: adds an xpriv to the keystore, the xpriv is prompted or read from stdin (`+keystoreCommandName+` `+keystoreCommandAdd+` <name>)
+list: lists the keys in the keystore (`+keystoreCommandName+` `+keystoreCommandList+`)
+remove: removes a key from the keystore (`+keystoreCommandName+` `+keystoreCommandRemove+` <name>)
+export: decrypts a key and shows the xpriv, xpub and WIF (`+keystoreCommandName+` `+keystoreCommandExport+` <name>)
+`),
+		Aliases: []string{"keys"},
+		Example: applicationName + " " + xprivCommandName + " " + xprivCommandNew + " -o=json | jq -r .xpriv | " +
+			applicationName + " " + keystoreCommandName + " " + keystoreCommandAdd + " main",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("%s %w, IE: %s, etc.", keystoreCommandName, ErrSubcommandIsRequired,
+					keystoreCommandAdd+", "+keystoreCommandList+", "+keystoreCommandRemove+", "+keystoreCommandExport)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Load the keystore
+			path := keystorePath()
+			ks, err := loadKeystore(path)
+			if err != nil {
+				return err
+			}
+
+			// List does not require a key name
+			if args[0] == keystoreCommandList {
+				keys := make([]*KeystoreKey, 0, len(ks.Keys))
+				for _, entry := range ks.Keys {
+					keys = append(keys, &entry.KeystoreKey)
+				}
+				return displayModel(keys)
+			} else if args[0] != keystoreCommandAdd && args[0] != keystoreCommandRemove &&
+				args[0] != keystoreCommandExport {
+				return ErrUnknownSubcommand
+			}
+
+			// Check if the key name is provided
+			if len(args) < 2 {
+				return ErrKeyNameIsRequired
+			}
+			name := args[1]
+
+			// Switch on the subcommand
+			if args[0] == keystoreCommandAdd { // Add an xpriv to the keystore
+
+				// Check the name before asking for the secrets
+				if !keyNameRegex.MatchString(name) {
+					return fmt.Errorf("%w: %s (use letters, numbers, dash, dot or underscore)", ErrInvalidKeyName, name)
+				} else if ks.get(name) != nil {
+					return fmt.Errorf("%w: %s", ErrKeyAlreadyExists, name)
+				}
+
+				// Read the xpriv (prompt or stdin) and the passphrase
+				var xprivKey, passphrase string
+				if xprivKey, err = readXpriv(); err != nil {
+					return err
+				}
+				if passphrase, err = getKeyPassphrase(true); err != nil {
+					return err
+				}
+
+				// Encrypt and store the xpriv
+				var entry *keystoreEntry
+				if entry, err = newKeystoreEntry(name, xprivKey, passphrase); err != nil {
+					return err
+				}
+				ks.Keys = append(ks.Keys, entry)
+				if err = saveKeystore(path, ks); err != nil {
+					return err
+				}
+
+				// Display the stored key
+				return displayModel(&entry.KeystoreKey)
+			} else if args[0] == keystoreCommandRemove { // Remove a key from the keystore
+
+				// Find the key
+				entry := ks.get(name)
+				if entry == nil {
+					return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+				}
+
+				// Remove the key and save the keystore
+				ks.remove(name)
+				if err = saveKeystore(path, ks); err != nil {
+					return err
+				}
+
+				// Display the removed key
+				return displayModel(&entry.KeystoreKey)
+			}
+
+			// Export (decrypt) the key
+			var xprivKey string
+			if xprivKey, err = getKeystoreXpriv(ks, name); err != nil {
+				return err
+			}
+
+			// Get the keys from the xpriv
+			var hdKey *bip32.ExtendedKey
+			if hdKey, err = bitcoin.GenerateHDKeyFromString(xprivKey); err != nil {
+				return fmt.Errorf("%w: %s", ErrInvalidXpriv, err.Error())
+			}
+			var keys *Keys
+			if keys, err = newKeys(hdKey); err != nil {
+				return err
+			}
+
+			// Display the keys
+			chalker.LogTo(os.Stderr, chalker.WARN, "The xpriv is shown in plain text, do not share it with anyone")
+			return displayModel(keys)
+		},
+	}
+	return
+}
+
+// keystorePath will return the path of the keystore file (in the application directory)
+func keystorePath() string {
+	return filepath.Join(applicationDirectory, keystoreFileName)
+}
+
+// loadKeystore will read the keystore file (an empty keystore is returned if the file does not exist)
+func loadKeystore(path string) (*keystore, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // The path is the application directory
+	if errors.Is(err, os.ErrNotExist) {
+		return &keystore{Version: keystoreVersion}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading keystore: %w", err)
+	}
+
+	ks := new(keystore)
+	if err = json.Unmarshal(data, ks); err != nil {
+		return nil, fmt.Errorf("error parsing keystore %s: %w", path, err)
+	}
+	return ks, nil
+}
+
+// saveKeystore will write the keystore file (only readable by the user)
+//
+// The file is written to a temporary file first, so a failed write cannot corrupt the keystore
+func saveKeystore(path string, ks *keystore) error {
+	sort.Slice(ks.Keys, func(i, j int) bool { return ks.Keys[i].Name < ks.Keys[j].Name })
+	ks.Version = keystoreVersion
+
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding keystore: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("error writing keystore: %w", err)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error writing keystore: %w", err)
+	}
+	return nil
+}
+
+// get will return the key by name (nil if not found)
+func (k *keystore) get(name string) *keystoreEntry {
+	for _, entry := range k.Keys {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// remove will remove the key by name
+func (k *keystore) remove(name string) {
+	keys := k.Keys[:0]
+	for _, entry := range k.Keys {
+		if entry.Name != name {
+			keys = append(keys, entry)
+		}
+	}
+	k.Keys = keys
+}
+
+// newKeystoreEntry will validate the xpriv and encrypt it with the passphrase
+func newKeystoreEntry(name, xprivKey, passphrase string) (*keystoreEntry, error) {
+
+	// Validate the xpriv and get the xpub
+	hdKey, err := bitcoin.GenerateHDKeyFromString(strings.TrimSpace(xprivKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidXpriv, err.Error())
+	} else if !hdKey.IsPrivate() {
+		return nil, fmt.Errorf("%w: the key is not private (xpub)", ErrInvalidXpriv)
+	}
+	var xpub string
+	if xpub, err = bitcoin.GetExtendedPublicKey(hdKey); err != nil {
+		return nil, fmt.Errorf("error generating xpub from xpriv: %w", err)
+	}
+
+	// Derive the encryption key from the passphrase
+	params := &keystoreParams{N: keystoreScryptN, P: keystoreScryptP, R: keystoreScryptR}
+	salt := make([]byte, keystoreSaltLen)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating salt: %w", err)
+	}
+	params.Salt = hex.EncodeToString(salt)
+	var aead cipher.AEAD
+	if aead, err = newKeystoreCipher(passphrase, params); err != nil {
+		return nil, err
+	}
+
+	// Encrypt the xpriv (the name is authenticated, so entries cannot be swapped)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	return &keystoreEntry{
+		KeystoreKey: KeystoreKey{CreatedAt: time.Now().UTC(), Name: name, Xpub: xpub},
+		Ciphertext:  hex.EncodeToString(aead.Seal(nil, nonce, []byte(hdKey.String()), []byte(name))),
+		KDF:         keystoreKDF,
+		KDFParams:   params,
+		Nonce:       hex.EncodeToString(nonce),
+	}, nil
+}
+
+// decrypt will return the xpriv using the passphrase
+func (e *keystoreEntry) decrypt(passphrase string) (string, error) {
+	if e.KDF != keystoreKDF || e.KDFParams == nil {
+		return "", fmt.Errorf("unsupported key derivation function for key %s: %s", e.Name, e.KDF)
+	}
+
+	aead, err := newKeystoreCipher(passphrase, e.KDFParams)
+	if err != nil {
+		return "", err
+	}
+
+	var nonce, ciphertext []byte
+	if nonce, err = hex.DecodeString(e.Nonce); err != nil || len(nonce) != aead.NonceSize() {
+		return "", fmt.Errorf("invalid nonce for key %s", e.Name)
+	} else if ciphertext, err = hex.DecodeString(e.Ciphertext); err != nil {
+		return "", fmt.Errorf("invalid ciphertext for key %s", e.Name)
+	}
+
+	var plaintext []byte
+	if plaintext, err = aead.Open(nil, nonce, ciphertext, []byte(e.Name)); err != nil {
+		return "", fmt.Errorf("%w for key %s", ErrInvalidKeyPassphrase, e.Name)
+	}
+	return string(plaintext), nil
+}
+
+// newKeystoreCipher will derive the key from the passphrase (scrypt) and return the AES-GCM cipher
+func newKeystoreCipher(passphrase string, params *keystoreParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore salt: %w", err)
+	}
+
+	var key []byte
+	if key, err = scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, keystoreKeyLen); err != nil {
+		return nil, fmt.Errorf("error deriving keystore key: %w", err)
+	}
+
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return nil, fmt.Errorf("error creating keystore cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// getKeystoreXpriv will decrypt the key by name (the passphrase is prompted or read from the environment)
+func getKeystoreXpriv(ks *keystore, name string) (string, error) {
+	entry := ks.get(name)
+	if entry == nil {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+
+	passphrase, err := getKeyPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	return entry.decrypt(passphrase)
+}
+
+// getXpriv will return the xpriv from the flag or argument, or from the keystore if --key is set
+func getXpriv(xprivKey string) (string, error) {
+	if len(keyName) == 0 {
+		return xprivKey, nil
+	} else if len(xprivKey) > 0 {
+		return "", fmt.Errorf("%w: use an xpriv or --%s, not both", ErrInvalidFlag, flagKey)
+	}
+
+	ks, err := loadKeystore(keystorePath())
+	if err != nil {
+		return "", err
+	}
+	return getKeystoreXpriv(ks, keyName)
+}
+
+// getKeyPassphrase will return the passphrase from the environment, or prompt for it (confirm for new keys)
+//
+// If stdin is piped (IE: the xpriv is piped to keystore add), the passphrase is prompted on the terminal
+func getKeyPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(keyPassphraseEnv); len(passphrase) > 0 {
+		return passphrase, nil
+	}
+
+	input, closeInput := openPromptInput()
+	if input == nil {
+		return "", fmt.Errorf("%w: set %s or use a terminal", ErrKeyPassphraseIsRequired, keyPassphraseEnv)
+	}
+	defer closeInput()
+
+	passphrase, err := promptSecret(input, "Keystore passphrase: ")
+	if err != nil {
+		return "", err
+	} else if len(passphrase) == 0 {
+		return "", ErrKeyPassphraseIsRequired
+	}
+
+	if confirm {
+		var confirmation string
+		if confirmation, err = promptSecret(input, "Confirm passphrase: "); err != nil {
+			return "", err
+		} else if confirmation != passphrase {
+			return "", ErrKeyPassphraseMismatch
+		}
+	}
+	return passphrase, nil
+}
+
+// readXpriv will prompt for the xpriv, or read the first line from stdin (IE: piped from another command)
+func readXpriv() (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return promptSecret(os.Stdin, "Xpriv: ")
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		return "", ErrXprivIsRequired
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// openPromptInput will return the terminal to prompt on: stdin, or the controlling terminal if stdin is piped
+//
+// Nil is returned if there is no terminal (IE: a script or CI), the close func must be called
+func openPromptInput() (*os.File, func()) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return os.Stdin, func() {}
+	}
+	tty, err := os.Open(ttyPath)
+	if err != nil {
+		return nil, nil
+	} else if !term.IsTerminal(int(tty.Fd())) {
+		_ = tty.Close()
+		return nil, nil
+	}
+	return tty, func() {
+		_ = tty.Close()
+	}
+}
+
+// promptSecret will prompt for a secret on stderr without echoing the input (read from the terminal)
+func promptSecret(input *os.File, prompt string) (string, error) {
+	_, _ = fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(input.Fd()))
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %w", err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
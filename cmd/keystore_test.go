@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystoreEntry(t *testing.T) {
+	t.Parallel()
+
+	xprivKey := testMnemonicVectors[0].xpriv
+
+	t.Run("encrypt and decrypt", func(t *testing.T) {
+		entry, err := newKeystoreEntry("main", " "+xprivKey+"\n", "passphrase")
+		require.NoError(t, err)
+		assert.Equal(t, "main", entry.Name)
+		assert.Equal(t, keystoreKDF, entry.KDF)
+		assert.NotContains(t, entry.Ciphertext, xprivKey)
+		assert.Contains(t, entry.Xpub, "xpub")
+
+		var decrypted string
+		decrypted, err = entry.decrypt("passphrase")
+		require.NoError(t, err)
+		assert.Equal(t, xprivKey, decrypted)
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		entry, err := newKeystoreEntry("main", xprivKey, "passphrase")
+		require.NoError(t, err)
+
+		_, err = entry.decrypt("wrong")
+		require.ErrorIs(t, err, ErrInvalidKeyPassphrase)
+	})
+
+	t.Run("renamed entry", func(t *testing.T) {
+		entry, err := newKeystoreEntry("main", xprivKey, "passphrase")
+		require.NoError(t, err)
+
+		entry.Name = "other"
+		_, err = entry.decrypt("passphrase")
+		require.ErrorIs(t, err, ErrInvalidKeyPassphrase)
+	})
+
+	t.Run("invalid xpriv", func(t *testing.T) {
+		_, err := newKeystoreEntry("main", "xprv-invalid", "passphrase")
+		require.ErrorIs(t, err, ErrInvalidXpriv)
+	})
+
+	t.Run("xpub is not allowed", func(t *testing.T) {
+		entry, err := newKeystoreEntry("main", xprivKey, "passphrase")
+		require.NoError(t, err)
+
+		_, err = newKeystoreEntry("main", entry.Xpub, "passphrase")
+		require.ErrorIs(t, err, ErrInvalidXpriv)
+	})
+}
+
+func TestKeystoreFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), keystoreFileName)
+
+	// Missing file is an empty keystore
+	ks, err := loadKeystore(path)
+	require.NoError(t, err)
+	assert.Empty(t, ks.Keys)
+
+	// Add two keys and save
+	for _, name := range []string{"second", "first"} {
+		var entry *keystoreEntry
+		entry, err = newKeystoreEntry(name, testMnemonicVectors[0].xpriv, "passphrase")
+		require.NoError(t, err)
+		ks.Keys = append(ks.Keys, entry)
+	}
+	require.NoError(t, saveKeystore(path, ks))
+
+	// The file is only readable by the user
+	var info os.FileInfo
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Load the keys (sorted by name) and decrypt
+	ks, err = loadKeystore(path)
+	require.NoError(t, err)
+	require.Len(t, ks.Keys, 2)
+	assert.Equal(t, "first", ks.Keys[0].Name)
+	assert.Equal(t, keystoreVersion, ks.Version)
+
+	var decrypted string
+	decrypted, err = ks.get("second").decrypt("passphrase")
+	require.NoError(t, err)
+	assert.Equal(t, testMnemonicVectors[0].xpriv, decrypted)
+
+	// Remove a key
+	ks.remove("first")
+	assert.Nil(t, ks.get("first"))
+	assert.Len(t, ks.Keys, 1)
+}
+
+func TestKeyNameRegex(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, keyNameRegex.MatchString("main"))
+	assert.True(t, keyNameRegex.MatchString("hot-wallet_2.0"))
+	assert.False(t, keyNameRegex.MatchString(""))
+	assert.False(t, keyNameRegex.MatchString("my key"))
+	assert.False(t, keyNameRegex.MatchString("../main"))
+}
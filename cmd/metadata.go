@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BuxOrg/bux"
+	"github.com/spf13/cobra"
+)
+
+// stdinPath is the file name for reading from stdin (IE: --metadata-file=- or --hex-file=-)
+const stdinPath = "-"
+
+// addMetadataFlag will add the metadata flags (JSON or a JSON file) to the command
+func addMetadataFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&metadata, flagMetadata, flagMetadataShort, "", "Model Metadata")
+	cmd.Flags().StringVar(&metadataFile, flagMetadataFile, "",
+		"File with the model metadata JSON (use "+stdinPath+" for stdin, instead of --"+flagMetadata+")")
+}
+
+// addMetadataUpdateFlags will add the flags for updating the metadata (replace and delete keys)
+func addMetadataUpdateFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&metadataReplace, flagReplace, false,
+		"Replace the metadata (the keys that are not in the new metadata are removed), the default is to merge")
+	cmd.Flags().StringArrayVar(&metadataDelete, flagDeleteKey, nil, "Metadata key to remove (repeat for each key)")
+}
+
+// getMetadataFlag will get the metadata flag (or file) and parse it into a BUX metadata model (nil if not set)
+func getMetadataFlag(cmd *cobra.Command) (bux.Metadata, error) {
+	metadataJSON, err := cmd.Flags().GetString(flagMetadata)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing metadata: %w", err)
+	}
+
+	// Read the metadata from the file (or stdin)
+	var path string
+	if path, err = cmd.Flags().GetString(flagMetadataFile); err != nil || len(path) == 0 {
+		return parseMetadata(metadataJSON)
+	} else if len(metadataJSON) > 0 {
+		return nil, fmt.Errorf("%w: use --%s or --%s, not both", ErrInvalidFlag, flagMetadata, flagMetadataFile)
+	}
+	var content []byte
+	if content, err = readMetadataFile(path, os.Stdin); err != nil {
+		return nil, err
+	}
+	return parseMetadata(string(content))
+}
+
+// readMetadataFile will read the metadata JSON from the file, or from stdin if the path is "-"
+func readMetadataFile(path string, stdin io.Reader) (content []byte, err error) {
+	if path == stdinPath {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(path) //nolint:gosec // the path is provided by the user
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading metadata file: %w", err)
+	}
+	return
+}
+
+// parseMetadata will parse the metadata JSON (if provided) into a BUX metadata model
+func parseMetadata(metadataJSON string) (metadata bux.Metadata, err error) {
+	if len(metadataJSON) == 0 {
+		return
+	}
+	if err = json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		err = fmt.Errorf("error unmarshalling metadata: %w", err)
+	}
+	return
+}
+
+// metadataConditions will return the metadata for searching (nil if empty, so all models match)
+func metadataConditions(metadata bux.Metadata) *bux.Metadata {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return &metadata
+}
+
+// metadataUpdate will return the metadata for a BUX metadata update (merged, a nil value removes the key)
+//
+// Replace removes the current keys that are not in the new metadata, the delete keys are always removed
+func metadataUpdate(current, metadata bux.Metadata, deleteKeys []string, replace bool) (bux.Metadata, error) {
+	if len(metadata) == 0 && len(deleteKeys) == 0 && !replace {
+		return nil, ErrMetadataIsRequired
+	}
+
+	update := make(bux.Metadata, len(current)+len(metadata)+len(deleteKeys))
+	if replace {
+		for key := range current {
+			update[key] = nil
+		}
+	}
+	for key, value := range metadata {
+		update[key] = value
+	}
+	for _, key := range deleteKeys {
+		if _, ok := metadata[key]; ok {
+			return nil, fmt.Errorf("%w: the metadata key %s is set and removed (--%s)", ErrInvalidFlag, key, flagDeleteKey)
+		}
+		update[key] = nil
+	}
+	return update, nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataUpdate(t *testing.T) {
+	t.Parallel()
+
+	current := bux.Metadata{"name": "old", "customer": "123", "note": "keep"}
+
+	t.Run("merge", func(t *testing.T) {
+		update, err := metadataUpdate(current, bux.Metadata{"name": "new"}, nil, false)
+		require.NoError(t, err)
+		assert.Equal(t, bux.Metadata{"name": "new"}, update)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		update, err := metadataUpdate(current, bux.Metadata{"name": "new"}, nil, true)
+		require.NoError(t, err)
+		assert.Equal(t, bux.Metadata{"name": "new", "customer": nil, "note": nil}, update)
+	})
+
+	t.Run("replace with nothing removes all the keys", func(t *testing.T) {
+		update, err := metadataUpdate(current, nil, nil, true)
+		require.NoError(t, err)
+		assert.Equal(t, bux.Metadata{"name": nil, "customer": nil, "note": nil}, update)
+	})
+
+	t.Run("delete keys", func(t *testing.T) {
+		update, err := metadataUpdate(nil, bux.Metadata{"name": "new"}, []string{"customer"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, bux.Metadata{"name": "new", "customer": nil}, update)
+	})
+
+	t.Run("set and delete the same key", func(t *testing.T) {
+		_, err := metadataUpdate(nil, bux.Metadata{"name": "new"}, []string{"name"}, false)
+		require.ErrorIs(t, err, ErrInvalidFlag)
+	})
+
+	t.Run("nothing to update", func(t *testing.T) {
+		_, err := metadataUpdate(current, nil, nil, false)
+		require.ErrorIs(t, err, ErrMetadataIsRequired)
+	})
+}
+
+func TestReadMetadataFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "metadata.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"name":"file"}`), 0o600))
+		content, err := readMetadataFile(path, nil)
+		require.NoError(t, err)
+		metadata, err := parseMetadata(string(content))
+		require.NoError(t, err)
+		assert.Equal(t, bux.Metadata{"name": "file"}, metadata)
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		content, err := readMetadataFile(stdinPath, strings.NewReader(`{"name":"stdin","remove":null}`))
+		require.NoError(t, err)
+		metadata, err := parseMetadata(string(content))
+		require.NoError(t, err)
+		assert.Equal(t, bux.Metadata{"name": "stdin", "remove": nil}, metadata)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readMetadataFile(filepath.Join(t.TempDir(), "missing.json"), nil)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tonicpow/go-minercraft"
+)
+
+// commands for miners
+const minersCommandName = "miners"
+const minersCommandList = "list"
+const minersCommandCheck = "check"
+
+// minerCheckTimeout is the timeout for each miner request (fee quote and policy quote, no retries)
+const minerCheckTimeout = 10 * time.Second
+
+// returnMinersCmd returns the miners command
+func returnMinersCmd(app *App) (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
+		Use:   minersCommandName,
+		Short: "list and check the configured miners (fee quotes, policy and latency)",
+		Long: color.GreenString(`
+   _____    .___   _______    ___________ __________    _________
+  /     \   |   |  \      \   \_   _____/ \______   \  /   _____/
+ /  \ /  \  |   |  /   |   \   |    __)_   |       _/  \_____  \
+/    Y    \ |   | /    |    \  |        \  |    |   \  /        \
+\____|__  / |___| \____|__  / /_______  /  |____|_  / /_______  /
+        \/                \/          \/          \/          \/`) + `
+` + color.YellowString(`
+This command is for the miners in the chainstate config (broadcasting and querying).
+
+The miners are the known miners (Taal, Mempool, Matterpool and GorillaPool) and the custom miners (chainstate.miners),
+only the Merchant API (mAPI) is supported.
+
+list: lists the configured miners (`+minersCommandName+` `+minersCommandList+`)
+check: fetches the fee quote and policy, shows the reachability and latency (`+minersCommandName+` `+minersCommandCheck+` [name...])
+`),
+		Example: applicationName + " " + minersCommandName + " " + minersCommandCheck + " taal",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("%s %w, IE: %s, etc.", minersCommandName, ErrSubcommandIsRequired,
+					minersCommandList+", "+minersCommandCheck)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Load the configured miners
+			config := app.config.Chainstate
+			if config == nil {
+				config = &ChainstateConfig{}
+			}
+			infos, miners, err := configuredMiners(config)
+			if err != nil {
+				return err
+			}
+
+			// Switch on the subcommand
+			if args[0] == minersCommandList { // List the configured miners
+
+				return displayModel(infos)
+			} else if args[0] == minersCommandCheck { // Check the miners
+
+				// Check the named miners (any known or custom miner), or all the configured miners
+				if len(args) > 1 {
+					var all []*minercraft.Miner
+					if all, err = newMiners(config); err != nil {
+						return err
+					} else if miners, err = selectMiners(args[1:], all, false); err != nil {
+						return err
+					}
+					infos = newMinerInfos(miners, config)
+				}
+
+				// Create the client (short timeout, no retries)
+				var client minercraft.ClientInterface
+				if client, err = newMinerClient(app.GetUserAgent(), miners); err != nil {
+					return err
+				}
+
+				// Check the miners and display the results
+				checks := checkMiners(cmd.Context(), client, miners, infos)
+				if err = displayModel(checks); err != nil {
+					return err
+				}
+				var unreachable []string
+				for _, check := range checks {
+					if !check.Reachable {
+						unreachable = append(unreachable, check.Name)
+					}
+				}
+				if len(unreachable) > 0 {
+					return fmt.Errorf("%w: %s", ErrMinerUnreachable, strings.Join(unreachable, ", "))
+				}
+				return nil
+			}
+
+			return ErrUnknownSubcommand
+		},
+	}
+	return
+}
+
+// newMinerClient will create the minercraft client for the miners (short timeout, no retries)
+func newMinerClient(userAgent string, miners []*minercraft.Miner) (minercraft.ClientInterface, error) {
+	options := minercraft.DefaultClientOptions()
+	options.RequestRetryCount = 0
+	options.RequestTimeout = minerCheckTimeout
+	options.UserAgent = userAgent
+	return minercraft.NewClient(options, nil, miners)
+}
+
+// configuredMiners will return the miners for broadcasting and querying (the BUX defaults if not configured)
+func configuredMiners(config *ChainstateConfig) ([]*MinerInfo, []*minercraft.Miner, error) {
+	all, err := newMiners(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The broadcast miners first, then the query miners (without duplicates)
+	var miners []*minercraft.Miner
+	for _, isBroadcast := range []bool{true, false} {
+		var selected []*minercraft.Miner
+		if selected, err = selectMiners(configuredMinerNames(config, isBroadcast), all, config.LenientMiners); err != nil {
+			return nil, nil, err
+		}
+		for _, miner := range selected {
+			if minerByName(miners, miner.Name) == nil {
+				miners = append(miners, miner)
+			}
+		}
+	}
+	return newMinerInfos(miners, config), miners, nil
+}
+
+// configuredMinerNames will return the names of the miners for broadcasting or querying
+func configuredMinerNames(config *ChainstateConfig, isBroadcast bool) []string {
+	names := config.MinersQuery
+	if isBroadcast {
+		names = config.MinersBroadcast
+	}
+	if len(names) == 0 {
+		return defaultMinerNames(isBroadcast)
+	}
+	return names
+}
+
+// newMinerInfos will return the info for the miners (if the miner is used for broadcasting and/or querying)
+func newMinerInfos(miners []*minercraft.Miner, config *ChainstateConfig) []*MinerInfo {
+	contains := func(names []string, name string) bool {
+		for _, n := range names {
+			if strings.EqualFold(n, name) {
+				return true
+			}
+		}
+		return false
+	}
+	infos := make([]*MinerInfo, 0, len(miners))
+	for _, miner := range miners {
+		infos = append(infos, &MinerInfo{
+			Broadcast: contains(configuredMinerNames(config, true), miner.Name),
+			HasToken:  len(miner.Token) > 0,
+			MinerID:   miner.MinerID,
+			Name:      miner.Name,
+			Query:     contains(configuredMinerNames(config, false), miner.Name),
+			URL:       miner.URL,
+		})
+	}
+	return infos
+}
+
+// checkMiners will check the miners concurrently (the results are in the same order as the miners)
+func checkMiners(ctx context.Context, client minercraft.ClientInterface, miners []*minercraft.Miner,
+	infos []*MinerInfo) []*MinerCheck {
+	checks := make([]*MinerCheck, len(miners))
+	var wg sync.WaitGroup
+	for index := range miners {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			checks[index] = checkMiner(ctx, client, miners[index], infos[index])
+		}(index)
+	}
+	wg.Wait()
+	return checks
+}
+
+// checkMiner will fetch the fee quote (latency) and the policy quote of the miner
+func checkMiner(ctx context.Context, client minercraft.ClientInterface, miner *minercraft.Miner,
+	info *MinerInfo) *MinerCheck {
+	check := &MinerCheck{Fees: []*MinerFee{}, MinerInfo: info}
+
+	// Fee quote (the latency is the time for the fee quote)
+	start := time.Now()
+	feeQuote, err := client.FeeQuote(ctx, miner)
+	check.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	} else if feeQuote == nil || feeQuote.Quote == nil {
+		check.Error = "missing fee quote"
+		return check
+	}
+	check.Reachable = true
+	for _, fee := range feeQuote.Quote.Fees {
+		check.Fees = append(check.Fees, &MinerFee{
+			FeeType:   string(fee.FeeType),
+			MiningFee: satoshisPerByte(fee.MiningFee.Satoshis, fee.MiningFee.Bytes),
+			RelayFee:  satoshisPerByte(fee.RelayFee.Satoshis, fee.RelayFee.Bytes),
+		})
+	}
+
+	// Policy quote (not all miners support it, the miner is still reachable)
+	policyQuote, err := client.PolicyQuote(ctx, miner)
+	if err != nil {
+		check.Error = "policy quote: " + err.Error()
+	} else if policyQuote != nil && policyQuote.Quote != nil {
+		check.Policy = policyQuote.Quote.Policies
+	}
+	return check
+}
+
+// satoshisPerByte will return the fee rate in satoshis per byte
+func satoshisPerByte(satoshis, bytes int) float64 {
+	if bytes == 0 {
+		return 0
+	}
+	return float64(satoshis) / float64(bytes)
+}
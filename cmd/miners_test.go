@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tonicpow/go-minercraft"
+)
+
+// testMinerPayload is the (unsigned) payload of the fee and policy quotes from the test miner
+const testMinerPayload = `{
+	"apiVersion": "1.4.0",
+	"timestamp": "2022-10-01T10:00:00.000Z",
+	"expiryTime": "2022-10-01T10:10:00.000Z",
+	"minerId": "test-miner-id",
+	"currentHighestBlockHash": "0000000000000000020c5e1a2b6e4b8f5c6f4d1e6d2c0f6c3a1a1a1a1a1a1a1a",
+	"currentHighestBlockHeight": 760000,
+	"fees": [
+		{"feeType": "standard", "miningFee": {"satoshis": 50, "bytes": 1000}, "relayFee": {"satoshis": 25, "bytes": 1000}},
+		{"feeType": "data", "miningFee": {"satoshis": 1, "bytes": 4}, "relayFee": {"satoshis": 0, "bytes": 1000}}
+	],
+	"policies": {"maxtxsizepolicy": 99999, "datacarrier": true}
+}`
+
+// newTestMinerServer will return a local stand-in mAPI server (fee quote and policy quote)
+func newTestMinerServer(t *testing.T, token string) *httptest.Server {
+	envelope, err := json.Marshal(map[string]string{
+		"encoding": "UTF-8",
+		"mimetype": "application/json",
+		"payload":  testMinerPayload,
+	})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(envelope)
+	}
+	mux.HandleFunc("/mapi/feeQuote", handler)
+	mux.HandleFunc("/mapi/policyQuote", handler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestConfiguredMiners(t *testing.T) {
+	t.Parallel()
+
+	t.Run("default miners", func(t *testing.T) {
+		infos, miners, err := configuredMiners(&ChainstateConfig{})
+		require.NoError(t, err)
+		require.Len(t, infos, len(miners))
+		assert.Equal(t, defaultMinerNames(true), minerNames(miners))
+
+		taal := infos[0]
+		assert.Equal(t, minercraft.MinerTaal, taal.Name)
+		assert.True(t, taal.Broadcast)
+		assert.True(t, taal.Query)
+		assert.False(t, taal.HasToken)
+	})
+
+	t.Run("custom miners", func(t *testing.T) {
+		infos, miners, err := configuredMiners(&ChainstateConfig{
+			MinerTokens:     map[string]string{"local": "token"},
+			Miners:          []*MinerConfig{{MinerID: "local-id", Name: "Local", URL: "http://localhost:8080"}},
+			MinersBroadcast: []string{"local"},
+			MinersQuery:     []string{"taal", "LOCAL"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Local", minercraft.MinerTaal}, minerNames(miners))
+		assert.Equal(t, &MinerInfo{
+			Broadcast: true, HasToken: true, MinerID: "local-id", Name: "Local", Query: true, URL: "http://localhost:8080",
+		}, infos[0])
+		assert.False(t, infos[1].Broadcast)
+		assert.True(t, infos[1].Query)
+	})
+
+	t.Run("unknown miners", func(t *testing.T) {
+		_, _, err := configuredMiners(&ChainstateConfig{MinersBroadcast: []string{"taal", "unknown"}})
+		require.ErrorIs(t, err, ErrUnknownMiner)
+
+		// Lenient skips the unknown miners
+		_, miners, err := configuredMiners(&ChainstateConfig{
+			LenientMiners: true, MinersBroadcast: []string{"taal", "unknown"}, MinersQuery: []string{"unknown"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{minercraft.MinerTaal}, minerNames(miners))
+	})
+}
+
+func TestSelectMiners(t *testing.T) {
+	t.Parallel()
+
+	miners, err := newMiners(&ChainstateConfig{})
+	require.NoError(t, err)
+
+	selected, err := selectMiners([]string{"GorillaPool", "taal"}, miners, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{minercraft.MinerGorillaPool, minercraft.MinerTaal}, minerNames(selected))
+
+	_, err = selectMiners([]string{"taal", "unknown"}, miners, false)
+	require.ErrorIs(t, err, ErrUnknownMiner)
+
+	selected, err = selectMiners([]string{"taal", "unknown"}, miners, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{minercraft.MinerTaal}, minerNames(selected))
+}
+
+func TestCheckMiners(t *testing.T) {
+	t.Parallel()
+
+	server := newTestMinerServer(t, "local-token")
+	_, miners, err := configuredMiners(&ChainstateConfig{
+		Miners: []*MinerConfig{
+			{MinerID: "local-id", Name: "local", Token: "local-token", URL: server.URL},
+			{MinerID: "no-token-id", Name: "no-token", URL: server.URL},
+			{MinerID: "offline-id", Name: "offline", URL: "http://127.0.0.1:1"},
+		},
+		MinersBroadcast: []string{"local", "no-token", "offline"},
+		MinersQuery:     []string{"local"},
+	})
+	require.NoError(t, err)
+
+	options := minercraft.DefaultClientOptions()
+	options.RequestRetryCount = 0
+	client, err := minercraft.NewClient(options, nil, miners)
+	require.NoError(t, err)
+
+	infos := newMinerInfos(miners, &ChainstateConfig{MinersBroadcast: []string{"local"}})
+	checks := checkMiners(context.Background(), client, miners, infos)
+	require.Len(t, checks, 3)
+
+	// The local miner is reachable (fees in satoshis per byte and the policy)
+	local := checks[0]
+	assert.Equal(t, "local", local.Name)
+	assert.True(t, local.Reachable)
+	assert.Empty(t, local.Error)
+	assert.GreaterOrEqual(t, local.LatencyMS, int64(0))
+	assert.Equal(t, []*MinerFee{
+		{FeeType: "standard", MiningFee: 0.05, RelayFee: 0.025},
+		{FeeType: "data", MiningFee: 0.25, RelayFee: 0},
+	}, local.Fees)
+	require.NotNil(t, local.Policy)
+	assert.Equal(t, uint32(99999), local.Policy.MaxTxSizePolicy)
+	assert.True(t, local.Policy.DataCarrier)
+
+	// Without the token the miner returns an error
+	assert.Equal(t, "no-token", checks[1].Name)
+	assert.False(t, checks[1].Reachable)
+	assert.NotEmpty(t, checks[1].Error)
+
+	// The offline miner is unreachable
+	assert.Equal(t, "offline", checks[2].Name)
+	assert.False(t, checks[2].Reachable)
+	assert.NotEmpty(t, checks[2].Error)
+	assert.Empty(t, checks[2].Fees)
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BuxOrg/bux-cli/chalker"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for displaying models and errors
+const (
+	outputFormatCSV    = "csv"    // Comma separated values (header + rows)
+	outputFormatJSON   = "json"   // Compact JSON (one line)
+	outputFormatPretty = "pretty" // Indented JSON (coloured in a terminal)
+	outputFormatTable  = "table"  // Aligned table
+	outputFormatYAML   = "yaml"   // YAML
+)
+
+// outputFormats are all the supported output formats
+var outputFormats = []string{
+	outputFormatPretty, outputFormatJSON, outputFormatYAML, outputFormatTable, outputFormatCSV,
+}
+
+// flatField is a single flattened field (dotted key path) and its display value
+type flatField struct {
+	Key   string
+	Value string
+}
+
+// validateOutputFormat will check that the output format is supported
+func validateOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (use: %s)", ErrUnknownOutputFormat, format, strings.Join(outputFormats, ", "))
+}
+
+// displayModel will display a model using the selected output format
+func displayModel(v any) error {
+	if v == nil {
+		return ErrModelIsNil
+	}
+
+	// Pretty (default) is coloured when displayed in a terminal
+	if outputFormat == outputFormatPretty || len(outputFormat) == 0 {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling model: %w", err)
+		}
+		chalker.Log(chalker.INFO, string(b))
+		return nil
+	}
+
+	if err := renderModel(os.Stdout, outputFormat, v); err != nil {
+		return fmt.Errorf("error rendering model: %w", err)
+	}
+	return nil
+}
+
+// displayError will display an error as a structured object (stderr)
+func displayError(err error) {
+	if err == nil {
+		return
+	}
+	if outputFormat == outputFormatPretty || len(outputFormat) == 0 {
+		var b bytes.Buffer
+		_ = renderError(&b, outputFormatJSON, err)
+		chalker.LogTo(os.Stderr, chalker.ERROR, strings.TrimSpace(b.String()))
+		return
+	}
+	_ = renderError(os.Stderr, outputFormat, err)
+}
+
+// renderError will write the error as a structured object in the given format
+//
+// Table and CSV formats use JSON for errors, so they are easy to separate from the data
+func renderError(w io.Writer, format string, err error) error {
+	payload := map[string]string{"error": err.Error()}
+	if format == outputFormatYAML {
+		return yaml.NewEncoder(w).Encode(payload)
+	}
+	return json.NewEncoder(w).Encode(payload)
+}
+
+// renderModel will write the model (or a list of models) in the given format
+func renderModel(w io.Writer, format string, v any) error {
+
+	// Convert into JSON first, so all formats use the same field names
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case outputFormatJSON:
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case outputFormatPretty:
+		var b bytes.Buffer
+		if err = json.Indent(&b, data, "", "  "); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, b.String())
+		return err
+	case outputFormatYAML:
+		var generic interface{}
+		if err = json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		return yaml.NewEncoder(w).Encode(generic)
+	case outputFormatTable, outputFormatCSV:
+		var rows [][]flatField
+		if rows, err = flattenJSON(data); err != nil {
+			return err
+		}
+		if format == outputFormatCSV {
+			return renderCSV(w, rows)
+		}
+		return renderTable(w, rows, !isJSONList(data))
+	}
+	return validateOutputFormat(format)
+}
+
+// renderTable will write the rows as an aligned table
+//
+// A single model is displayed vertically (field, value), a list is displayed with a column per field
+func renderTable(w io.Writer, rows [][]flatField, single bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if single {
+		_, _ = fmt.Fprintln(tw, "FIELD\tVALUE")
+		for _, row := range rows {
+			for _, field := range row {
+				_, _ = fmt.Fprintf(tw, "%s\t%s\n", field.Key, tableCell(field.Value))
+			}
+		}
+		return tw.Flush()
+	}
+
+	columns := flatColumns(rows)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = strings.ToUpper(column)
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		values := flatValues(row, columns)
+		for i := range values {
+			values[i] = tableCell(values[i])
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// renderCSV will write the rows as CSV with a header row
+func renderCSV(w io.Writer, rows [][]flatField) error {
+	columns := flatColumns(rows)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := cw.Write(flatValues(row, columns)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tableCell will make sure a value does not break the table alignment
+func tableCell(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(value)
+}
+
+// flatColumns will return all the keys (in order of first appearance) across all rows
+func flatColumns(rows [][]flatField) (columns []string) {
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for _, field := range row {
+			if !seen[field.Key] {
+				seen[field.Key] = true
+				columns = append(columns, field.Key)
+			}
+		}
+	}
+	return
+}
+
+// flatValues will return the values of the row in the order of the columns
+func flatValues(row []flatField, columns []string) []string {
+	values := make([]string, len(columns))
+	index := make(map[string]string, len(row))
+	for _, field := range row {
+		index[field.Key] = field.Value
+	}
+	for i, column := range columns {
+		values[i] = index[column]
+	}
+	return values
+}
+
+// isJSONList will return true if the JSON is an array
+func isJSONList(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// flattenJSON will flatten a JSON object (or array of objects) into rows of dotted key/value fields
+func flattenJSON(data []byte) (rows [][]flatField, err error) {
+	if isJSONList(data) {
+		var items []json.RawMessage
+		if err = json.Unmarshal(data, &items); err != nil {
+			return
+		}
+		for _, item := range items {
+			var row []flatField
+			if row, err = flattenValue("", item, nil); err != nil {
+				return
+			}
+			rows = append(rows, row)
+		}
+		return
+	}
+
+	var row []flatField
+	if row, err = flattenValue("", data, nil); err != nil {
+		return
+	}
+	rows = append(rows, row)
+	return
+}
+
+// flattenValue will flatten the JSON value into fields (objects are nested using dotted keys)
+func flattenValue(prefix string, data []byte, fields []flatField) ([]flatField, error) {
+	trimmed := bytes.TrimSpace(data)
+	key := prefix
+	if len(key) == 0 {
+		key = "value"
+	}
+
+	// Scalars, nulls and arrays are displayed as a single value
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		value := string(trimmed)
+		if value == "null" {
+			value = ""
+		} else if len(trimmed) > 0 && trimmed[0] == '"' {
+			if err := json.Unmarshal(trimmed, &value); err != nil {
+				return nil, err
+			}
+		}
+		return append(fields, flatField{Key: key, Value: value}), nil
+	}
+
+	// Objects are decoded in order to keep the field order of the model
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprint(token)
+		if len(prefix) > 0 {
+			name = prefix + "." + name
+		}
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		if fields, err = flattenValue(name, value, fields); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
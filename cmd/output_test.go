@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/mrz1836/go-whatsonchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateOutputFormat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid formats", func(t *testing.T) {
+		for _, format := range outputFormats {
+			assert.NoError(t, validateOutputFormat(format))
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		err := validateOutputFormat("xml")
+		require.ErrorIs(t, err, ErrUnknownOutputFormat)
+	})
+}
+
+func TestRenderModel(t *testing.T) {
+	t.Parallel()
+
+	keys := &Keys{Xpriv: "xprv-test", Xpub: "xpub-test"}
+	destinations := []*Destination{
+		{Bux: &bux.Destination{ID: "id-1", Address: "address-1"}},
+		{Bux: &bux.Destination{ID: "id-2", Address: "address-2"}, WOCBalance: &whatsonchain.AddressBalance{Confirmed: 100}},
+	}
+
+	t.Run("compact json", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, renderModel(&b, outputFormatJSON, keys))
+		assert.Equal(t, `{"private_key":"","wif":"","xpriv":"xprv-test","xpub":"xpub-test"}`+"\n", b.String())
+	})
+
+	t.Run("pretty json", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, renderModel(&b, outputFormatPretty, keys))
+		assert.Contains(t, b.String(), "\n  \"xpriv\": \"xprv-test\",\n")
+	})
+
+	t.Run("yaml uses the json field names", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, renderModel(&b, outputFormatYAML, keys))
+		var decoded map[string]string
+		require.NoError(t, yaml.Unmarshal(b.Bytes(), &decoded))
+		assert.Equal(t, "xprv-test", decoded["xpriv"])
+		assert.Equal(t, "xpub-test", decoded["xpub"])
+	})
+
+	t.Run("table for a single model", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, renderModel(&b, outputFormatTable, keys))
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		require.Len(t, lines, 5)
+		assert.Equal(t, []string{"FIELD", "VALUE"}, strings.Fields(lines[0]))
+		assert.Equal(t, []string{"private_key", "-"}, strings.Fields(lines[1]))
+		assert.Equal(t, []string{"xpriv", "xprv-test"}, strings.Fields(lines[3]))
+	})
+
+	t.Run("table for a list of models", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, renderModel(&b, outputFormatTable, destinations))
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		require.Len(t, lines, 3)
+		header := strings.Fields(lines[0])
+		assert.Contains(t, header, "BUX.ID")
+		assert.Contains(t, header, "WOC_BALANCE.CONFIRMED")
+		assert.Equal(t, len(header), len(strings.Fields(lines[1])))
+		assert.Equal(t, len(header), len(strings.Fields(lines[2])))
+	})
+
+	t.Run("csv for a list of models", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, renderModel(&b, outputFormatCSV, destinations))
+		records, err := csv.NewReader(&b).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+
+		// Find the columns by name
+		columns := make(map[string]int)
+		for i, name := range records[0] {
+			columns[name] = i
+		}
+		require.Contains(t, columns, "bux.id")
+		require.Contains(t, columns, "woc_balance.confirmed")
+		assert.Equal(t, "id-1", records[1][columns["bux.id"]])
+		assert.Equal(t, "id-2", records[2][columns["bux.id"]])
+
+		// The first destination has no balance, so the column is empty
+		assert.Equal(t, "", records[1][columns["woc_balance.confirmed"]])
+		assert.Equal(t, "100", records[2][columns["woc_balance.confirmed"]])
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		var b bytes.Buffer
+		require.ErrorIs(t, renderModel(&b, "xml", keys), ErrUnknownOutputFormat)
+	})
+}
+
+func TestRenderError(t *testing.T) {
+	t.Parallel()
+
+	testErr := errors.New(`invalid "quoted" value`)
+
+	t.Run("json is properly escaped", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, renderError(&b, outputFormatJSON, testErr))
+		var decoded map[string]string
+		require.NoError(t, json.Unmarshal(b.Bytes(), &decoded))
+		assert.Equal(t, testErr.Error(), decoded["error"])
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, renderError(&b, outputFormatYAML, testErr))
+		var decoded map[string]string
+		require.NoError(t, yaml.Unmarshal(b.Bytes(), &decoded))
+		assert.Equal(t, testErr.Error(), decoded["error"])
+	})
+}
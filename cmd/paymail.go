@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BuxOrg/bux"
+	"github.com/fatih/color"
+	"github.com/mrz1836/go-datastore"
+	"github.com/spf13/cobra"
+)
+
+// commands for paymail
+const paymailCommandDelete = "delete"
+const paymailCommandGet = "get"
+const paymailCommandList = "list"
+const paymailCommandName = "paymail"
+const paymailCommandNew = "new"
+const paymailCommandUpdateMetadata = "update-metadata"
+
+// returnPaymailCmd returns the paymail command
+func returnPaymailCmd(app *App) (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
+		Use:   paymailCommandName,
+		Short: "manage and interact with paymail addresses in BUX",
+		Long: color.GreenString(`
+__________    _____ _____.___.  _____      _____  .___.____
+\______   \  /  _  \\__  |   | /     \    /  _  \ |   |    |
+ |     ___/ /  /_\  \/   |   |/  \ /  \  /  /_\  \|   |    |
+ |    |    /    |    \____   /    Y    \/    |    \   |    |___
+ |____|    \____|__  / ______\____|__  /\____|__  /___|_______ \
+                   \/\/              \/         \/            \/`) + `
+` + color.YellowString(`
+This command is for paymail address related commands.
+
+new: creates a new paymail address in BUX (`+paymailCommandName+` `+paymailCommandNew+` <xpub> <alias@domain.com> --public-name='' --avatar='')
+get: gets an existing paymail address in BUX (`+paymailCommandName+` `+paymailCommandGet+` <alias@domain.com>)
+list: returns the paymail addresses for an xpub (`+paymailCommandName+` `+paymailCommandList+` <xpub_id> -m=<metadata_json>)
+update-metadata: updates (merges) the metadata of a paymail address (`+paymailCommandName+` `+paymailCommandUpdateMetadata+` <alias@domain.com> -m=<metadata_json>)
+delete: deletes a paymail address in BUX (`+paymailCommandName+` `+paymailCommandDelete+` <alias@domain.com>)
+`),
+		Aliases: []string{"paymails"},
+		Example: applicationName + " " + paymailCommandName + " " + paymailCommandNew + " <xpub> <alias@domain.com>",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("%s %w, IE: %s, etc.", paymailCommandName, ErrSubcommandIsRequired, paymailCommandNew)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Initialize the BUX client
+			deferFunc, err := app.InitializeBUX()
+			if err != nil {
+				return err
+			}
+			defer deferFunc()
+
+			// Parse Metadata
+			var metaData bux.Metadata
+			if metaData, err = getMetadataFlag(cmd); err != nil {
+				return err
+			}
+
+			// Switch on the subcommand
+			if args[0] == paymailCommandNew { // Create a new paymail address
+
+				// Check if xpub and paymail are provided
+				if len(args) < 2 {
+					return ErrXpubIsRequired
+				} else if len(args) < 3 {
+					return ErrPaymailIsRequired
+				}
+
+				// Create the paymail address
+				var paymail *Paymail
+				if paymail, err = newPaymail(
+					context.Background(), app, args[1], args[2], paymailPublicName, paymailAvatar, metaData,
+				); err != nil {
+					return fmt.Errorf("error creating paymail: %w", err)
+				}
+
+				// Display the paymail address
+				return displayModel(paymail)
+
+			} else if args[0] == paymailCommandGet { // Get a paymail address
+
+				// Check if paymail is provided
+				if len(args) < 2 {
+					return ErrPaymailIsRequired
+				}
+
+				// Get the paymail address
+				paymail := new(Paymail)
+				if paymail.Bux, err = app.backend.GetPaymail(context.Background(), args[1]); err != nil {
+					return fmt.Errorf("error getting paymail: %w", err)
+				}
+
+				// Display the paymail address
+				return displayModel(paymail)
+
+			} else if args[0] == paymailCommandList { // List the paymail addresses
+
+				// Check if xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubIDIsRequired
+				}
+
+				// Build the pagination
+				var queryParams *datastore.QueryParams
+				if queryParams, err = newQueryParams(page, pageSize, orderBy, sortDirection); err != nil {
+					return err
+				}
+
+				// Get the paymail addresses
+				var paymails []*Paymail
+				var total int64
+				if paymails, total, err = listPaymails(
+					context.Background(), app, args[1], metaData, queryParams,
+				); err != nil {
+					return fmt.Errorf("error getting paymails: %w", err)
+				}
+
+				// Display the paymail addresses
+				displayPage(queryParams, len(paymails), total)
+				return displayModel(paymails)
+
+			} else if args[0] == paymailCommandUpdateMetadata { // Update the metadata of a paymail address
+
+				// Check if paymail is provided
+				if len(args) < 2 {
+					return ErrPaymailIsRequired
+				}
+
+				// Update the metadata
+				var paymail *Paymail
+				if paymail, err = updatePaymailMetadata(
+					context.Background(), app, args[1], metaData, metadataDelete, metadataReplace,
+				); err != nil {
+					return fmt.Errorf("error updating paymail metadata: %w", err)
+				}
+
+				// Display the paymail address
+				return displayModel(paymail)
+
+			} else if args[0] == paymailCommandDelete { // Delete a paymail address
+
+				// Check if paymail is provided
+				if len(args) < 2 {
+					return ErrPaymailIsRequired
+				}
+
+				// Get the paymail address (to display what was deleted)
+				paymail := &Paymail{Deleted: true}
+				if paymail.Bux, err = app.backend.GetPaymail(context.Background(), args[1]); err != nil {
+					return fmt.Errorf("error getting paymail: %w", err)
+				}
+
+				// Delete the paymail address
+				if err = app.backend.DeletePaymail(context.Background(), args[1]); err != nil {
+					return fmt.Errorf("error deleting paymail: %w", err)
+				}
+
+				// Display the paymail address
+				return displayModel(paymail)
+			}
+
+			return ErrUnknownSubcommand
+		},
+	}
+
+	// Set the metadata flags
+	addMetadataFlag(newCmd)
+	addMetadataUpdateFlags(newCmd)
+
+	// Set the public profile flags
+	newCmd.Flags().StringVar(&paymailAvatar, flagAvatar, "", "Avatar url for the public profile")
+	newCmd.Flags().StringVar(&paymailPublicName, flagPublicName, "", "Public name for the public profile")
+
+	// Set the pagination flags
+	addPaginationFlags(newCmd)
+
+	return
+}
+
+// newPaymail creates a new paymail address for the xpub
+func newPaymail(ctx context.Context, app *App,
+	xpubKey, address, publicName, avatar string, metaData bux.Metadata) (paymail *Paymail, err error) {
+
+	paymail = new(Paymail)
+
+	// Create the paymail address
+	paymail.Bux, err = app.backend.NewPaymail(ctx, xpubKey, address, publicName, avatar, metaData)
+
+	return
+}
+
+// listPaymails gets a page of paymail addresses for the xpub and the total count
+func listPaymails(ctx context.Context, app *App, xpubID string, metaData bux.Metadata,
+	queryParams *datastore.QueryParams) (paymails []*Paymail, total int64, err error) {
+
+	// Get the paymail addresses
+	var results []*bux.PaymailAddress
+	if results, err = app.backend.GetPaymails(ctx, xpubID, metadataConditions(metaData), queryParams); err != nil {
+		return
+	}
+	paymails = make([]*Paymail, 0, len(results))
+	for _, result := range results {
+		paymails = append(paymails, &Paymail{Bux: result})
+	}
+
+	// Get the total count
+	total, err = app.backend.GetPaymailsCount(ctx, xpubID, metadataConditions(metaData))
+	return
+}
+
+// updatePaymailMetadata updates the metadata of a paymail address
+//
+// The metadata is merged, unless replace is set (the current metadata is needed to remove the other keys)
+func updatePaymailMetadata(ctx context.Context, app *App, address string, metaData bux.Metadata,
+	deleteKeys []string, replace bool) (paymail *Paymail, err error) {
+
+	// Get the current metadata (to replace it)
+	var current bux.Metadata
+	if replace {
+		var existing *bux.PaymailAddress
+		if existing, err = app.backend.GetPaymail(ctx, address); err != nil {
+			return
+		} else if existing == nil {
+			return nil, bux.ErrMissingPaymail
+		}
+		current = existing.Metadata
+	}
+
+	// Update the metadata
+	var update bux.Metadata
+	if update, err = metadataUpdate(current, metaData, deleteKeys, replace); err != nil {
+		return
+	}
+	paymail = new(Paymail)
+	paymail.Bux, err = app.backend.UpdatePaymailMetadata(ctx, address, update)
+	return
+}
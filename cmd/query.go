@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/mrz1836/go-datastore"
+	customTypes "github.com/mrz1836/go-datastore/custom_types"
+	"github.com/spf13/cobra"
+)
+
+// Defaults for the list commands (pagination & sorting)
+const (
+	defaultOrderBy       = "created_at"
+	defaultPage          = 1
+	defaultPageSize      = 20
+	defaultSort          = datastore.SortDesc
+	maxPageSize          = 1000
+	dateFormatShort      = "2006-01-02"
+	conditionExists      = "$exists"
+	conditionGreaterEq   = "$gte"
+	conditionGreaterThan = "$gt"
+	conditionLessEq      = "$lte"
+)
+
+// addPaginationFlags will add the pagination & sorting flags to the command
+func addPaginationFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&page, flagPage, defaultPage, "Page number for list commands (starts at 1)")
+	cmd.Flags().IntVar(&pageSize, flagPageSize, defaultPageSize, "Number of results per page for list commands")
+	cmd.Flags().StringVar(&orderBy, flagOrderBy, defaultOrderBy, "Field to order the results by for list commands")
+	cmd.Flags().StringVar(&sortDirection, flagSort, defaultSort, "Sort direction for list commands: asc or desc")
+}
+
+// newQueryParams will validate and return the query params for the datastore
+func newQueryParams(page, pageSize int, orderBy, sortDirection string) (*datastore.QueryParams, error) {
+	if page < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPage, page)
+	} else if pageSize < 1 || pageSize > maxPageSize {
+		return nil, fmt.Errorf("%w: %d (1-%d)", ErrInvalidPageSize, pageSize, maxPageSize)
+	} else if sortDirection != datastore.SortAsc && sortDirection != datastore.SortDesc {
+		return nil, fmt.Errorf("%w: %s (use: %s or %s)", ErrInvalidSortDirection, sortDirection,
+			datastore.SortAsc, datastore.SortDesc)
+	}
+	if len(orderBy) == 0 {
+		orderBy = defaultOrderBy
+	}
+	return &datastore.QueryParams{
+		OrderByField:  orderBy,
+		Page:          page,
+		PageSize:      pageSize,
+		SortDirection: sortDirection,
+	}, nil
+}
+
+// allPagesQueryParams will return the query params for a page when getting all the results (IE: getAllUtxos)
+//
+// The results are ordered by the id, ordering by a field with equal values (IE: satoshis or created_at)
+// can return the same row on two pages, or skip a row
+func allPagesQueryParams(page int) *datastore.QueryParams {
+	return &datastore.QueryParams{
+		OrderByField:  "id",
+		Page:          page,
+		PageSize:      maxPageSize,
+		SortDirection: datastore.SortAsc,
+	}
+}
+
+// displayPage will display the page information (stderr, only for the human-readable formats)
+func displayPage(queryParams *datastore.QueryParams, results int, total int64) {
+	if outputFormat != outputFormatPretty && outputFormat != outputFormatTable && len(outputFormat) > 0 {
+		return
+	}
+	pages := (total + int64(queryParams.PageSize) - 1) / int64(queryParams.PageSize)
+	chalker.LogTo(os.Stderr, chalker.INFO, fmt.Sprintf(
+		"Showing %d result(s) on page %d of %d (%d total)", results, queryParams.Page, pages, total,
+	))
+}
+
+// parseDate will parse a date flag (RFC3339 or YYYY-MM-DD)
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(dateFormatShort, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s (use: %s or %s)", ErrInvalidDate, value, dateFormatShort, time.RFC3339)
+	}
+	return t, nil
+}
+
+// heightRangeCondition will return the condition for a block height range (0 = not set)
+func heightRangeCondition(from, to uint64) (map[string]interface{}, error) {
+	if from > 0 && to > 0 && from > to {
+		return nil, fmt.Errorf("%w: height %d is after %d", ErrInvalidRange, from, to)
+	}
+	condition := make(map[string]interface{})
+	if from > 0 {
+		condition[conditionGreaterEq] = from
+	}
+	if to > 0 {
+		condition[conditionLessEq] = to
+	}
+	return condition, nil
+}
+
+// dateRangeCondition will return the condition for a date range (empty = not set)
+//
+// The end date is inclusive, a date without a time includes the whole day
+func dateRangeCondition(from, to string) (map[string]interface{}, error) {
+	condition := make(map[string]interface{})
+	var fromTime, toTime time.Time
+	var err error
+	if len(from) > 0 {
+		if fromTime, err = parseDate(from); err != nil {
+			return nil, err
+		}
+		condition[conditionGreaterEq] = nullTime(fromTime)
+	}
+	if len(to) > 0 {
+		if toTime, err = parseDate(to); err != nil {
+			return nil, err
+		}
+		if len(to) == len(dateFormatShort) {
+			toTime = toTime.Add(24*time.Hour - time.Nanosecond)
+		}
+		condition[conditionLessEq] = nullTime(toTime)
+	}
+	if !fromTime.IsZero() && !toTime.IsZero() && fromTime.After(toTime) {
+		return nil, fmt.Errorf("%w: date %s is after %s", ErrInvalidRange, from, to)
+	}
+	return condition, nil
+}
+
+// nullTime will return the time as a datastore time (formatted for each database engine)
+func nullTime(t time.Time) customTypes.NullTime {
+	return customTypes.NullTime{NullTime: sql.NullTime{Time: t.UTC(), Valid: true}}
+}
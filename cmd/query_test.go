@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mrz1836/go-datastore"
+	customTypes "github.com/mrz1836/go-datastore/custom_types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQueryParams(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid params", func(t *testing.T) {
+		queryParams, err := newQueryParams(2, 50, "", datastore.SortAsc)
+		require.NoError(t, err)
+		assert.Equal(t, &datastore.QueryParams{
+			OrderByField:  defaultOrderBy,
+			Page:          2,
+			PageSize:      50,
+			SortDirection: datastore.SortAsc,
+		}, queryParams)
+	})
+
+	t.Run("invalid page", func(t *testing.T) {
+		_, err := newQueryParams(0, defaultPageSize, defaultOrderBy, defaultSort)
+		require.ErrorIs(t, err, ErrInvalidPage)
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		_, err := newQueryParams(defaultPage, maxPageSize+1, defaultOrderBy, defaultSort)
+		require.ErrorIs(t, err, ErrInvalidPageSize)
+	})
+
+	t.Run("invalid sort direction", func(t *testing.T) {
+		_, err := newQueryParams(defaultPage, defaultPageSize, defaultOrderBy, "up")
+		require.ErrorIs(t, err, ErrInvalidSortDirection)
+	})
+}
+
+func TestDateRangeCondition(t *testing.T) {
+	t.Parallel()
+
+	t.Run("short dates include the whole end day", func(t *testing.T) {
+		condition, err := dateRangeCondition("2023-01-01", "2023-01-31")
+		require.NoError(t, err)
+		from := condition[conditionGreaterEq].(customTypes.NullTime)
+		to := condition[conditionLessEq].(customTypes.NullTime)
+		assert.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), from.Time)
+		assert.Equal(t, time.Date(2023, 1, 31, 23, 59, 59, 999999999, time.UTC), to.Time)
+	})
+
+	t.Run("rfc3339 dates are used as is", func(t *testing.T) {
+		condition, err := dateRangeCondition("", "2023-01-31T12:00:00Z")
+		require.NoError(t, err)
+		assert.NotContains(t, condition, conditionGreaterEq)
+		to := condition[conditionLessEq].(customTypes.NullTime)
+		assert.Equal(t, time.Date(2023, 1, 31, 12, 0, 0, 0, time.UTC), to.Time)
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		_, err := dateRangeCondition("01/31/2023", "")
+		require.ErrorIs(t, err, ErrInvalidDate)
+	})
+
+	t.Run("start after end", func(t *testing.T) {
+		_, err := dateRangeCondition("2023-02-01", "2023-01-01")
+		require.ErrorIs(t, err, ErrInvalidRange)
+	})
+}
+
+func TestHeightRangeCondition(t *testing.T) {
+	t.Parallel()
+
+	condition, err := heightRangeCondition(100, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{conditionGreaterEq: uint64(100)}, condition)
+
+	_, err = heightRangeCondition(200, 100)
+	require.ErrorIs(t, err, ErrInvalidRange)
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
-	"errors"
+	"fmt"
+	"os"
+	"strings"
 
 	"github.com/BuxOrg/bux-cli/chalker"
 	"github.com/BuxOrg/bux-cli/database"
@@ -28,36 +30,67 @@ This CLI app is used for interacting with BUX databases or servers.
 
 Learn more about BUX: https://GetBux.io
 `,
-	Version: Version,
+	SilenceErrors: true, // Errors are displayed by Execute() (stderr)
+	SilenceUsage:  true,
+	Version:       Version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat(outputFormat)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+//
+// Errors are displayed on stderr and the process exits with the mapped exit code (see: errors.go)
 func Execute() {
+	if err := execute(); err != nil {
+		displayError(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// execute will run the root command (deferred cleanup runs before the exit code is returned)
+func execute() error {
 
 	// Preprocess the command line arguments and flags before executing the root command
 	app := commandPreprocessor()
 	var err error
 
-	// Create a database connection (Don't require DB for now)
-	if app.database, err = database.Connect(applicationName, "db_"+applicationName); err != nil {
-		displayError(errors.New("error connecting to database: " + err.Error()))
+	// Create a database connection (Don't require DB for now, the offline commands do not use it)
+	if app.offline {
+		verboseLog(func() {
+			chalker.Log(chalker.INFO, "Running offline (no config or database)...")
+		})
+	} else if app.database, err = database.Connect(applicationName, "db_"+applicationName); err != nil {
+		displayError(fmt.Errorf("error connecting to database: %w", err))
 	} else {
 		// Defer the database disconnection
 		defer func(app *App) {
 			dbErr := app.database.GarbageCollection()
 			if dbErr != nil {
-				displayError(errors.New("error in database GarbageCollection: " + dbErr.Error()))
+				displayError(fmt.Errorf("error in database GarbageCollection: %w", dbErr))
 			}
 
 			if dbErr = app.database.Disconnect(); dbErr != nil {
-				displayError(errors.New("error in database disconnect: " + dbErr.Error()))
+				displayError(fmt.Errorf("error in database disconnect: %w", dbErr))
 			}
 		}(app)
 	}
 
+	// Flag errors are usage errors
+	rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return fmt.Errorf("%w: %s", ErrInvalidFlag, err.Error())
+	})
+
 	// Run root command
-	er(rootCmd.Execute())
+	if err = rootCmd.Execute(); err != nil {
+
+		// Cobra does not use a sentinel error for unknown commands
+		if strings.HasPrefix(err.Error(), "unknown command") {
+			return fmt.Errorf("%w: %s", ErrUnknownSubcommand, err.Error())
+		}
+		return err
+	}
 
 	// Generate documentation from all commands
 	if generateDocs {
@@ -65,11 +98,12 @@ func Execute() {
 	}
 
 	// Flush cache if requested and database is connected
-	if flushCache && app.database.Connected {
+	if flushCache && app.database != nil && app.database.Connected {
 		if dbErr := app.database.Flush(); dbErr != nil {
-			displayError(errors.New("error in database Flush: " + dbErr.Error()))
+			displayError(fmt.Errorf("error in database Flush: %w", dbErr))
 		} else {
 			chalker.Log(chalker.SUCCESS, "Successfully flushed the local database cache")
 		}
 	}
+	return nil
 }
@@ -2,26 +2,39 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/BuxOrg/bux"
 	"github.com/BuxOrg/bux-cli/chalker"
-	"github.com/BuxOrg/bux/taskmanager"
 	"github.com/bitcoinschema/go-bitcoin/v2"
 	"github.com/fatih/color"
 	"github.com/libsv/go-bk/bip32"
+	"github.com/libsv/go-bt/v2"
+	"github.com/mrz1836/go-datastore"
 	"github.com/spf13/cobra"
 )
 
 // commands for transaction
+const transactionCommandDecode = "decode"
+const transactionCommandEstimate = "estimate"
 const transactionCommandInfo = "info"
+const transactionCommandList = "list"
 const transactionCommandName = "transaction"
 const transactionCommandNew = "new"
 const transactionCommandRecord = "record"
 const transactionCommandSend = "send"
+const transactionCommandSign = "sign"
 const transactionCommandTasks = "tasks"
+const transactionCommandUpdateMetadata = "update-metadata"
+
+// transaction statuses (filters for listing)
+const transactionStatusConfirmed = "confirmed"
+const transactionStatusUnconfirmed = "unconfirmed"
 
 // returnTransactionCmd returns the transaction command
 func returnTransactionCmd(app *App) (newCmd *cobra.Command) {
@@ -39,67 +52,142 @@ _____________________    _____    _______    _________   _____  ________________
 ` + color.YellowString(`
 This command is for transaction related commands.
 
-new: returns a draft transaction to be used for recording (`+transactionCommandName+` `+transactionCommandNew+` <xpub> -m=<metadata> -c=<tx_config>)
-record: records a new transaction in BUX (`+transactionCommandName+` `+transactionCommandRecord+` <xpub> -i=<tx_id>)
-send: creates a new transaction in BUX and signs & broadcasts (`+transactionCommandName+` `+transactionCommandSend+` <xpub> --txconfig='' --xpriv='')
+new: returns a draft transaction to be used for recording (`+transactionCommandName+` `+transactionCommandNew+` <xpub> --to=<address|paymail> --sats=1000 --`+flagOut+`=draft.json)
+estimate: estimates the inputs, fee, change and size without reserving utxos (`+transactionCommandName+` `+transactionCommandEstimate+` <xpub> --to=<address|paymail> --sats=1000)
+sign: signs an exported draft offline, no config or database is needed (`+transactionCommandName+` `+transactionCommandSign+` draft.json --key=<name> --`+flagOut+`=signed.hex)
+record: records a new transaction in BUX (`+transactionCommandName+` `+transactionCommandRecord+` <xpub> -i=<tx_id> | --draft=<draft_id> --`+flagTxHexFile+`=signed.hex)
+send: creates a new transaction in BUX and signs & broadcasts (`+transactionCommandName+` `+transactionCommandSend+` <xpub> --to=<address|paymail> --sats=1000 --key=<name>)
+decode: decodes a transaction (hex, txid or `+stdinPath+` for stdin), --`+flagOffline+` runs without BUX (`+transactionCommandName+` `+transactionCommandDecode+` <hex|tx_id>)
 info: returns all information about transaction in BUX (`+transactionCommandName+` `+transactionCommandInfo+` <xpub_id> -i=<tx_id>)
-tasks: runs all registered tasks locally if in DB mode (`+transactionCommandName+` `+transactionCommandTasks+`)
+list: returns the transactions for an xpub (`+transactionCommandName+` `+transactionCommandList+` <xpub_id> --direction=incoming --page=1)
+update-metadata: updates (merges) the metadata of a transaction for an xpub (`+transactionCommandName+` `+transactionCommandUpdateMetadata+` <xpub_id> -i=<tx_id> -m=<metadata_json>)
+tasks: runs all registered tasks once if in DB mode, see the worker command (`+transactionCommandName+` `+transactionCommandTasks+`)
 `),
 		Aliases: []string{"tx"},
 		Example: applicationName + " " + transactionCommandRecord + " <xpub> -i=<tx_id>",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) < 1 {
-				return chalker.Error(transactionCommandName + " requires a subcommand, IE: " + transactionCommandRecord + ", etc.")
+				return fmt.Errorf("%s %w, IE: %s, etc.", transactionCommandName, ErrSubcommandIsRequired, transactionCommandRecord)
 			}
 			return nil
 		},
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Sign an exported draft (does not require BUX, IE: on an air-gapped machine)
+			if args[0] == transactionCommandSign {
+
+				// Check if the draft file is provided
+				if len(args) < 2 {
+					return ErrDraftFileIsRequired
+				}
+
+				// Get the xpriv from the flag or the keystore (--key)
+				xprivKey, err := getXpriv(xpriv)
+				if err != nil {
+					return err
+				} else if len(xprivKey) == 0 {
+					return ErrXprivIsRequired
+				}
+
+				// Read the draft, verify the inputs belong to the xpriv and sign
+				var draft *bux.DraftTransaction
+				if draft, err = readDraftFile(args[1]); err != nil {
+					return err
+				}
+				var signed *SignedTransaction
+				if signed, err = signDraft(draft, xprivKey); err != nil {
+					return err
+				}
+
+				// Display the signed transaction, or write the hex for recording
+				if len(outFile) == 0 {
+					return displayModel(signed)
+				} else if err = os.WriteFile(outFile, []byte(signed.Hex+"\n"), offlineFileMode); err != nil {
+					return fmt.Errorf("error writing hex file: %w", err)
+				}
+				chalker.LogTo(os.Stderr, chalker.SUCCESS, fmt.Sprintf(
+					"Signed transaction %s written to %s, record it with: %s %s %s <xpub> --%s=%s --%s=%s",
+					signed.TxID, outFile, applicationName, transactionCommandName, transactionCommandRecord,
+					flagTxDraftID, signed.DraftID, flagTxHexFile, outFile,
+				))
+				return nil
+			}
+
+			// Decode a transaction (does not require BUX when offline, the inputs are not resolved)
+			if args[0] == transactionCommandDecode {
+
+				// Check if the transaction is provided
+				if len(args) < 2 {
+					return ErrTransactionIsRequired
+				}
+				rawTx := args[1]
+				if rawTx == stdinPath {
+					var err error
+					if rawTx, err = readHexFile(stdinPath, os.Stdin); err != nil {
+						return err
+					}
+				}
+				if decodeOffline && len(rawTx) == txIDLength {
+					return fmt.Errorf("%w: --%s needs the transaction hex, not the txid", ErrInvalidFlag, flagOffline)
+				}
+
+				// Initialize the BUX client (WhatsOnChain and the destinations)
+				if !decodeOffline {
+					deferFunc, err := app.InitializeBUX()
+					if err != nil {
+						return err
+					}
+					defer deferFunc()
+				}
+
+				decoded, err := decodeRawTransaction(context.Background(), app, rawTx, !decodeOffline)
+				if err != nil {
+					return err
+				}
+				return displayModel(decoded)
+			}
 
 			// Initialize the BUX client
-			deferFunc := app.InitializeBUX()
+			deferFunc, err := app.InitializeBUX()
+			if err != nil {
+				return err
+			}
 			defer deferFunc()
 
 			// Parse Metadata
-			var err error
-			if metadata, err = cmd.Flags().GetString(flagMetadata); err != nil {
-				displayError(errors.New("error parsing metadata: " + err.Error()))
-				return
+			var metaData bux.Metadata
+			if metaData, err = getMetadataFlag(cmd); err != nil {
+				return err
 			}
 
 			// Get the transaction ID
 			if txID, err = cmd.Flags().GetString(flagTxID); err != nil {
-				displayError(errors.New("error getting txid: " + err.Error()))
-				return
+				return fmt.Errorf("error getting txid: %w", err)
 			}
 
 			// Get the transaction hex from the flags
 			if txHex, err = cmd.Flags().GetString(flagTxHex); err != nil {
-				displayError(errors.New("error getting hex: " + err.Error()))
-				return
+				return fmt.Errorf("error getting hex: %w", err)
 			}
 
 			// Get the optional draft id from the flags
 			if draftID, err = cmd.Flags().GetString(flagTxDraftID); err != nil {
-				displayError(errors.New("error getting draft id: " + err.Error()))
-				return
+				return fmt.Errorf("error getting draft id: %w", err)
 			}
 
 			// Get the transaction config from the flags
 			if txConfig, err = cmd.Flags().GetString(flagTxConfig); err != nil {
-				displayError(errors.New("error getting config: " + err.Error()))
-				return
+				return fmt.Errorf("error getting config: %w", err)
 			}
 
 			// Get the xpriv from the flags
 			if xpriv, err = cmd.Flags().GetString(flagXpriv); err != nil {
-				displayError(errors.New("error getting xpriv: " + err.Error()))
-				return
+				return fmt.Errorf("error getting xpriv: %w", err)
 			}
 
 			// Get the optional woc flag from the flags
 			if wocEnabled, err = cmd.Flags().GetBool(flagWoc); err != nil {
-				displayError(errors.New("error getting woc flag: " + err.Error()))
-				return
+				return fmt.Errorf("error getting woc flag: %w", err)
 			}
 
 			// Switch on the subcommand
@@ -107,58 +195,124 @@ tasks: runs all registered tasks locally if in DB mode (`+transactionCommandName
 
 				// Check if xpub is provided
 				if len(args) < 2 {
-					displayError(ErrXpubIsRequired)
-					return
+					return ErrXpubIsRequired
+				}
+
+				// Stdin can only be read once
+				if txHexFile == stdinPath && metadataFile == stdinPath {
+					return fmt.Errorf("%w: --%s and --%s cannot both read from stdin",
+						ErrInvalidFlag, flagTxHexFile, flagMetadataFile)
+				}
+
+				// Record many transactions from a file (one hex per line), with a result per line
+				if txRecordBulk {
+					if len(txHexFile) == 0 {
+						return fmt.Errorf("%w: --%s needs --%s=<file>", ErrInvalidFlag, flagBulk, flagTxHexFile)
+					} else if len(draftID) > 0 || len(txID) > 0 || len(txHex) > 0 {
+						return fmt.Errorf("%w: --%s cannot be combined with --%s, --%s or --%s",
+							ErrInvalidFlag, flagBulk, flagTxDraftID, flagTxID, flagTxHex)
+					}
+					var content string
+					if content, err = readHexFile(txHexFile, os.Stdin); err != nil {
+						return err
+					}
+					results := recordTransactions(context.Background(), app, args[1], content, metaData)
+					if err = displayModel(results); err != nil {
+						return err
+					}
+					return recordResultsError(results)
+				}
+
+				// Read the transaction hex from the file or stdin (IE: signed offline with the sign command)
+				if len(txHexFile) > 0 {
+					if len(txHex) > 0 {
+						return fmt.Errorf("%w: use --%s or --%s, not both", ErrInvalidFlag, flagTxHex, flagTxHexFile)
+					} else if txHex, err = readHexFile(txHexFile, os.Stdin); err != nil {
+						return err
+					}
 				}
 
 				// Record the transaction
 				var tx *Transaction
-				tx, err = recordTransaction(context.Background(), app, args[1], draftID, metadata, txID, txHex)
+				tx, err = recordTransaction(context.Background(), app, args[1], draftID, txID, txHex, metaData)
 				if err != nil {
-					displayError(err)
-					return
+					return err
 				}
 
 				// Display the transaction
-				displayModel(tx)
+				return displayModel(tx)
 			} else if args[0] == transactionCommandInfo { // get transaction info
 
 				// Check if xpub id is provided
 				if len(args) < 2 {
-					displayError(ErrXpubIDIsRequired)
-					return
+					return ErrXpubIDIsRequired
 				}
 
 				// Get the transaction info
 				var tx *Transaction
 				tx, err = getTransaction(context.Background(), app, args[1], txID, wocEnabled)
 				if err != nil {
-					displayError(err)
-					return
+					return err
 				}
 
 				// Display the transaction
-				displayModel(tx)
+				return displayModel(tx)
+			} else if args[0] == transactionCommandList { // list transactions
+
+				// Check if xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubIDIsRequired
+				}
+
+				// Build the filters and the pagination
+				var conditions map[string]interface{}
+				if conditions, err = transactionConditions(
+					txStatus, txDirection, fromHeight, toHeight, fromDate, toDate,
+				); err != nil {
+					return err
+				}
+				var queryParams *datastore.QueryParams
+				if queryParams, err = newQueryParams(page, pageSize, orderBy, sortDirection); err != nil {
+					return err
+				}
+
+				// Get the transactions
+				var transactions []*bux.Transaction
+				var total int64
+				if transactions, total, err = listTransactions(
+					context.Background(), app, args[1], metaData, conditions, queryParams,
+				); err != nil {
+					return err
+				}
+
+				// Display the transactions
+				displayPage(queryParams, len(transactions), total)
+				return displayModel(transactions)
 			} else if args[0] == transactionCommandSend { // send a transaction
 
 				// Check if xpub is provided
 				if len(args) < 2 {
-					displayError(ErrXpubIsRequired)
-					return
+					return ErrXpubIsRequired
 				}
 
-				// Check that xpriv is provided
-				if len(xpriv) <= 0 {
-					displayError(ErrXprivIsRequired)
-					return
+				// Get the xpriv from the flag or the keystore (--key)
+				if xpriv, err = getXpriv(xpriv); err != nil {
+					return err
+				} else if len(xpriv) <= 0 {
+					return ErrXprivIsRequired
+				}
+
+				// Build the transaction config
+				var config *bux.TransactionConfig
+				if config, err = getTransactionConfig(); err != nil {
+					return err
 				}
 
 				// Create a new draft transaction
 				var draft *bux.DraftTransaction
-				draft, err = newTransaction(context.Background(), app, args[1], txConfig, metadata)
+				draft, err = newTransaction(context.Background(), app, args[1], config, metaData)
 				if err != nil {
-					displayError(err)
-					return
+					return err
 				}
 				draftID = draft.ID
 
@@ -166,71 +320,121 @@ tasks: runs all registered tasks locally if in DB mode (`+transactionCommandName
 				var xprivKey *bip32.ExtendedKey
 				xprivKey, err = bitcoin.GenerateHDKeyFromString(xpriv)
 				if err != nil {
-					displayError(err)
-					return
+					return err
 				}
 
 				// Sign the inputs and get the hex
 				txHex, err = draft.SignInputs(xprivKey)
 				if err != nil {
-					displayError(err)
-					return
+					return err
 				}
 
 				// Record the transaction
 				var tx *Transaction
-				tx, err = recordTransaction(context.Background(), app, args[1], draftID, metadata, "", txHex)
+				tx, err = recordTransaction(context.Background(), app, args[1], draftID, "", txHex, metaData)
 				if err != nil {
-					displayError(err)
-					return
+					return err
 				}
 
 				// Display the transaction
-				displayModel(tx)
+				return displayModel(tx)
 
 			} else if args[0] == transactionCommandNew { // create a new draft transaction
 
 				// Check if xpub is provided
 				if len(args) < 2 {
-					displayError(ErrXpubIsRequired)
-					return
+					return ErrXpubIsRequired
+				}
+
+				// Build the transaction config
+				var config *bux.TransactionConfig
+				if config, err = getTransactionConfig(); err != nil {
+					return err
 				}
 
 				// Create a new draft transaction
 				var draft *bux.DraftTransaction
-				draft, err = newTransaction(context.Background(), app, args[1], txConfig, metadata)
+				draft, err = newTransaction(context.Background(), app, args[1], config, metaData)
 				if err != nil {
-					displayError(err)
-					return
+					return err
+				}
+
+				// Display the draft, or export it for signing offline
+				if len(outFile) == 0 {
+					return displayModel(draft)
+				} else if err = writeDraftFile(outFile, draft); err != nil {
+					return err
+				}
+				chalker.LogTo(os.Stderr, chalker.SUCCESS, fmt.Sprintf(
+					"Draft %s written to %s (expires at %s), sign it with: %s %s %s %s --%s=<name>",
+					draft.ID, outFile, draft.ExpiresAt.Format(time.RFC3339), applicationName, transactionCommandName,
+					transactionCommandSign, outFile, flagKey,
+				))
+				return nil
+			} else if args[0] == transactionCommandEstimate { // estimate a new transaction (the draft is canceled)
+
+				// Check if xpub is provided
+				if len(args) < 2 {
+					return ErrXpubIsRequired
 				}
 
-				// Display the draft
-				displayModel(draft)
-			} else if args[0] == transactionCommandTasks { // run all tasks
+				// Build the transaction config
+				var config *bux.TransactionConfig
+				if config, err = getTransactionConfig(); err != nil {
+					return err
+				}
 
-				// todo: need a better approach to running "all available" tasks
-				// issue: currently cannot keep the process running, so it will exit after the first task is run
-				// There is also no way to get the total tasks in the queue, so we cannot determine when to exit
+				// Estimate the transaction and compare the miner fees
+				var estimate *TransactionEstimate
+				if estimate, err = estimateTransaction(context.Background(), app, args[1], config); err != nil {
+					return err
+				}
+				return displayModel(estimate)
+			} else if args[0] == transactionCommandTasks { // run all tasks (same as: worker --once)
+
+				// Tasks are only available with a local BUX engine
+				if app.bux == nil {
+					return ErrNotSupportedInServerMode
+				} else if app.bux.Taskmanager() == nil {
+					return bux.ErrTaskManagerNotLoaded
+				}
 
-				chalker.Log(chalker.INFO, "Running all tasks...")
+				// Run all the tasks a single time
+				var tasks []*workerTask
+				if tasks, err = selectWorkerTasks(registeredWorkerTasks(app.bux), nil); err != nil {
+					return err
+				}
+				chalker.LogTo(os.Stderr, chalker.INFO, fmt.Sprintf("Running %d task(s)... (to keep running use: %s %s)",
+					len(tasks), applicationName, workerCommandName))
+				return runWorkerTasksOnce(context.Background(), tasks)
+			} else if args[0] == transactionCommandUpdateMetadata { // update the metadata of a transaction
 
-				// Run all tasks
-				if err = runAllTasks(context.Background(), app); err != nil {
-					displayError(err)
-					return
+				// Check if xpub id and the transaction id are provided
+				if len(args) < 2 {
+					return ErrXpubIDIsRequired
+				} else if len(txID) == 0 {
+					return ErrTransactionIDIsRequired
 				}
 
-				time.Sleep(5 * time.Second)
-				chalker.Log(chalker.SUCCESS, "All 4 tasks complete.")
+				// Update the metadata
+				var tx *Transaction
+				if tx, err = updateTransactionMetadata(
+					context.Background(), app, args[1], txID, metaData, metadataDelete, metadataReplace,
+				); err != nil {
+					return fmt.Errorf("error updating transaction metadata: %w", err)
+				}
 
-			} else {
-				displayError(ErrUnknownSubcommand)
+				// Display the transaction
+				return displayModel(tx)
 			}
+
+			return ErrUnknownSubcommand
 		},
 	}
 
-	// Set the metadata flag
-	newCmd.Flags().StringVarP(&metadata, flagMetadata, flagMetadataShort, "", "Model Metadata")
+	// Set the metadata flags
+	addMetadataFlag(newCmd)
+	addMetadataUpdateFlags(newCmd)
 
 	// Set the transaction ID flag
 	newCmd.Flags().StringVarP(&txID, flagTxID, flagTxIDShort, "", "Transaction ID")
@@ -238,14 +442,36 @@ tasks: runs all registered tasks locally if in DB mode (`+transactionCommandName
 	// Set the transaction hex flag
 	newCmd.Flags().StringVarP(&txHex, flagTxHex, flagTxHexShort, "", "Transaction Hex")
 
+	// Set the transaction hex file flags (IE: signed offline)
+	newCmd.Flags().StringVar(&txHexFile, flagTxHexFile, "",
+		"File with the transaction hex (use "+stdinPath+" for stdin, instead of --"+flagTxHex+")")
+	newCmd.Flags().BoolVar(&txRecordBulk, flagBulk, false,
+		"Record a transaction for each line of the --"+flagTxHexFile+" (one hex per line)")
+
 	// Set the transaction draft flag
-	newCmd.Flags().StringVarP(&txHex, flagTxDraftID, flagTxDraftIDShort, "", "Draft ID (optional)")
+	newCmd.Flags().StringVarP(&draftID, flagTxDraftID, flagTxDraftIDShort, "", "Draft ID (optional)")
 
 	// Set the transaction config flag
 	newCmd.Flags().StringVarP(&txConfig, flagTxConfig, flagTxConfigShort, "", "Transaction Configuration")
 
+	// Set the output flags (built into the transaction config, merged with --txconfig)
+	newCmd.Flags().StringArrayVar(&txRecipients, flagTo, nil,
+		"Recipient address, paymail, $handle or locking script (hex), repeat for multiple recipients")
+	newCmd.Flags().UintSliceVar(&txSatoshis, flagSatoshis, nil,
+		"Satoshis to send, one value for all recipients or one per recipient (in order)")
+	newCmd.Flags().StringArrayVar(&txOpReturns, flagOpReturn, nil,
+		"OP_RETURN data (hex or utf8), repeat for multiple data parts")
+	newCmd.Flags().Float64Var(&txFeePerByte, flagFeePerByte, 0, "Fee in satoshis per byte (IE: 0.05)")
+	newCmd.Flags().IntVar(&txChangeDestinations, flagChangeDests, 0, "Number of change destinations to create")
+	newCmd.Flags().BoolVar(&txSendAll, flagSendAll, false, "Send all the spendable utxos to the recipient (--to)")
+
+	// Set the export flag (the draft for new, the signed hex for sign)
+	newCmd.Flags().StringVar(&outFile, flagOut, "",
+		"File to write the draft (JSON) for signing offline, or the signed hex (sign)")
+
 	// Set the xpriv
 	newCmd.Flags().StringVarP(&xpriv, flagXpriv, flagXprivShort, "", "Xpriv used for signing the transaction")
+	newCmd.Flags().StringVar(&keyName, flagKey, "", "Name of the keystore key used for signing (instead of --xpriv)")
 
 	// Set the woc flag
 	newCmd.Flags().BoolP(
@@ -253,59 +479,116 @@ tasks: runs all registered tasks locally if in DB mode (`+transactionCommandName
 		"Optional flag to use WhatsOnChain for additional transaction data",
 	)
 
+	// Set the decode flag
+	newCmd.Flags().BoolVar(&decodeOffline, flagOffline, false,
+		"Decode without BUX (decode), the inputs and the BUX destinations are not resolved")
+
+	// Set the list filters
+	newCmd.Flags().StringVar(&txStatus, flagStatus, "", "Filter by status: "+
+		transactionStatusConfirmed+" or "+transactionStatusUnconfirmed)
+	newCmd.Flags().StringVar(&txDirection, flagDirection, "", "Filter by direction: "+
+		string(bux.TransactionDirectionIn)+", "+string(bux.TransactionDirectionOut)+" or "+
+		string(bux.TransactionDirectionReconcile))
+	newCmd.Flags().Uint64Var(&fromHeight, flagFromHeight, 0, "Filter by block height (from, inclusive)")
+	newCmd.Flags().Uint64Var(&toHeight, flagToHeight, 0, "Filter by block height (to, inclusive)")
+	newCmd.Flags().StringVar(&fromDate, flagFromDate, "", "Filter by created date (from, YYYY-MM-DD or RFC3339)")
+	newCmd.Flags().StringVar(&toDate, flagToDate, "", "Filter by created date (to, YYYY-MM-DD or RFC3339)")
+	addPaginationFlags(newCmd)
+
 	return
 }
 
-// newTransaction creates a new draft transaction
-func newTransaction(ctx context.Context, app *App,
-	xpubKey, txConfigJSON, metadata string) (draft *bux.DraftTransaction, err error) {
+// transactionConditions will build the conditions for listing transactions from the filters
+func transactionConditions(status, direction string, fromHeight, toHeight uint64,
+	fromDate, toDate string) (map[string]interface{}, error) {
 
-	// Get the xpub
-	var xpub *bux.Xpub
-	xpub, err = app.bux.GetXpub(ctx, xpubKey)
-	if err != nil {
-		return
-	} else if xpub == nil {
-		return
+	conditions := make(map[string]interface{})
+	var and []map[string]interface{}
+
+	// Direction is handled by BUX (using the xpub output value)
+	if len(direction) > 0 {
+		switch bux.TransactionDirection(direction) {
+		case bux.TransactionDirectionIn, bux.TransactionDirectionOut, bux.TransactionDirectionReconcile:
+			conditions["direction"] = direction
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTransactionDirection, direction)
+		}
 	}
 
-	// Parse the tx config from JSON
-	var txConfigModel *bux.TransactionConfig
-	err = json.Unmarshal([]byte(txConfigJSON), &txConfigModel)
+	// Status is based on the block height (unconfirmed transactions have no block height)
+	switch status {
+	case "":
+	case transactionStatusConfirmed:
+		and = append(and, map[string]interface{}{"block_height": map[string]interface{}{conditionGreaterThan: 0}})
+	case transactionStatusUnconfirmed:
+		if fromHeight > 0 || toHeight > 0 {
+			return nil, fmt.Errorf("%w: %s cannot be combined with a block height range", ErrInvalidRange, status)
+		}
+		and = append(and, map[string]interface{}{"$or": []map[string]interface{}{
+			{"block_height": 0}, {"block_height": nil},
+		}})
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTransactionStatus, status)
+	}
+
+	// Block height range
+	heightRange, err := heightRangeCondition(fromHeight, toHeight)
 	if err != nil {
-		return
+		return nil, err
+	} else if len(heightRange) > 0 {
+		and = append(and, map[string]interface{}{"block_height": heightRange})
+	}
+
+	// Date range
+	var dateRange map[string]interface{}
+	if dateRange, err = dateRangeCondition(fromDate, toDate); err != nil {
+		return nil, err
+	} else if len(dateRange) > 0 {
+		and = append(and, map[string]interface{}{"created_at": dateRange})
 	}
 
-	// Get the metadata if provided
-	modelOps := app.bux.DefaultModelOptions()
-	if len(metadata) > 0 {
-		modelOps = append(modelOps, bux.WithMetadataFromJSON([]byte(metadata)))
+	if len(and) > 0 {
+		conditions["$and"] = and
+	}
+	return conditions, nil
+}
+
+// listTransactions gets a page of transactions for the xpub and the total count matching the filters
+func listTransactions(ctx context.Context, app *App, xpubID string, metaData bux.Metadata,
+	conditions map[string]interface{},
+	queryParams *datastore.QueryParams) (transactions []*bux.Transaction, total int64, err error) {
+
+	// Get the transactions
+	if transactions, err = app.backend.GetTransactions(
+		ctx, xpubID, metadataConditions(metaData), conditions, queryParams,
+	); err != nil {
+		return
 	}
 
+	// Get the total count
+	total, err = app.backend.GetTransactionsCount(ctx, xpubID, metadataConditions(metaData), conditions)
+	return
+}
+
+// newTransaction creates a new draft transaction
+func newTransaction(ctx context.Context, app *App, xpubKey string,
+	config *bux.TransactionConfig, metaData bux.Metadata) (draft *bux.DraftTransaction, err error) {
+
 	// Create a new draft transaction
-	draft, err = app.bux.NewTransaction(ctx, xpubKey, txConfigModel, modelOps...)
+	draft, err = app.backend.NewTransaction(ctx, xpubKey, config, metaData)
 
 	return
 }
 
 // recordTransaction records a new transaction
 func recordTransaction(ctx context.Context, app *App, xpubKey,
-	draftID, metadata, txID, txHex string) (tx *Transaction, err error) {
-
-	tx = new(Transaction)
-
-	// Get the xpub
-	var xpub *bux.Xpub
-	xpub, err = app.bux.GetXpub(ctx, xpubKey)
-	if err != nil {
-		return
-	} else if xpub == nil {
-		return
-	}
+	draftID, txID, txHex string, metaData bux.Metadata) (tx *Transaction, err error) {
 
 	// Check if txID or txHex is provided
 	if len(txHex) == 0 && len(txID) == 0 {
-		return
+		return nil, ErrTransactionIDOrHexIsRequired
+	} else if len(txHex) > 0 && len(txID) > 0 {
+		return nil, fmt.Errorf("%w: use --%s or --%s, not both", ErrInvalidFlag, flagTxID, flagTxHex)
 	}
 
 	// Check if txID is provided
@@ -316,31 +599,105 @@ func recordTransaction(ctx context.Context, app *App, xpubKey,
 		})
 
 		// Get the transaction hex from the txID using the WhatsOnChain API
-		txHex, err = app.bux.Chainstate().WhatsOnChain().GetRawTransactionData(ctx, txID)
+		txHex, err = app.WhatsOnChain().GetRawTransactionData(ctx, txID)
 		if err != nil {
 			return
 		}
 	}
 
-	// Get the metadata if provided
-	modelOps := app.bux.DefaultModelOptions()
-	if len(metadata) > 0 {
-		modelOps = append(modelOps, bux.WithMetadataFromJSON([]byte(metadata)))
+	// The hex must be a valid transaction
+	if _, err = parseTransactionHex(txHex); err != nil {
+		return
 	}
 
 	// Record the transaction
-	tx.Bux, err = app.bux.RecordTransaction(ctx, xpubKey, txHex, draftID, modelOps...)
+	tx = new(Transaction)
+	tx.Bux, err = app.backend.RecordTransaction(ctx, xpubKey, txHex, draftID, metaData)
 
 	return
 }
 
+// recordTransactions records a transaction for each line of the content (one hex per line, empty lines are skipped)
+//
+// A failed line does not stop the other lines, the result of each line is returned
+func recordTransactions(ctx context.Context, app *App, xpubKey, content string,
+	metaData bux.Metadata) []*RecordResult {
+	results := make([]*RecordResult, 0)
+	for index, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		result := &RecordResult{Line: index + 1}
+		results = append(results, result)
+
+		// Parse the hex (invalid lines are not sent to BUX)
+		parsed, err := parseTransactionHex(line)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.TxID = parsed.TxID()
+
+		// Record the transaction
+		if _, err = app.backend.RecordTransaction(ctx, xpubKey, line, "", metaData); err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.Recorded = true
+	}
+	return results
+}
+
+// recordResultsError will return an error if any of the transactions failed to record (bulk)
+func recordResultsError(results []*RecordResult) error {
+	var failed []string
+	for _, result := range results {
+		if !result.Recorded {
+			failed = append(failed, strconv.Itoa(result.Line))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %d of %d (lines: %s)", ErrRecordFailed, len(failed), len(results),
+			strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// readHexFile will read the transaction hex from the file, or from stdin if the path is "-"
+// (surrounding whitespace is removed)
+func readHexFile(path string, stdin io.Reader) (string, error) {
+	var data []byte
+	var err error
+	if path == stdinPath {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path) //nolint:gosec // the path is provided by the user
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading hex file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// parseTransactionHex will parse the hex into a transaction (with at least one input and one output)
+func parseTransactionHex(txHex string) (*bt.Tx, error) {
+	tx, err := bt.NewTxFromString(txHex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTransactionHex, err.Error())
+	} else if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return nil, fmt.Errorf("%w: the transaction has %d inputs and %d outputs",
+			ErrInvalidTransactionHex, len(tx.Inputs), len(tx.Outputs))
+	}
+	return tx, nil
+}
+
 // getTransaction gets a transaction by ID and optionally fetches additional data from WhatsOnChain
 func getTransaction(ctx context.Context, app *App,
 	xpubID, txID string, wocEnabled bool) (tx *Transaction, err error) {
 
 	// Get the transaction info
 	tx = new(Transaction)
-	tx.Bux, err = app.bux.GetTransaction(ctx, xpubID, txID)
+	tx.Bux, err = app.backend.GetTransaction(ctx, xpubID, txID)
 	if err != nil {
 		return
 	}
@@ -353,7 +710,7 @@ func getTransaction(ctx context.Context, app *App,
 		})
 
 		// Get the transaction info from the txHex using the WhatsOnChain API
-		tx.WOC, err = app.bux.Chainstate().WhatsOnChain().GetTxByHash(ctx, txID)
+		tx.WOC, err = app.WhatsOnChain().GetTxByHash(ctx, txID)
 		if err != nil {
 			return
 		}
@@ -362,38 +719,62 @@ func getTransaction(ctx context.Context, app *App,
 	return
 }
 
-// runTask runs the given task
-func runTask(ctx context.Context, app *App, taskName string) (err error) {
-	tm := app.bux.Taskmanager()
-	err = tm.RunTask(ctx, &taskmanager.TaskOptions{
-		Arguments: []interface{}{app.bux},
-		TaskName:  taskName,
-	})
-	return
-}
-
-// runAllTasks runs all tasks like transaction clean up, incoming transaction, sync transaction
-func runAllTasks(ctx context.Context, app *App) (err error) {
-
-	// Run transaction clean up task
-	if err = runTask(ctx, app, "draft_transaction_clean_up"); err != nil {
-		return
+// updateTransactionMetadata updates the metadata of a transaction for the xpub
+//
+// The metadata is merged, unless replace is set (the current metadata is needed to remove the other keys).
+// BUX only updates the metadata of the xpub, the global metadata of the transaction is kept and not displayed
+func updateTransactionMetadata(ctx context.Context, app *App, xpubID, txID string, metaData bux.Metadata,
+	deleteKeys []string, replace bool) (tx *Transaction, err error) {
+
+	// Get the current metadata (to replace it)
+	database := app.bux != nil
+	var current bux.Metadata
+	if replace {
+		var existing *bux.Transaction
+		if existing, err = app.backend.GetTransaction(ctx, xpubID, txID); err != nil {
+			return
+		}
+		current = xpubTransactionMetadata(existing, xpubID, database)
 	}
 
-	// Run incoming transaction process task
-	if err = runTask(ctx, app, "incoming_transaction_process"); err != nil {
+	// Update the metadata
+	var update bux.Metadata
+	if update, err = metadataUpdate(current, metaData, deleteKeys, replace); err != nil {
 		return
 	}
-
-	// Run transaction sync task
-	if err = runTask(ctx, app, "sync_transaction_sync"); err != nil {
+	tx = new(Transaction)
+	if tx.Bux, err = app.backend.UpdateTransactionMetadata(ctx, xpubID, txID, update); err != nil {
 		return
 	}
+	tx.Bux.Metadata = xpubTransactionMetadata(tx.Bux, xpubID, database)
+	return
+}
 
-	// Run transaction broadcast task
-	if err = runTask(ctx, app, "sync_transaction_broadcast"); err != nil {
-		return
+// xpubTransactionMetadata will return the metadata of the transaction that belongs to the xpub
+//
+// The database stores the metadata per xpub (without the global metadata), the server returns the
+// metadata of the xpub that is authenticated merged with the global metadata
+func xpubTransactionMetadata(tx *bux.Transaction, xpubID string, database bool) bux.Metadata {
+	metadata := make(bux.Metadata)
+	if tx == nil {
+		return metadata
+	}
+	source := tx.Metadata
+	if database {
+		source = tx.XpubMetadata[xpubID]
 	}
+	for key, value := range source {
+		metadata[key] = value
+	}
+	return metadata
+}
 
-	return
+// transactionOutputValue will return the output value of the transaction for the xpub (negative for outgoing)
+//
+// The database stores the value per xpub, the server returns the value of the xpub that is authenticated
+func transactionOutputValue(tx *bux.Transaction, xpubID string) int64 {
+	if value, ok := tx.XpubOutputValue[xpubID]; ok {
+		return value
+	}
+	return tx.OutputValue
 }
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/bitcoinschema/go-bitcoin/v2"
+	"github.com/tonicpow/go-paymail"
+)
+
+// feeUnitBytes is the number of bytes used for the fee unit when using --fee-per-byte (allows fractions)
+const feeUnitBytes = 1000
+
+// txConfigFlags are the flags used to build the transaction config (instead of the raw JSON)
+type txConfigFlags struct {
+	changeDestinations int      // Number of change destinations (0 = BUX default)
+	feePerByte         float64  // Fee in satoshis per byte (0 = chainstate default)
+	opReturns          []string // OP_RETURN data parts (hex or utf8)
+	recipients         []string // Address, paymail, $handle or locking script (hex)
+	satoshis           []uint   // Satoshis for each recipient (or one value for all recipients)
+	sendAll            bool     // Send all the utxos to the (single) recipient
+}
+
+// getTransactionConfig will build the transaction config from the --txconfig and the output flags
+func getTransactionConfig() (*bux.TransactionConfig, error) {
+	return newTransactionConfig(txConfig, &txConfigFlags{
+		changeDestinations: txChangeDestinations,
+		feePerByte:         txFeePerByte,
+		opReturns:          txOpReturns,
+		recipients:         txRecipients,
+		satoshis:           txSatoshis,
+		sendAll:            txSendAll,
+	})
+}
+
+// newTransactionConfig will parse the raw config (if provided) and merge the flags into the config
+//
+// Outputs from the flags are added after the outputs of the raw config, the other flags override the raw config
+func newTransactionConfig(txConfigJSON string, flags *txConfigFlags) (*bux.TransactionConfig, error) {
+
+	// Parse the raw config from JSON
+	config := new(bux.TransactionConfig)
+	if len(txConfigJSON) > 0 {
+		if err := json.Unmarshal([]byte(txConfigJSON), config); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTransactionConfig, err.Error())
+		}
+	}
+
+	// Build the recipient outputs
+	outputs, err := newTransactionOutputs(flags.recipients, flags.satoshis, flags.sendAll)
+	if err != nil {
+		return nil, err
+	}
+	if flags.sendAll {
+		config.SendAllTo = outputs[0]
+	} else {
+		config.Outputs = append(config.Outputs, outputs...)
+	}
+
+	// Add the OP_RETURN output
+	if len(flags.opReturns) > 0 {
+		config.Outputs = append(config.Outputs, newOpReturnOutput(flags.opReturns))
+	}
+
+	// Fee per byte (overrides the chainstate fee)
+	if flags.feePerByte < 0 || math.IsNaN(flags.feePerByte) || math.IsInf(flags.feePerByte, 0) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeePerByte, flags.feePerByte)
+	} else if flags.feePerByte > 0 {
+		feeUnit := &utils.FeeUnit{Satoshis: int(math.Round(flags.feePerByte * feeUnitBytes)), Bytes: feeUnitBytes}
+		if feeUnit.Satoshis == 0 {
+			return nil, fmt.Errorf("%w: %v is less than 1 satoshi per %d bytes",
+				ErrInvalidFeePerByte, flags.feePerByte, feeUnitBytes)
+		}
+		config.FeeUnit = feeUnit
+	}
+
+	// Number of change destinations
+	if flags.changeDestinations < 0 {
+		return nil, fmt.Errorf("%w: change destinations cannot be negative", ErrInvalidTransactionConfig)
+	} else if flags.changeDestinations > 0 {
+		config.ChangeNumberOfDestinations = flags.changeDestinations
+	}
+
+	// Something needs to be sent
+	if len(config.Outputs) == 0 && config.SendAllTo == nil {
+		return nil, ErrTransactionConfigIsRequired
+	}
+	return config, nil
+}
+
+// newTransactionOutputs will validate the recipients & satoshis and return the outputs
+func newTransactionOutputs(recipients []string, satoshis []uint, sendAll bool) ([]*bux.TransactionOutput, error) {
+
+	// Send all goes to a single recipient, the value is all the utxos
+	if sendAll {
+		if len(recipients) != 1 {
+			return nil, fmt.Errorf("%w: send all requires exactly one recipient", ErrInvalidTransactionConfig)
+		} else if len(satoshis) > 0 {
+			return nil, fmt.Errorf("%w: send all cannot be combined with satoshis", ErrInvalidSatoshis)
+		}
+	} else if len(satoshis) > 1 && len(satoshis) != len(recipients) {
+		return nil, fmt.Errorf("%w: %d values for %d recipients (use one value for all, or one per recipient)",
+			ErrInvalidSatoshis, len(satoshis), len(recipients))
+	} else if len(satoshis) > 0 && len(recipients) == 0 {
+		return nil, fmt.Errorf("%w: satoshis require a recipient", ErrInvalidSatoshis)
+	}
+
+	outputs := make([]*bux.TransactionOutput, 0, len(recipients))
+	for index, recipient := range recipients {
+
+		// Get the value for the recipient
+		output := new(bux.TransactionOutput)
+		if !sendAll {
+			if len(satoshis) == 0 {
+				return nil, fmt.Errorf("%w: missing the value for %s", ErrInvalidSatoshis, recipient)
+			} else if len(satoshis) == 1 {
+				output.Satoshis = uint64(satoshis[0])
+			} else {
+				output.Satoshis = uint64(satoshis[index])
+			}
+			if output.Satoshis == 0 {
+				return nil, fmt.Errorf("%w: the value for %s must be more than 0", ErrInvalidSatoshis, recipient)
+			}
+		}
+
+		// Detect the type of recipient
+		if err := setRecipient(output, strings.TrimSpace(recipient)); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// setRecipient will set the recipient on the output (address, paymail, $handle or locking script)
+func setRecipient(output *bux.TransactionOutput, recipient string) error {
+	if strings.Contains(recipient, "@") { // Paymail
+		if err := paymail.ValidatePaymail(recipient); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidRecipient, err.Error())
+		}
+		output.To = recipient
+	} else if strings.HasPrefix(recipient, "$") && len(recipient) > 1 { // HandCash handle
+		output.To = recipient
+	} else if valid, _ := bitcoin.ValidA58([]byte(recipient)); valid { // Bitcoin address
+		output.To = recipient
+	} else if script, err := hex.DecodeString(recipient); err == nil && len(script) > 0 { // Locking script
+		output.Script = recipient
+	} else {
+		return fmt.Errorf("%w: %s", ErrInvalidRecipient, recipient)
+	}
+	return nil
+}
+
+// newOpReturnOutput will return the OP_RETURN output for the data parts (hex if it decodes, otherwise utf8)
+func newOpReturnOutput(parts []string) *bux.TransactionOutput {
+	hexParts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if _, err := hex.DecodeString(part); err == nil && len(part) > 0 {
+			hexParts = append(hexParts, strings.ToLower(part))
+		} else {
+			hexParts = append(hexParts, hex.EncodeToString([]byte(part)))
+		}
+	}
+	return &bux.TransactionOutput{OpReturn: &bux.OpReturn{HexParts: hexParts}}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAddress is a valid address used for testing
+const testAddress = "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"
+
+func TestNewTransactionConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nothing to send", func(t *testing.T) {
+		_, err := newTransactionConfig("", &txConfigFlags{})
+		require.ErrorIs(t, err, ErrTransactionConfigIsRequired)
+	})
+
+	t.Run("invalid raw config", func(t *testing.T) {
+		_, err := newTransactionConfig("{not-json", &txConfigFlags{})
+		require.ErrorIs(t, err, ErrInvalidTransactionConfig)
+	})
+
+	t.Run("raw config only", func(t *testing.T) {
+		config, err := newTransactionConfig(`{"send_all_to":{"to":"`+testAddress+`"}}`, &txConfigFlags{})
+		require.NoError(t, err)
+		require.NotNil(t, config.SendAllTo)
+		assert.Equal(t, testAddress, config.SendAllTo.To)
+	})
+
+	t.Run("recipients with one value for all", func(t *testing.T) {
+		config, err := newTransactionConfig("", &txConfigFlags{
+			recipients: []string{testAddress, "alias@domain.com", "$handle", "76a914"},
+			satoshis:   []uint{1000},
+		})
+		require.NoError(t, err)
+		require.Len(t, config.Outputs, 4)
+		assert.Equal(t, &bux.TransactionOutput{To: testAddress, Satoshis: 1000}, config.Outputs[0])
+		assert.Equal(t, &bux.TransactionOutput{To: "alias@domain.com", Satoshis: 1000}, config.Outputs[1])
+		assert.Equal(t, &bux.TransactionOutput{To: "$handle", Satoshis: 1000}, config.Outputs[2])
+		assert.Equal(t, &bux.TransactionOutput{Script: "76a914", Satoshis: 1000}, config.Outputs[3])
+	})
+
+	t.Run("recipients with one value each, merged with the raw config", func(t *testing.T) {
+		config, err := newTransactionConfig(`{"outputs":[{"to":"first@domain.com","satoshis":500}]}`, &txConfigFlags{
+			recipients: []string{testAddress, "alias@domain.com"},
+			satoshis:   []uint{1000, 2000},
+		})
+		require.NoError(t, err)
+		require.Len(t, config.Outputs, 3)
+		assert.Equal(t, "first@domain.com", config.Outputs[0].To)
+		assert.Equal(t, uint64(1000), config.Outputs[1].Satoshis)
+		assert.Equal(t, uint64(2000), config.Outputs[2].Satoshis)
+	})
+
+	t.Run("mismatched values", func(t *testing.T) {
+		_, err := newTransactionConfig("", &txConfigFlags{
+			recipients: []string{testAddress, "alias@domain.com", "$handle"},
+			satoshis:   []uint{1000, 2000},
+		})
+		require.ErrorIs(t, err, ErrInvalidSatoshis)
+	})
+
+	t.Run("missing or zero value", func(t *testing.T) {
+		_, err := newTransactionConfig("", &txConfigFlags{recipients: []string{testAddress}})
+		require.ErrorIs(t, err, ErrInvalidSatoshis)
+
+		_, err = newTransactionConfig("", &txConfigFlags{recipients: []string{testAddress}, satoshis: []uint{0}})
+		require.ErrorIs(t, err, ErrInvalidSatoshis)
+	})
+
+	t.Run("invalid recipient", func(t *testing.T) {
+		_, err := newTransactionConfig("", &txConfigFlags{recipients: []string{"not-valid"}, satoshis: []uint{1}})
+		require.ErrorIs(t, err, ErrInvalidRecipient)
+
+		_, err = newTransactionConfig("", &txConfigFlags{recipients: []string{"not valid@"}, satoshis: []uint{1}})
+		require.ErrorIs(t, err, ErrInvalidRecipient)
+	})
+
+	t.Run("send all", func(t *testing.T) {
+		config, err := newTransactionConfig("", &txConfigFlags{recipients: []string{testAddress}, sendAll: true})
+		require.NoError(t, err)
+		assert.Equal(t, &bux.TransactionOutput{To: testAddress}, config.SendAllTo)
+		assert.Empty(t, config.Outputs)
+
+		_, err = newTransactionConfig("", &txConfigFlags{
+			recipients: []string{testAddress}, sendAll: true, satoshis: []uint{1},
+		})
+		require.ErrorIs(t, err, ErrInvalidSatoshis)
+
+		_, err = newTransactionConfig("", &txConfigFlags{sendAll: true})
+		require.ErrorIs(t, err, ErrInvalidTransactionConfig)
+	})
+
+	t.Run("op return (hex and utf8)", func(t *testing.T) {
+		config, err := newTransactionConfig("", &txConfigFlags{opReturns: []string{"CAFE", "hello world"}})
+		require.NoError(t, err)
+		require.Len(t, config.Outputs, 1)
+		assert.Equal(t, []string{"cafe", "68656c6c6f20776f726c64"}, config.Outputs[0].OpReturn.HexParts)
+	})
+
+	t.Run("fee per byte", func(t *testing.T) {
+		config, err := newTransactionConfig("", &txConfigFlags{
+			recipients: []string{testAddress}, satoshis: []uint{1000}, feePerByte: 0.05,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &utils.FeeUnit{Satoshis: 50, Bytes: feeUnitBytes}, config.FeeUnit)
+
+		_, err = newTransactionConfig("", &txConfigFlags{
+			recipients: []string{testAddress}, satoshis: []uint{1000}, feePerByte: -1,
+		})
+		require.ErrorIs(t, err, ErrInvalidFeePerByte)
+
+		_, err = newTransactionConfig("", &txConfigFlags{
+			recipients: []string{testAddress}, satoshis: []uint{1000}, feePerByte: 0.0001,
+		})
+		require.ErrorIs(t, err, ErrInvalidFeePerByte)
+	})
+
+	t.Run("change destinations", func(t *testing.T) {
+		config, err := newTransactionConfig("", &txConfigFlags{
+			recipients: []string{testAddress}, satoshis: []uint{1000}, changeDestinations: 3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, config.ChangeNumberOfDestinations)
+
+		_, err = newTransactionConfig("", &txConfigFlags{
+			recipients: []string{testAddress}, satoshis: []uint{1000}, changeDestinations: -1,
+		})
+		require.ErrorIs(t, err, ErrInvalidTransactionConfig)
+	})
+}
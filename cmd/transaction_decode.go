@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/libsv/go-bt/v2"
+	"github.com/libsv/go-bt/v2/bscript"
+	"github.com/mrz1836/go-whatsonchain"
+)
+
+// Bitcom protocols that are decoded in OP_RETURN outputs
+const (
+	bitcomB       = "19HxigV4QyBv3tHpQVcUEQyq1pzZVdoAut" // B:// (content, media type, encoding, filename)
+	bitcomMAP     = "1PuQa7K62MiKCtssSLKy1kh56WWU7MtUR5" // Magic Attribute Protocol
+	bitcomMAPSet  = "SET"
+	protocolB     = "B"
+	protocolData  = "data"
+	protocolMAP   = "MAP"
+	protocolSplit = "|"
+)
+
+// txIDLength is the length of a transaction id (hex), a transaction hex is always longer
+const txIDLength = 64
+
+// destinationFinder will find a BUX destination by locking script (see: Backend.FindDestination)
+type destinationFinder func(ctx context.Context, lockingScript string) (*bux.Destination, error)
+
+// decodeRawTransaction will decode the transaction (hex or txid)
+//
+// If resolve is set, the transaction (txid) and the inputs are fetched from WhatsOnChain,
+// and the inputs and outputs are matched with the BUX destinations
+func decodeRawTransaction(ctx context.Context, app *App, rawTx string, resolve bool) (*DecodedTransaction, error) {
+	if len(rawTx) == txIDLength && resolve {
+		txHex, err := app.WhatsOnChain().GetRawTransactionData(ctx, rawTx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting transaction %s from WhatsOnChain: %w", rawTx, err)
+		}
+		rawTx = txHex
+	}
+	tx, err := parseTransactionHex(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	if !resolve {
+		return decodeTransaction(tx), nil
+	}
+
+	resolveInputs(ctx, app.WhatsOnChain(), tx)
+	decoded := decodeTransaction(tx)
+	if decoded.Fee == nil {
+		chalker.LogTo(os.Stderr, chalker.WARN, "the fee is unknown, not all inputs were resolved")
+	}
+	findKnownDestinations(ctx, app.backend.FindDestination, tx, decoded)
+	return decoded, nil
+}
+
+// decodeTransaction will decode the inputs and outputs of the transaction
+//
+// The inputs are only decoded (address, satoshis) if the previous outputs are resolved (see: resolveInputs),
+// the fee is only known if all inputs are resolved
+func decodeTransaction(tx *bt.Tx) *DecodedTransaction {
+	decoded := &DecodedTransaction{
+		Inputs:   make([]*DecodedInput, 0, len(tx.Inputs)),
+		LockTime: tx.LockTime,
+		Outputs:  make([]*DecodedOutput, 0, len(tx.Outputs)),
+		Size:     tx.Size(),
+		TxID:     tx.TxID(),
+		Version:  tx.Version,
+	}
+
+	var totalInput uint64
+	resolved := 0
+	for index, input := range tx.Inputs {
+		decodedInput := &DecodedInput{
+			Index:         index,
+			PreviousIndex: input.PreviousTxOutIndex,
+			PreviousTxID:  input.PreviousTxIDStr(),
+			Sequence:      input.SequenceNumber,
+		}
+		if input.PreviousTxScript != nil {
+			satoshis := input.PreviousTxSatoshis
+			lockingScript := input.PreviousTxScript.String()
+			decodedInput.Address = utils.GetAddressFromScript(lockingScript)
+			decodedInput.Satoshis = &satoshis
+			decodedInput.ScriptType = utils.GetDestinationType(lockingScript)
+			totalInput += satoshis
+			resolved++
+		}
+		decoded.Inputs = append(decoded.Inputs, decodedInput)
+	}
+
+	for index, output := range tx.Outputs {
+		lockingScript := output.LockingScriptHexString()
+		decodedOutput := &DecodedOutput{
+			Address:    utils.GetAddressFromScript(lockingScript),
+			Index:      index,
+			Satoshis:   output.Satoshis,
+			ScriptType: utils.GetDestinationType(lockingScript),
+		}
+		if output.LockingScript != nil && (output.LockingScript.IsData() ||
+			decodedOutput.ScriptType == utils.ScriptTypeNullData) {
+			decodedOutput.OpReturn = decodeOpReturn(*output.LockingScript)
+		}
+		decoded.TotalOutput += output.Satoshis
+		decoded.Outputs = append(decoded.Outputs, decodedOutput)
+	}
+
+	// The fee is the difference between the inputs and the outputs
+	if resolved == len(tx.Inputs) && totalInput >= decoded.TotalOutput {
+		fee := totalInput - decoded.TotalOutput
+		decoded.Fee = &fee
+		decoded.TotalInput = &totalInput
+		if decoded.Size > 0 {
+			decoded.FeeRate = float64(fee) / float64(decoded.Size)
+		}
+	}
+	return decoded
+}
+
+// decodeOpReturn will decode the data of an OP_RETURN (or OP_FALSE OP_RETURN) output
+//
+// The protocols are separated by a pipe, MAP and B are decoded, anything else is returned as data (hex and UTF-8)
+func decodeOpReturn(lockingScript bscript.Script) []*OpReturnProtocol {
+	script := []byte(lockingScript)
+	if len(script) > 0 && script[0] == bscript.OpFALSE {
+		script = script[1:]
+	}
+	if len(script) == 0 || script[0] != bscript.OpRETURN {
+		return nil
+	}
+	parts, err := bscript.DecodeParts(script[1:])
+	if err != nil || len(parts) == 0 {
+		return nil
+	}
+
+	// Split the parts into protocols
+	protocols := make([]*OpReturnProtocol, 0, 1)
+	segment := make([][]byte, 0, len(parts))
+	for _, part := range parts {
+		if string(part) == protocolSplit {
+			protocols = append(protocols, decodeOpReturnProtocol(segment))
+			segment = make([][]byte, 0, len(parts))
+			continue
+		}
+		segment = append(segment, part)
+	}
+	return append(protocols, decodeOpReturnProtocol(segment))
+}
+
+// decodeOpReturnProtocol will decode the parts of a protocol, the first part is the prefix (bitcom address)
+func decodeOpReturnProtocol(parts [][]byte) *OpReturnProtocol {
+	if len(parts) == 0 {
+		return &OpReturnProtocol{Protocol: protocolData}
+	}
+
+	switch string(parts[0]) {
+	case bitcomMAP:
+		protocol := &OpReturnProtocol{Protocol: protocolMAP}
+		if len(parts) < 2 {
+			return protocol
+		}
+		protocol.Command = string(parts[1])
+		if protocol.Command != bitcomMAPSet {
+			protocol.Parts = newOpReturnParts(parts[2:])
+			return protocol
+		}
+
+		// SET has key value pairs
+		protocol.Data = make(map[string]string, (len(parts)-2)/2)
+		for i := 2; i+1 < len(parts); i += 2 {
+			protocol.Data[string(parts[i])] = string(parts[i+1])
+		}
+		return protocol
+	case bitcomB:
+		protocol := &OpReturnProtocol{Data: make(map[string]string, 3), Protocol: protocolB}
+		if len(parts) > 1 {
+			protocol.Parts = newOpReturnParts(parts[1:2])
+		}
+		for index, key := range []string{"media_type", "encoding", "filename"} {
+			if index+2 < len(parts) {
+				protocol.Data[key] = string(parts[index+2])
+			}
+		}
+		return protocol
+	}
+	return &OpReturnProtocol{Parts: newOpReturnParts(parts), Protocol: protocolData}
+}
+
+// newOpReturnParts will return the parts as hex, and as UTF-8 if the part is readable text
+func newOpReturnParts(parts [][]byte) []*OpReturnPart {
+	opReturnParts := make([]*OpReturnPart, 0, len(parts))
+	for _, part := range parts {
+		opReturnPart := &OpReturnPart{Hex: hex.EncodeToString(part)}
+		if isReadableText(part) {
+			opReturnPart.UTF8 = string(part)
+		}
+		opReturnParts = append(opReturnParts, opReturnPart)
+	}
+	return opReturnParts
+}
+
+// isReadableText will return true if the data is valid UTF-8 with only printable characters (and whitespace)
+func isReadableText(data []byte) bool {
+	if len(data) == 0 || !utf8.Valid(data) {
+		return false
+	}
+	return bytes.IndexFunc(data, func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	}) == -1
+}
+
+// resolveInputs will get the previous outputs of the inputs from WhatsOnChain (the script and satoshis)
+//
+// Inputs that cannot be resolved are skipped with a warning, the fee will be unknown
+func resolveInputs(ctx context.Context, client whatsonchain.ClientInterface, tx *bt.Tx) {
+	previousTxs := make(map[string]*bt.Tx)
+	for index, input := range tx.Inputs {
+		previousTxID := input.PreviousTxIDStr()
+		previousTx, ok := previousTxs[previousTxID]
+		if !ok {
+			txHex, err := client.GetRawTransactionData(ctx, previousTxID)
+			if err == nil {
+				previousTx, err = bt.NewTxFromString(txHex)
+			}
+			if err != nil {
+				chalker.LogTo(os.Stderr, chalker.WARN, fmt.Sprintf(
+					"error getting the previous transaction %s of input %d from WhatsOnChain: %s",
+					previousTxID, index, err.Error()))
+			}
+			previousTxs[previousTxID] = previousTx
+		}
+		if previousTx == nil {
+			continue
+		} else if int(input.PreviousTxOutIndex) >= len(previousTx.Outputs) {
+			chalker.LogTo(os.Stderr, chalker.WARN, fmt.Sprintf(
+				"the previous transaction %s of input %d has no output %d",
+				previousTxID, index, input.PreviousTxOutIndex))
+			continue
+		}
+		output := previousTx.Outputs[input.PreviousTxOutIndex]
+		input.PreviousTxSatoshis = output.Satoshis
+		input.PreviousTxScript = output.LockingScript
+	}
+}
+
+// findKnownDestinations will mark the inputs (if resolved) and outputs that are BUX destinations
+//
+// The lookups stop at the first error (IE: the admin key is not set in server mode)
+func findKnownDestinations(ctx context.Context, find destinationFinder, tx *bt.Tx, decoded *DecodedTransaction) {
+	known := make(map[string]*KnownDestination)
+	var lookupErr error
+	lookup := func(lockingScript *bscript.Script) *KnownDestination {
+		if lookupErr != nil || lockingScript == nil || len(*lockingScript) == 0 || lockingScript.IsData() {
+			return nil
+		}
+		scriptHex := lockingScript.String()
+		if destination, ok := known[scriptHex]; ok {
+			return destination
+		}
+		destination, err := find(ctx, scriptHex)
+		if err != nil {
+			lookupErr = err
+			chalker.LogTo(os.Stderr, chalker.WARN, "error finding the BUX destinations: "+err.Error())
+			return nil
+		} else if destination != nil {
+			known[scriptHex] = &KnownDestination{
+				Chain:  destination.Chain,
+				ID:     destination.ID,
+				Num:    destination.Num,
+				XpubID: destination.XpubID,
+			}
+		} else {
+			known[scriptHex] = nil
+		}
+		return known[scriptHex]
+	}
+
+	for index, output := range tx.Outputs {
+		decoded.Outputs[index].Destination = lookup(output.LockingScript)
+	}
+	for index, input := range tx.Inputs {
+		decoded.Inputs[index].Destination = lookup(input.PreviousTxScript)
+	}
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/bitcoinschema/go-bitcoin/v2"
+	"github.com/libsv/go-bt/v2"
+	"github.com/libsv/go-bt/v2/bscript"
+	"github.com/mrz1836/go-whatsonchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDecodeAddress is the address of the previous output and the first output of the test transaction
+const testDecodeAddress = "1CfaQw9udYNPccssFJFZ94DN8MqNZm9nGt"
+
+// rawTxStub is a WhatsOnChain client stub that returns the raw transactions by txid
+type rawTxStub struct {
+	whatsonchain.ClientInterface
+	txs map[string]string
+}
+
+// GetRawTransactionData returns the hex of the transaction (or an error if it is unknown)
+func (r *rawTxStub) GetRawTransactionData(_ context.Context, hash string) (string, error) {
+	if txHex, ok := r.txs[hash]; ok {
+		return txHex, nil
+	}
+	return "", errors.New("transaction not found")
+}
+
+// newOpReturnScript returns an OP_FALSE OP_RETURN script with the parts
+func newOpReturnScript(t *testing.T, parts ...string) bscript.Script {
+	script := &bscript.Script{}
+	require.NoError(t, script.AppendOpcodes(bscript.OpFALSE, bscript.OpRETURN))
+	data := make([][]byte, 0, len(parts))
+	for _, part := range parts {
+		data = append(data, []byte(part))
+	}
+	require.NoError(t, script.AppendPushDataArray(data))
+	return *script
+}
+
+// newTestDecodeTxs returns a previous transaction and a transaction (hex, no previous outputs) that spends it
+func newTestDecodeTxs(t *testing.T) (previousTx *bt.Tx, tx *bt.Tx) {
+	previousTx = bt.NewTx()
+	require.NoError(t, previousTx.From(testUtxoTxID, 0, "76a914cf1ba2bef07b31ab5ed2ba5ef9e8f5a5a0d8b2e088ac", 5000))
+	require.NoError(t, previousTx.PayToAddress(testDecodeAddress, 2000))
+
+	spending := bt.NewTx()
+	require.NoError(t, spending.From(previousTx.TxID(), 0, previousTx.Outputs[0].LockingScriptHexString(), 2000))
+	require.NoError(t, spending.PayToAddress(testDecodeAddress, 1500))
+	opReturn := newOpReturnScript(t, "hello world")
+	spending.AddOutput(&bt.Output{LockingScript: &opReturn})
+
+	// Parse the hex, the previous outputs are not part of the hex
+	var err error
+	tx, err = parseTransactionHex(spending.String())
+	require.NoError(t, err)
+	return
+}
+
+func TestDecodeTransaction(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unresolved inputs", func(t *testing.T) {
+		previousTx, tx := newTestDecodeTxs(t)
+		decoded := decodeTransaction(tx)
+
+		assert.Equal(t, tx.TxID(), decoded.TxID)
+		assert.Equal(t, tx.Size(), decoded.Size)
+		assert.Equal(t, uint64(1500), decoded.TotalOutput)
+		assert.Nil(t, decoded.Fee)
+		assert.Nil(t, decoded.TotalInput)
+
+		require.Len(t, decoded.Inputs, 1)
+		assert.Equal(t, previousTx.TxID(), decoded.Inputs[0].PreviousTxID)
+		assert.Equal(t, uint32(0), decoded.Inputs[0].PreviousIndex)
+		assert.Nil(t, decoded.Inputs[0].Satoshis)
+		assert.Empty(t, decoded.Inputs[0].Address)
+
+		require.Len(t, decoded.Outputs, 2)
+		assert.Equal(t, testDecodeAddress, decoded.Outputs[0].Address)
+		assert.Equal(t, utils.ScriptTypePubKeyHash, decoded.Outputs[0].ScriptType)
+		assert.Nil(t, decoded.Outputs[0].OpReturn)
+		assert.Equal(t, utils.ScriptTypeNullData, decoded.Outputs[1].ScriptType)
+		require.Len(t, decoded.Outputs[1].OpReturn, 1)
+		assert.Equal(t, "hello world", decoded.Outputs[1].OpReturn[0].Parts[0].UTF8)
+	})
+
+	t.Run("resolved inputs", func(t *testing.T) {
+		previousTx, tx := newTestDecodeTxs(t)
+		resolveInputs(context.Background(), &rawTxStub{txs: map[string]string{
+			previousTx.TxID(): previousTx.String(),
+		}}, tx)
+		decoded := decodeTransaction(tx)
+
+		require.NotNil(t, decoded.Fee)
+		assert.Equal(t, uint64(500), *decoded.Fee)
+		assert.Equal(t, uint64(2000), *decoded.TotalInput)
+		assert.InDelta(t, 500/float64(tx.Size()), decoded.FeeRate, 0.000001)
+		require.NotNil(t, decoded.Inputs[0].Satoshis)
+		assert.Equal(t, uint64(2000), *decoded.Inputs[0].Satoshis)
+		assert.Equal(t, testDecodeAddress, decoded.Inputs[0].Address)
+	})
+
+	t.Run("previous transaction not found", func(t *testing.T) {
+		_, tx := newTestDecodeTxs(t)
+		resolveInputs(context.Background(), &rawTxStub{}, tx)
+		decoded := decodeTransaction(tx)
+		assert.Nil(t, decoded.Fee)
+		assert.Nil(t, decoded.Inputs[0].Satoshis)
+	})
+}
+
+func TestDecodeOpReturn(t *testing.T) {
+	t.Parallel()
+
+	t.Run("data", func(t *testing.T) {
+		protocols := decodeOpReturn(newOpReturnScript(t, "hello", "\x00\x01\xff"))
+		require.Len(t, protocols, 1)
+		assert.Equal(t, protocolData, protocols[0].Protocol)
+		require.Len(t, protocols[0].Parts, 2)
+		assert.Equal(t, &OpReturnPart{Hex: "68656c6c6f", UTF8: "hello"}, protocols[0].Parts[0])
+		assert.Equal(t, &OpReturnPart{Hex: "0001ff"}, protocols[0].Parts[1])
+	})
+
+	t.Run("B and MAP", func(t *testing.T) {
+		protocols := decodeOpReturn(newOpReturnScript(t,
+			bitcomB, "# hello", "text/markdown", "UTF-8", "hello.md", protocolSplit,
+			bitcomMAP, bitcomMAPSet, "app", "bux", "type", "post",
+		))
+		require.Len(t, protocols, 2)
+
+		assert.Equal(t, protocolB, protocols[0].Protocol)
+		assert.Equal(t, map[string]string{
+			"encoding": "UTF-8", "filename": "hello.md", "media_type": "text/markdown",
+		}, protocols[0].Data)
+		require.Len(t, protocols[0].Parts, 1)
+		assert.Equal(t, "# hello", protocols[0].Parts[0].UTF8)
+
+		assert.Equal(t, protocolMAP, protocols[1].Protocol)
+		assert.Equal(t, bitcomMAPSet, protocols[1].Command)
+		assert.Equal(t, map[string]string{"app": "bux", "type": "post"}, protocols[1].Data)
+	})
+
+	t.Run("OP_RETURN without OP_FALSE", func(t *testing.T) {
+		script := &bscript.Script{}
+		require.NoError(t, script.AppendOpcodes(bscript.OpRETURN))
+		require.NoError(t, script.AppendPushData([]byte("data")))
+		protocols := decodeOpReturn(*script)
+		require.Len(t, protocols, 1)
+		assert.Equal(t, "data", protocols[0].Parts[0].UTF8)
+	})
+
+	t.Run("not an OP_RETURN", func(t *testing.T) {
+		script, err := bitcoin.ScriptFromAddress(testDecodeAddress)
+		require.NoError(t, err)
+		var lockingScript *bscript.Script
+		lockingScript, err = bscript.NewFromHexString(script)
+		require.NoError(t, err)
+		assert.Nil(t, decodeOpReturn(*lockingScript))
+	})
+}
+
+func TestFindKnownDestinations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("known output and input", func(t *testing.T) {
+		previousTx, tx := newTestDecodeTxs(t)
+		resolveInputs(context.Background(), &rawTxStub{txs: map[string]string{
+			previousTx.TxID(): previousTx.String(),
+		}}, tx)
+		decoded := decodeTransaction(tx)
+
+		lookups := 0
+		findKnownDestinations(context.Background(), func(_ context.Context, lockingScript string) (*bux.Destination, error) {
+			lookups++
+			if lockingScript != tx.Outputs[0].LockingScriptHexString() {
+				return nil, nil
+			}
+			destination := &bux.Destination{Chain: utils.ChainExternal, Num: 2, XpubID: "xpub-id"}
+			destination.ID = "destination-id"
+			return destination, nil
+		}, tx, decoded)
+
+		// The same locking script is only looked up once, the OP_RETURN is skipped
+		assert.Equal(t, 1, lookups)
+		assert.Equal(t, &KnownDestination{
+			Chain: utils.ChainExternal, ID: "destination-id", Num: 2, XpubID: "xpub-id",
+		}, decoded.Outputs[0].Destination)
+		assert.Nil(t, decoded.Outputs[1].Destination)
+		assert.Equal(t, decoded.Outputs[0].Destination, decoded.Inputs[0].Destination)
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		_, tx := newTestDecodeTxs(t)
+		decoded := decodeTransaction(tx)
+		lookups := 0
+		findKnownDestinations(context.Background(), func(context.Context, string) (*bux.Destination, error) {
+			lookups++
+			return nil, errors.New("admin key is required")
+		}, tx, decoded)
+		assert.Equal(t, 1, lookups)
+		assert.Nil(t, decoded.Outputs[0].Destination)
+	})
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/libsv/go-bt/v2"
+	"github.com/tonicpow/go-minercraft"
+)
+
+// unsignedInputSize is the size of an input without the unlocking script (txid, vout, script length and sequence)
+const unsignedInputSize = 41
+
+// estimateTransaction will estimate the inputs, fee, change and size of a new transaction
+//
+// The draft is created to select the utxos and is canceled right away (before the miner fee quotes),
+// so no utxos stay reserved. Canceling is only available with a local BUX engine, in server mode
+// the draft would stay reserved until it expires, so the estimate is not supported
+func estimateTransaction(ctx context.Context, app *App, xpubKey string,
+	config *bux.TransactionConfig) (*TransactionEstimate, error) {
+
+	if app.bux == nil {
+		return nil, ErrNotSupportedInServerMode
+	}
+
+	// Create the draft (selects and reserves the utxos)
+	draft, err := newTransaction(ctx, app, xpubKey, config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Estimate from the draft, then cancel it right away (releases the utxos), even if the estimate fails
+	estimate, err := newTransactionEstimate(draft)
+	if _, cancelErr := app.backend.CancelDraftTransaction(ctx, draft.ID); cancelErr != nil {
+		return nil, fmt.Errorf("error canceling draft %s, the utxos are reserved until %s: %w",
+			draft.ID, draft.ExpiresAt.Format(time.RFC3339), cancelErr)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Compare the fee at the quoted rate of each configured miner
+	chainstateConfig := app.config.Chainstate
+	if chainstateConfig == nil {
+		chainstateConfig = &ChainstateConfig{}
+	}
+	var miners []*minercraft.Miner
+	if _, miners, err = configuredMiners(chainstateConfig); err != nil {
+		return nil, err
+	}
+	var client minercraft.ClientInterface
+	if client, err = newMinerClient(app.GetUserAgent(), miners); err != nil {
+		return nil, err
+	}
+	estimate.MinerFees = estimateMinerFees(ctx, client, miners, estimate.Size)
+	return estimate, nil
+}
+
+// newTransactionEstimate will return the estimate of the draft (inputs, outputs, change and the signed size)
+func newTransactionEstimate(draft *bux.DraftTransaction) (*TransactionEstimate, error) {
+	tx, err := bt.NewTxFromString(draft.Hex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDraft, err.Error())
+	}
+
+	config := draft.Configuration
+	estimate := &TransactionEstimate{
+		ChangeOutputs:  make([]*EstimateOutput, 0),
+		ChangeSatoshis: config.ChangeSatoshis,
+		Fee:            config.Fee,
+		FeeUnit:        config.FeeUnit,
+		Inputs:         make([]*EstimateInput, 0, len(config.Inputs)),
+		MinerFees:      make([]*MinerFeeEstimate, 0),
+		Outputs:        make([]*EstimateOutput, 0, len(config.Outputs)),
+		Size:           uint64(tx.Size()),
+	}
+
+	// The draft is not signed, add the size of the unlocking scripts
+	for _, input := range config.Inputs {
+		estimate.Inputs = append(estimate.Inputs, &EstimateInput{
+			Address:       input.Destination.Address,
+			OutputIndex:   input.OutputIndex,
+			Satoshis:      input.Satoshis,
+			TransactionID: input.TransactionID,
+		})
+		estimate.Size += utils.GetInputSizeForType(input.Type) - unsignedInputSize
+		estimate.TotalInput += input.Satoshis
+	}
+
+	// The change outputs are the change destinations, or the outputs that are used for change
+	changeScripts := make(map[string]bool, len(config.ChangeDestinations))
+	for _, destination := range config.ChangeDestinations {
+		changeScripts[destination.LockingScript] = true
+	}
+	for _, output := range config.Outputs {
+		estimateOutput := &EstimateOutput{Satoshis: output.Satoshis, To: output.To}
+		if len(output.Scripts) > 0 {
+			estimateOutput.ScriptType = output.Scripts[0].ScriptType
+		}
+		if output.UseForChange || (len(output.Scripts) == 1 && changeScripts[output.Scripts[0].Script]) {
+			estimate.ChangeOutputs = append(estimate.ChangeOutputs, estimateOutput)
+		} else {
+			estimate.Outputs = append(estimate.Outputs, estimateOutput)
+		}
+		estimate.TotalOutput += output.Satoshis
+	}
+	return estimate, nil
+}
+
+// estimateMinerFees will get the fee quotes of the miners concurrently, and calculate the fee for the size
+//
+// The results are in the same order as the miners, a miner that cannot be reached has an error
+func estimateMinerFees(ctx context.Context, client minercraft.ClientInterface, miners []*minercraft.Miner,
+	size uint64) []*MinerFeeEstimate {
+	fees := make([]*MinerFeeEstimate, len(miners))
+	var wg sync.WaitGroup
+	for index := range miners {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			fees[index] = &MinerFeeEstimate{Name: miners[index].Name}
+			feeQuote, err := client.FeeQuote(ctx, miners[index])
+			if err != nil {
+				fees[index].Error = err.Error()
+				return
+			} else if feeQuote == nil || feeQuote.Quote == nil || feeQuote.Quote.GetFee(minercraft.FeeTypeStandard) == nil {
+				fees[index].Error = "missing fee quote"
+				return
+			}
+			fee := feeQuote.Quote.GetFee(minercraft.FeeTypeStandard)
+			fees[index].FeeRate = satoshisPerByte(fee.MiningFee.Satoshis, fee.MiningFee.Bytes)
+			if fees[index].Fee, err = feeQuote.Quote.CalculateFee(
+				minercraft.FeeCategoryMining, minercraft.FeeTypeStandard, size,
+			); err != nil {
+				fees[index].Error = err.Error()
+			}
+		}(index)
+	}
+	wg.Wait()
+	return fees
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransactionEstimate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("inputs, outputs and change", func(t *testing.T) {
+		draft := newTestDraft(t, testMnemonicVectors[0].xpriv)
+		changeScript := draft.Configuration.Inputs[0].Destination.LockingScript
+		draft.Configuration.Inputs[0].Type = utils.ScriptTypePubKeyHash
+		draft.Configuration.ChangeDestinations = []*bux.Destination{{LockingScript: changeScript}}
+		draft.Configuration.ChangeSatoshis = 300
+		draft.Configuration.Fee = 100
+		draft.Configuration.FeeUnit = &utils.FeeUnit{Satoshis: 1, Bytes: 20}
+		draft.Configuration.Outputs = []*bux.TransactionOutput{
+			{
+				Satoshis: 600,
+				Scripts:  []*bux.ScriptOutput{{Script: "76a914", ScriptType: utils.ScriptTypePubKeyHash}},
+				To:       "alias@domain.com",
+			},
+			{
+				Satoshis: 300,
+				Scripts:  []*bux.ScriptOutput{{Script: changeScript, ScriptType: utils.ScriptTypePubKeyHash}},
+				To:       "1change",
+			},
+		}
+
+		estimate, err := newTransactionEstimate(draft)
+		require.NoError(t, err)
+
+		// The unsigned draft is 85 bytes, the signature and the public key add 107 bytes
+		assert.Equal(t, uint64(192), estimate.Size)
+		assert.Equal(t, uint64(100), estimate.Fee)
+		assert.Equal(t, uint64(300), estimate.ChangeSatoshis)
+		assert.Equal(t, uint64(1000), estimate.TotalInput)
+		assert.Equal(t, uint64(900), estimate.TotalOutput)
+		assert.Equal(t, []*EstimateInput{{
+			Address:       draft.Configuration.Inputs[0].Destination.Address,
+			OutputIndex:   1,
+			Satoshis:      1000,
+			TransactionID: testUtxoTxID,
+		}}, estimate.Inputs)
+		assert.Equal(t, []*EstimateOutput{
+			{Satoshis: 600, ScriptType: utils.ScriptTypePubKeyHash, To: "alias@domain.com"},
+		}, estimate.Outputs)
+		assert.Equal(t, []*EstimateOutput{
+			{Satoshis: 300, ScriptType: utils.ScriptTypePubKeyHash, To: "1change"},
+		}, estimate.ChangeOutputs)
+	})
+
+	t.Run("outputs used for change", func(t *testing.T) {
+		draft := newTestDraft(t, testMnemonicVectors[0].xpriv)
+		draft.Configuration.Outputs = []*bux.TransactionOutput{{Satoshis: 900, To: "1address", UseForChange: true}}
+
+		estimate, err := newTransactionEstimate(draft)
+		require.NoError(t, err)
+		assert.Empty(t, estimate.Outputs)
+		require.Len(t, estimate.ChangeOutputs, 1)
+		assert.Equal(t, uint64(900), estimate.ChangeOutputs[0].Satoshis)
+	})
+
+	t.Run("invalid hex", func(t *testing.T) {
+		draft := newTestDraft(t, testMnemonicVectors[0].xpriv)
+		draft.Hex = "0100"
+		_, err := newTransactionEstimate(draft)
+		require.ErrorIs(t, err, ErrInvalidDraft)
+	})
+}
+
+func TestEstimateTransaction(t *testing.T) {
+	t.Parallel()
+
+	t.Run("server mode does not create a draft", func(t *testing.T) {
+		var req *http.Request
+		body := map[string]interface{}{}
+		server := newTestServer(t, http.StatusCreated, newTestDraft(t, testMnemonicVectors[0].xpriv), &req, &body)
+		backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+		require.NoError(t, err)
+
+		_, err = estimateTransaction(context.Background(), &App{backend: backend}, testXpub, &bux.TransactionConfig{})
+		require.ErrorIs(t, err, ErrNotSupportedInServerMode)
+		assert.Nil(t, req)
+	})
+}
+
+func TestEstimateMinerFees(t *testing.T) {
+	t.Parallel()
+
+	server := newTestMinerServer(t, "local-token")
+	_, miners, err := configuredMiners(&ChainstateConfig{
+		Miners: []*MinerConfig{
+			{MinerID: "local-id", Name: "local", Token: "local-token", URL: server.URL},
+			{MinerID: "offline-id", Name: "offline", URL: "http://127.0.0.1:1"},
+		},
+		MinersBroadcast: []string{"local", "offline"},
+		MinersQuery:     []string{"local"},
+	})
+	require.NoError(t, err)
+
+	client, err := newMinerClient("test", miners)
+	require.NoError(t, err)
+
+	// The standard mining fee of the local miner is 0.05 satoshis per byte
+	fees := estimateMinerFees(context.Background(), client, miners, 1000)
+	require.Len(t, fees, 2)
+	assert.Equal(t, &MinerFeeEstimate{Fee: 50, FeeRate: 0.05, Name: "local"}, fees[0])
+	assert.Equal(t, "offline", fees[1].Name)
+	assert.NotEmpty(t, fees[1].Error)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/bitcoinschema/go-bitcoin/v2"
+	"github.com/libsv/go-bk/bip32"
+	"github.com/libsv/go-bt/v2"
+)
+
+// offlineFileMode is the file mode for the exported drafts and the signed transactions
+const offlineFileMode = 0o600
+
+// writeDraftFile will export the draft transaction (JSON) for signing offline (IE: on an air-gapped machine)
+func writeDraftFile(path string, draft *bux.DraftTransaction) error {
+	data, err := json.MarshalIndent(draft, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling draft: %w", err)
+	}
+	if err = os.WriteFile(path, append(data, '\n'), offlineFileMode); err != nil {
+		return fmt.Errorf("error writing draft file: %w", err)
+	}
+	return nil
+}
+
+// readDraftFile will read an exported draft transaction
+func readDraftFile(path string) (*bux.DraftTransaction, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // the path is provided by the user
+	if err != nil {
+		return nil, fmt.Errorf("error reading draft file: %w", err)
+	}
+	draft := new(bux.DraftTransaction)
+	if err = json.Unmarshal(data, draft); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDraft, err.Error())
+	}
+	return draft, nil
+}
+
+// signDraft will verify that the inputs of the draft belong to the xpriv, and sign the inputs
+//
+// Signing does not need BUX, the draft has the inputs (utxos and destinations) and the unsigned hex
+func signDraft(draft *bux.DraftTransaction, xprivKey string) (*SignedTransaction, error) {
+	hdKey, err := bitcoin.GenerateHDKeyFromString(xprivKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidXpriv, err.Error())
+	} else if !hdKey.IsPrivate() {
+		return nil, fmt.Errorf("%w: the key is not private", ErrInvalidXpriv)
+	}
+
+	// The draft must still be open, an expired draft cannot be recorded (the clock might be off when offline)
+	if draft.Status != bux.DraftStatusDraft {
+		return nil, fmt.Errorf("%w: the draft status is %s", ErrInvalidDraft, draft.Status)
+	} else if !draft.ExpiresAt.IsZero() && draft.ExpiresAt.Before(time.Now()) {
+		chalker.LogTo(os.Stderr, chalker.WARN, fmt.Sprintf("the draft %s expired at %s, recording might fail",
+			draft.ID, draft.ExpiresAt.Format(time.RFC3339)))
+	}
+
+	// Check the inputs before signing
+	if err = verifyDraftInputs(draft, hdKey); err != nil {
+		return nil, err
+	}
+
+	// Sign the inputs
+	signed := &SignedTransaction{DraftID: draft.ID, XpubID: draft.XpubID}
+	if signed.Hex, err = draft.SignInputs(hdKey); err != nil {
+		return nil, fmt.Errorf("error signing draft: %w", err)
+	}
+	var tx *bt.Tx
+	if tx, err = bt.NewTxFromString(signed.Hex); err != nil {
+		return nil, fmt.Errorf("error parsing signed transaction: %w", err)
+	}
+	signed.TxID = tx.TxID()
+	return signed, nil
+}
+
+// verifyDraftInputs will check that the draft and all its inputs belong to the xpriv
+//
+// The inputs must match the unsigned transaction, and the key derived at the destination (chain/num)
+// must produce the locking script of the utxo that is spent
+func verifyDraftInputs(draft *bux.DraftTransaction, hdKey *bip32.ExtendedKey) error {
+	xpub, err := bitcoin.GetExtendedPublicKey(hdKey)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidXpriv, err.Error())
+	}
+	xpubID := utils.Hash(xpub)
+	if draft.XpubID != xpubID {
+		return fmt.Errorf("%w: the draft is for xpub id %s, the key is for %s", ErrDraftKeyMismatch, draft.XpubID, xpubID)
+	}
+
+	// The inputs of the unsigned transaction (in the same order as the configuration inputs)
+	var tx *bt.Tx
+	if len(draft.Hex) == 0 {
+		return fmt.Errorf("%w: the draft has no hex", ErrInvalidDraft)
+	} else if tx, err = bt.NewTxFromString(draft.Hex); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDraft, err.Error())
+	} else if len(tx.Inputs) != len(draft.Configuration.Inputs) || len(tx.Inputs) == 0 {
+		return fmt.Errorf("%w: the transaction has %d inputs, the configuration has %d",
+			ErrInvalidDraft, len(tx.Inputs), len(draft.Configuration.Inputs))
+	}
+
+	for index, input := range draft.Configuration.Inputs {
+		if txInput := tx.Inputs[index]; txInput.PreviousTxIDStr() != input.TransactionID ||
+			txInput.PreviousTxOutIndex != input.OutputIndex {
+			return fmt.Errorf("%w: input %d spends %s:%d, the configuration has %s:%d", ErrInvalidDraft, index,
+				txInput.PreviousTxIDStr(), txInput.PreviousTxOutIndex, input.TransactionID, input.OutputIndex)
+		} else if len(input.Destination.XpubID) > 0 && input.Destination.XpubID != xpubID {
+			return fmt.Errorf("%w: input %d is for xpub id %s", ErrDraftKeyMismatch, index, input.Destination.XpubID)
+		}
+
+		// Derive the key of the destination and compare the locking scripts
+		key, err := deriveKey(hdKey, []uint32{input.Destination.Chain, input.Destination.Num})
+		if err != nil {
+			return err
+		}
+		var lockingScript string
+		if lockingScript, err = bitcoin.ScriptFromAddress(key.Address); err != nil {
+			return fmt.Errorf("error getting locking script for %s: %w", key.Path, err)
+		} else if lockingScript != input.Destination.LockingScript ||
+			(len(input.ScriptPubKey) > 0 && lockingScript != input.ScriptPubKey) {
+			return fmt.Errorf("%w: input %d (%s) is not locked to the key derived at %s (%s)",
+				ErrDraftKeyMismatch, index, input.Destination.Address, key.Path, key.Address)
+		}
+	}
+	return nil
+}
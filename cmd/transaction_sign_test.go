@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/bitcoinschema/go-bitcoin/v2"
+	"github.com/libsv/go-bk/bip32"
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testUtxoTxID is the transaction id of the utxo spent by the test draft
+const testUtxoTxID = "2a9c45e1c51e4f3e4f5c0e2a5ba9f4d7c5fd1a9b46a5d5c10f4e3f1b7f1e6c11"
+
+// newTestDraft will return an unsigned draft that spends a utxo of the xpriv (destination m/1/3)
+func newTestDraft(t *testing.T, xprivKey string) *bux.DraftTransaction {
+	hdKey, err := bip32.NewKeyFromString(xprivKey)
+	require.NoError(t, err)
+	var xpub string
+	xpub, err = bitcoin.GetExtendedPublicKey(hdKey)
+	require.NoError(t, err)
+	var key *DerivedKey
+	key, err = deriveKey(hdKey, []uint32{utils.ChainInternal, 3})
+	require.NoError(t, err)
+	var lockingScript string
+	lockingScript, err = bitcoin.ScriptFromAddress(key.Address)
+	require.NoError(t, err)
+
+	tx := bt.NewTx()
+	require.NoError(t, tx.From(testUtxoTxID, 1, lockingScript, 1000))
+	require.NoError(t, tx.PayToAddress(key.Address, 900))
+
+	xpubID := utils.Hash(xpub)
+	draft := &bux.DraftTransaction{
+		Configuration: bux.TransactionConfig{Inputs: []*bux.TransactionInput{{
+			Utxo: bux.Utxo{
+				UtxoPointer:  bux.UtxoPointer{OutputIndex: 1, TransactionID: testUtxoTxID},
+				Satoshis:     1000,
+				ScriptPubKey: lockingScript,
+				XpubID:       xpubID,
+			},
+			Destination: bux.Destination{
+				Address: key.Address, Chain: utils.ChainInternal, LockingScript: lockingScript, Num: 3, XpubID: xpubID,
+			},
+		}}},
+		Status: bux.DraftStatusDraft,
+		XpubID: xpubID,
+	}
+	draft.Hex = tx.String()
+	draft.ID = "draft-id"
+	return draft
+}
+
+func TestSignDraft(t *testing.T) {
+	t.Parallel()
+
+	xprivKey := testMnemonicVectors[0].xpriv
+
+	t.Run("exported draft", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "draft.json")
+		require.NoError(t, writeDraftFile(path, newTestDraft(t, xprivKey)))
+		draft, err := readDraftFile(path)
+		require.NoError(t, err)
+
+		var signed *SignedTransaction
+		signed, err = signDraft(draft, xprivKey)
+		require.NoError(t, err)
+		assert.Equal(t, "draft-id", signed.DraftID)
+		assert.Equal(t, draft.XpubID, signed.XpubID)
+
+		var tx *bt.Tx
+		tx, err = bt.NewTxFromString(signed.Hex)
+		require.NoError(t, err)
+		assert.Equal(t, tx.TxID(), signed.TxID)
+		require.Len(t, tx.Inputs, 1)
+		assert.NotEmpty(t, tx.Inputs[0].UnlockingScript)
+	})
+
+	t.Run("another key", func(t *testing.T) {
+		_, err := signDraft(newTestDraft(t, xprivKey), testMnemonicVectors[1].xpriv)
+		require.ErrorIs(t, err, ErrDraftKeyMismatch)
+	})
+
+	t.Run("input of another destination", func(t *testing.T) {
+		draft := newTestDraft(t, xprivKey)
+		draft.Configuration.Inputs[0].Destination.Num = 4
+		_, err := signDraft(draft, xprivKey)
+		require.ErrorIs(t, err, ErrDraftKeyMismatch)
+	})
+
+	t.Run("input not in the transaction", func(t *testing.T) {
+		draft := newTestDraft(t, xprivKey)
+		draft.Configuration.Inputs[0].OutputIndex = 0
+		_, err := signDraft(draft, xprivKey)
+		require.ErrorIs(t, err, ErrInvalidDraft)
+	})
+
+	t.Run("canceled draft", func(t *testing.T) {
+		draft := newTestDraft(t, xprivKey)
+		draft.Status = bux.DraftStatusCanceled
+		_, err := signDraft(draft, xprivKey)
+		require.ErrorIs(t, err, ErrInvalidDraft)
+	})
+
+	t.Run("xpub", func(t *testing.T) {
+		hdKey, err := bip32.NewKeyFromString(xprivKey)
+		require.NoError(t, err)
+		var xpub *bip32.ExtendedKey
+		xpub, err = hdKey.Neuter()
+		require.NoError(t, err)
+		_, err = signDraft(newTestDraft(t, xprivKey), xpub.String())
+		require.ErrorIs(t, err, ErrInvalidXpriv)
+	})
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionConditions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no filters", func(t *testing.T) {
+		conditions, err := transactionConditions("", "", 0, 0, "", "")
+		require.NoError(t, err)
+		assert.Empty(t, conditions)
+	})
+
+	t.Run("direction", func(t *testing.T) {
+		conditions, err := transactionConditions("", string(bux.TransactionDirectionIn), 0, 0, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, string(bux.TransactionDirectionIn), conditions["direction"])
+	})
+
+	t.Run("confirmed within a block height range", func(t *testing.T) {
+		conditions, err := transactionConditions(transactionStatusConfirmed, "", 700000, 710000, "", "")
+		require.NoError(t, err)
+		and := conditions["$and"].([]map[string]interface{})
+		require.Len(t, and, 2)
+		assert.Equal(t, map[string]interface{}{conditionGreaterThan: 0}, and[0]["block_height"])
+		assert.Equal(t, map[string]interface{}{
+			conditionGreaterEq: uint64(700000), conditionLessEq: uint64(710000),
+		}, and[1]["block_height"])
+	})
+
+	t.Run("date range", func(t *testing.T) {
+		conditions, err := transactionConditions("", "", 0, 0, "2023-01-01", "")
+		require.NoError(t, err)
+		and := conditions["$and"].([]map[string]interface{})
+		require.Len(t, and, 1)
+		assert.Contains(t, and[0], "created_at")
+	})
+
+	t.Run("unconfirmed cannot use a block height range", func(t *testing.T) {
+		_, err := transactionConditions(transactionStatusUnconfirmed, "", 1, 0, "", "")
+		require.ErrorIs(t, err, ErrInvalidRange)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := transactionConditions("pending", "", 0, 0, "", "")
+		require.ErrorIs(t, err, ErrInvalidTransactionStatus)
+	})
+
+	t.Run("invalid direction", func(t *testing.T) {
+		_, err := transactionConditions("", "sideways", 0, 0, "", "")
+		require.ErrorIs(t, err, ErrInvalidTransactionDirection)
+	})
+}
+
+// metadataBackendStub is a backend stub that stores the metadata of one transaction (like the database)
+type metadataBackendStub struct {
+	Backend
+	tx *bux.Transaction
+}
+
+// GetTransaction returns the transaction
+func (m *metadataBackendStub) GetTransaction(context.Context, string, string) (*bux.Transaction, error) {
+	return m.tx, nil
+}
+
+// UpdateTransactionMetadata updates the metadata of the xpub (the global metadata is not changed)
+func (m *metadataBackendStub) UpdateTransactionMetadata(_ context.Context, xpubID, _ string,
+	metadata bux.Metadata) (*bux.Transaction, error) {
+	if err := m.tx.UpdateTransactionMetadata(xpubID, metadata); err != nil {
+		return nil, err
+	}
+	return m.tx, nil
+}
+
+// buxClientStub is a BUX client stub (database mode)
+type buxClientStub struct {
+	bux.ClientInterface
+}
+
+func TestXpubTransactionMetadata(t *testing.T) {
+	t.Parallel()
+
+	tx := &bux.Transaction{
+		Model:        bux.Model{Metadata: bux.Metadata{"global": "value", "name": "global"}},
+		XpubMetadata: bux.XpubMetadata{"xpub-id": {"name": "xpub"}, "other-id": {"other": "value"}},
+	}
+	assert.Equal(t, bux.Metadata{"name": "xpub"}, xpubTransactionMetadata(tx, "xpub-id", true))
+	assert.Empty(t, xpubTransactionMetadata(tx, "unknown-id", true))
+	assert.Equal(t, bux.Metadata{"global": "value", "name": "global"}, xpubTransactionMetadata(tx, "xpub-id", false))
+	assert.Empty(t, xpubTransactionMetadata(nil, "xpub-id", true))
+}
+
+func TestUpdateTransactionMetadata(t *testing.T) {
+	t.Parallel()
+
+	t.Run("replace keeps the global metadata out", func(t *testing.T) {
+		backend := &metadataBackendStub{tx: &bux.Transaction{
+			Model:        bux.Model{Metadata: bux.Metadata{"global": "value"}},
+			XpubMetadata: bux.XpubMetadata{"xpub-id": {"name": "old", "note": "old"}},
+		}}
+		app := &App{backend: backend, bux: &buxClientStub{}}
+
+		tx, err := updateTransactionMetadata(context.Background(), app, "xpub-id", "tx-id",
+			bux.Metadata{"name": "new"}, nil, true)
+		require.NoError(t, err)
+		assert.Equal(t, bux.Metadata{"name": "new"}, tx.Bux.Metadata)
+		assert.Equal(t, bux.Metadata{"name": "new"}, backend.tx.XpubMetadata["xpub-id"])
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		backend := &metadataBackendStub{tx: &bux.Transaction{
+			Model:        bux.Model{Metadata: bux.Metadata{"global": "value"}},
+			XpubMetadata: bux.XpubMetadata{"xpub-id": {"name": "old"}},
+		}}
+		app := &App{backend: backend, bux: &buxClientStub{}}
+
+		tx, err := updateTransactionMetadata(context.Background(), app, "xpub-id", "tx-id",
+			bux.Metadata{"note": "new"}, []string{"name"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, bux.Metadata{"note": "new"}, tx.Bux.Metadata)
+	})
+}
+
+func TestParseTransactionHex(t *testing.T) {
+	t.Parallel()
+
+	tx, err := parseTransactionHex(newTestDraft(t, testMnemonicVectors[0].xpriv).Hex)
+	require.NoError(t, err)
+	assert.Len(t, tx.Inputs, 1)
+
+	_, err = parseTransactionHex("not-hex")
+	require.ErrorIs(t, err, ErrInvalidTransactionHex)
+
+	// No inputs or outputs
+	_, err = parseTransactionHex("01000000000000000000")
+	require.ErrorIs(t, err, ErrInvalidTransactionHex)
+}
+
+func TestReadHexFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "signed.hex")
+	require.NoError(t, os.WriteFile(path, []byte("0100\n"), 0o600))
+	txHex, err := readHexFile(path, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "0100", txHex)
+
+	txHex, err = readHexFile(stdinPath, strings.NewReader(" 0200 \n"))
+	require.NoError(t, err)
+	assert.Equal(t, "0200", txHex)
+
+	_, err = readHexFile(filepath.Join(t.TempDir(), "missing.hex"), nil)
+	require.Error(t, err)
+}
+
+func TestRecordTransaction(t *testing.T) {
+	t.Parallel()
+
+	_, err := recordTransaction(context.Background(), new(App), testXpub, "", "", "", nil)
+	require.ErrorIs(t, err, ErrTransactionIDOrHexIsRequired)
+
+	_, err = recordTransaction(context.Background(), new(App), testXpub, "draft-id", "", "0100", nil)
+	require.ErrorIs(t, err, ErrInvalidTransactionHex)
+}
+
+func TestRecordTransactions(t *testing.T) {
+	t.Parallel()
+
+	var req *http.Request
+	body := map[string]interface{}{}
+	server := newTestServer(t, http.StatusCreated, &bux.Transaction{}, &req, &body)
+	backend, err := newServerBackend(&ServerConfig{URL: server.URL}, "test")
+	require.NoError(t, err)
+
+	txHex := newTestDraft(t, testMnemonicVectors[0].xpriv).Hex
+	parsed, err := parseTransactionHex(txHex)
+	require.NoError(t, err)
+
+	// The empty lines are skipped, the invalid lines are not sent
+	results := recordTransactions(context.Background(), &App{backend: backend}, testXpub,
+		txHex+"\n\nnot-hex\n", bux.Metadata{"batch": "1"})
+	require.Len(t, results, 2)
+	assert.Equal(t, &RecordResult{Line: 1, Recorded: true, TxID: parsed.TxID()}, results[0])
+	assert.Equal(t, 3, results[1].Line)
+	assert.False(t, results[1].Recorded)
+	assert.Contains(t, results[1].Error, ErrInvalidTransactionHex.Error())
+	assert.Equal(t, txHex, body["hex"])
+	assert.Equal(t, map[string]interface{}{"batch": "1"}, body["metadata"])
+
+	err = recordResultsError(results)
+	require.ErrorIs(t, err, ErrRecordFailed)
+	assert.Contains(t, err.Error(), "1 of 2 (lines: 3)")
+	require.NoError(t, recordResultsError(results[:1]))
+}
@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BuxOrg/bux"
+	"github.com/fatih/color"
+	"github.com/mrz1836/go-datastore"
+	"github.com/spf13/cobra"
+)
+
+// commands for utxo
+const utxoCommandGet = "get"
+const utxoCommandList = "list"
+const utxoCommandName = "utxo"
+const utxoCommandSummary = "summary"
+
+// utxo statuses (filters for listing)
+const utxoStatusReserved = "reserved"
+const utxoStatusSpendable = "spendable"
+const utxoStatusSpent = "spent"
+const utxoStatusUnspent = "unspent"
+
+// returnUtxoCmd returns the utxo command
+func returnUtxoCmd(app *App) (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
+		Use:   utxoCommandName,
+		Short: "list and inspect unspent transaction outputs (utxos) in BUX",
+		Long: color.GreenString(`
+ ____ ______________  ____  ________   
+|    |   \__    ___/  \   \/  /\_____  \  
+|    |   /  |    |      \     /  /   |   \ 
+|    |  /   |    |      /     \ /    |    \
+|______/    |____|     /___/\  \\_______  /
+                             \_/        \/`) + `
+` + color.YellowString(`
+This command is for utxo (unspent transaction output) related commands.
+
+list: returns the utxos for an xpub (`+utxoCommandName+` `+utxoCommandList+` <xpub_id> --status=unspent --min-sats=1000)
+get: gets a utxo by transaction id and output index (`+utxoCommandName+` `+utxoCommandGet+` <txid:vout>)
+summary: returns the count, total and value histogram of the utxos (`+utxoCommandName+` `+utxoCommandSummary+` <xpub_id>)
+`),
+		Aliases: []string{"utxos"},
+		Example: applicationName + " " + utxoCommandName + " " + utxoCommandList + " <xpub_id>",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("%s %w, IE: %s, etc.", utxoCommandName, ErrSubcommandIsRequired, utxoCommandList)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Initialize the BUX client
+			deferFunc, err := app.InitializeBUX()
+			if err != nil {
+				return err
+			}
+			defer deferFunc()
+
+			// Parse Metadata
+			var metaData bux.Metadata
+			if metaData, err = getMetadataFlag(cmd); err != nil {
+				return err
+			}
+
+			// Switch on the subcommand
+			if args[0] == utxoCommandList { // List the utxos
+
+				// Check if xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubIDIsRequired
+				}
+
+				// Build the filters and the pagination
+				var conditions map[string]interface{}
+				if conditions, err = utxoFilters(context.Background(), app, args[1], utxoStatus); err != nil {
+					return err
+				}
+				var queryParams *datastore.QueryParams
+				if queryParams, err = newQueryParams(page, pageSize, orderBy, sortDirection); err != nil {
+					return err
+				}
+
+				// Get the utxos
+				var utxos []*bux.Utxo
+				var total int64
+				if utxos, total, err = listUtxos(
+					context.Background(), app, args[1], metaData, conditions, queryParams,
+				); err != nil {
+					return err
+				}
+
+				// Display the utxos
+				displayPage(queryParams, len(utxos), total)
+				return displayModel(utxos)
+
+			} else if args[0] == utxoCommandGet { // Get a utxo
+
+				// Check if the utxo is provided
+				if len(args) < 2 {
+					return ErrUtxoIsRequired
+				}
+
+				// Parse the utxo pointer
+				txID, outputIndex, parseErr := parseUtxoPointer(args[1])
+				if parseErr != nil {
+					return parseErr
+				}
+
+				// Get the utxo
+				var utxo *bux.Utxo
+				if utxo, err = app.backend.GetUtxo(context.Background(), txID, outputIndex); err != nil {
+					return fmt.Errorf("error getting utxo: %w", err)
+				}
+
+				// Display the utxo
+				return displayModel(utxo)
+
+			} else if args[0] == utxoCommandSummary { // Summarize the utxos
+
+				// Check if xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubIDIsRequired
+				}
+
+				// The summary is for the unspent utxos, unless another status is requested
+				status := utxoStatus
+				if len(status) == 0 {
+					status = utxoStatusUnspent
+				}
+
+				// Build the filters
+				var conditions map[string]interface{}
+				if conditions, err = utxoFilters(context.Background(), app, args[1], status); err != nil {
+					return err
+				}
+
+				// Summarize the utxos
+				var summary *UtxoSummary
+				if summary, err = summarizeUtxos(
+					context.Background(), app, args[1], metaData, conditions,
+				); err != nil {
+					return err
+				}
+				summary.Status = status
+
+				// Display the summary
+				return displayModel(summary)
+			}
+
+			return ErrUnknownSubcommand
+		},
+	}
+
+	// Set the metadata flag
+	addMetadataFlag(newCmd)
+
+	// Set the list filters
+	newCmd.Flags().StringVar(&utxoStatus, flagStatus, "", "Filter by status: "+strings.Join([]string{
+		utxoStatusUnspent, utxoStatusSpent, utxoStatusReserved, utxoStatusSpendable,
+	}, ", "))
+	newCmd.Flags().StringVar(&destinationFilter, flagDestination, "",
+		"Filter by destination (id, address or locking script)")
+	newCmd.Flags().Uint64Var(&minSatoshis, flagMinSatoshis, 0, "Filter by value (minimum satoshis, inclusive)")
+	newCmd.Flags().Uint64Var(&maxSatoshis, flagMaxSatoshis, 0, "Filter by value (maximum satoshis, inclusive)")
+	addPaginationFlags(newCmd)
+
+	return
+}
+
+// utxoFilters will build the conditions for the utxo flags (resolves the destination filter)
+func utxoFilters(ctx context.Context, app *App, xpubID, status string) (map[string]interface{}, error) {
+	var lockingScript string
+	if len(destinationFilter) > 0 {
+		destination, err := app.backend.GetDestination(ctx, xpubID, destinationFilter)
+		if err != nil {
+			return nil, fmt.Errorf("error getting destination: %w", err)
+		}
+		lockingScript = destination.LockingScript
+	}
+	return utxoConditions(status, lockingScript, minSatoshis, maxSatoshis)
+}
+
+// utxoConditions will build the conditions for listing utxos from the filters
+func utxoConditions(status, lockingScript string, minSats, maxSats uint64) (map[string]interface{}, error) {
+	conditions := make(map[string]interface{})
+
+	// Status is based on the spending tx and the draft reservation
+	switch status {
+	case "":
+	case utxoStatusSpent:
+		conditions["spending_tx_id"] = map[string]interface{}{conditionExists: true}
+	case utxoStatusUnspent:
+		conditions["spending_tx_id"] = nil
+	case utxoStatusReserved:
+		conditions["spending_tx_id"] = nil
+		conditions["draft_id"] = map[string]interface{}{conditionExists: true}
+	case utxoStatusSpendable:
+		conditions["spending_tx_id"] = nil
+		conditions["draft_id"] = nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidUtxoStatus, status)
+	}
+
+	// Destination
+	if len(lockingScript) > 0 {
+		conditions["script_pub_key"] = lockingScript
+	}
+
+	// Value range
+	if minSats > 0 && maxSats > 0 && minSats > maxSats {
+		return nil, fmt.Errorf("%w: %d satoshis is more than %d", ErrInvalidRange, minSats, maxSats)
+	}
+	satoshis := make(map[string]interface{})
+	if minSats > 0 {
+		satoshis[conditionGreaterEq] = minSats
+	}
+	if maxSats > 0 {
+		satoshis[conditionLessEq] = maxSats
+	}
+	if len(satoshis) > 0 {
+		conditions["satoshis"] = satoshis
+	}
+	return conditions, nil
+}
+
+// parseUtxoPointer will parse the utxo in the format <txid:vout>
+func parseUtxoPointer(pointer string) (txID string, outputIndex uint32, err error) {
+	parts := strings.Split(pointer, ":")
+	if len(parts) != 2 || len(parts[0]) != 64 {
+		err = fmt.Errorf("%w: %s", ErrInvalidUtxoPointer, pointer)
+		return
+	}
+	var index uint64
+	if index, err = strconv.ParseUint(parts[1], 10, 32); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidUtxoPointer, pointer)
+		return
+	}
+	return parts[0], uint32(index), nil
+}
+
+// listUtxos gets a page of utxos for the xpub and the total count matching the filters
+func listUtxos(ctx context.Context, app *App, xpubID string, metaData bux.Metadata, conditions map[string]interface{},
+	queryParams *datastore.QueryParams) (utxos []*bux.Utxo, total int64, err error) {
+
+	// Get the utxos
+	if utxos, err = app.backend.GetUtxos(ctx, xpubID, metadataConditions(metaData), conditions, queryParams); err != nil {
+		return
+	}
+
+	// Get the total count
+	total, err = app.backend.GetUtxosCount(ctx, xpubID, metadataConditions(metaData), conditions)
+	return
+}
+
+// summarizeUtxos gets all the utxos for the xpub (page by page) and returns the summary
+func summarizeUtxos(ctx context.Context, app *App, xpubID string, metaData bux.Metadata,
+	conditions map[string]interface{}) (summary *UtxoSummary, err error) {
+
+	// Get all the utxos
+	var utxos []*bux.Utxo
+	if utxos, err = getAllUtxos(ctx, app, xpubID, metaData, conditions); err != nil {
+		return
+	}
+
+	summary = newUtxoSummary(xpubID, utxos)
+	return
+}
+
+// getAllUtxos gets all the utxos for the xpub matching the metadata and conditions (page by page)
+func getAllUtxos(ctx context.Context, app *App, xpubID string, metaData bux.Metadata,
+	conditions map[string]interface{}) (utxos []*bux.Utxo, err error) {
+	for currentPage := 1; ; currentPage++ {
+		var results []*bux.Utxo
+		if results, err = app.backend.GetUtxos(
+			ctx, xpubID, metadataConditions(metaData), conditions, allPagesQueryParams(currentPage),
+		); err != nil {
+			return
+		}
+		utxos = append(utxos, results...)
+		if len(results) < maxPageSize {
+			return
+		}
+	}
+}
+
+// newUtxoSummary will count & total the utxos and group the values in a histogram (powers of 10)
+func newUtxoSummary(xpubID string, utxos []*bux.Utxo) *UtxoSummary {
+	summary := &UtxoSummary{XpubID: xpubID, Histogram: []*UtxoHistogramBucket{}}
+	buckets := make(map[uint64]*UtxoHistogramBucket)
+	for _, utxo := range utxos {
+		summary.Count++
+		summary.TotalSatoshis += utxo.Satoshis
+		if utxo.DraftID.Valid && len(utxo.DraftID.String) > 0 {
+			summary.ReservedCount++
+			summary.ReservedSatoshis += utxo.Satoshis
+		}
+
+		// Find the bucket (0, 1-9, 10-99, 100-999, etc.)
+		var minValue, maxValue uint64
+		if utxo.Satoshis > 0 {
+			minValue = 1
+			for minValue <= utxo.Satoshis/10 {
+				minValue *= 10
+			}
+			maxValue = minValue*10 - 1
+		}
+		bucket, ok := buckets[minValue]
+		if !ok {
+			bucket = &UtxoHistogramBucket{Min: minValue, Max: maxValue}
+			buckets[minValue] = bucket
+			summary.Histogram = append(summary.Histogram, bucket)
+		}
+		bucket.Count++
+		bucket.Satoshis += utxo.Satoshis
+	}
+	sort.Slice(summary.Histogram, func(i, j int) bool {
+		return summary.Histogram[i].Min < summary.Histogram[j].Min
+	})
+	return summary
+}
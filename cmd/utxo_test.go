@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BuxOrg/bux"
+	customTypes "github.com/mrz1836/go-datastore/custom_types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTxID is a valid transaction id used for testing
+const testTxID = "2d2a7f3d4e8d0f3c4a6e3b1c2d9f0e8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e"
+
+func TestParseUtxoPointer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid pointer", func(t *testing.T) {
+		txID, outputIndex, err := parseUtxoPointer(testTxID + ":3")
+		require.NoError(t, err)
+		assert.Equal(t, testTxID, txID)
+		assert.Equal(t, uint32(3), outputIndex)
+	})
+
+	for _, pointer := range []string{"", testTxID, testTxID + ":", testTxID + ":-1", "abc:0", testTxID + ":1:2"} {
+		t.Run("invalid pointer: "+pointer, func(t *testing.T) {
+			_, _, err := parseUtxoPointer(pointer)
+			require.ErrorIs(t, err, ErrInvalidUtxoPointer)
+		})
+	}
+}
+
+func TestUtxoConditions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("spendable with a value range", func(t *testing.T) {
+		conditions, err := utxoConditions(utxoStatusSpendable, "76a914", 1000, 5000)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"draft_id":       nil,
+			"satoshis":       map[string]interface{}{conditionGreaterEq: uint64(1000), conditionLessEq: uint64(5000)},
+			"script_pub_key": "76a914",
+			"spending_tx_id": nil,
+		}, conditions)
+	})
+
+	t.Run("spent", func(t *testing.T) {
+		conditions, err := utxoConditions(utxoStatusSpent, "", 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{conditionExists: true}, conditions["spending_tx_id"])
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := utxoConditions("frozen", "", 0, 0)
+		require.ErrorIs(t, err, ErrInvalidUtxoStatus)
+	})
+
+	t.Run("invalid value range", func(t *testing.T) {
+		_, err := utxoConditions("", "", 5000, 1000)
+		require.ErrorIs(t, err, ErrInvalidRange)
+	})
+}
+
+func TestNewUtxoSummary(t *testing.T) {
+	t.Parallel()
+
+	reserved := customTypes.NullString{NullString: sql.NullString{String: "draft-id", Valid: true}}
+	summary := newUtxoSummary("xpub-id", []*bux.Utxo{
+		{Satoshis: 5},
+		{Satoshis: 1000, DraftID: reserved},
+		{Satoshis: 9999},
+		{Satoshis: 1},
+		{Satoshis: 10},
+	})
+
+	assert.Equal(t, "xpub-id", summary.XpubID)
+	assert.Equal(t, int64(5), summary.Count)
+	assert.Equal(t, uint64(11015), summary.TotalSatoshis)
+	assert.Equal(t, int64(1), summary.ReservedCount)
+	assert.Equal(t, uint64(1000), summary.ReservedSatoshis)
+	assert.Equal(t, []*UtxoHistogramBucket{
+		{Min: 1, Max: 9, Count: 2, Satoshis: 6},
+		{Min: 10, Max: 99, Count: 1, Satoshis: 10},
+		{Min: 1000, Max: 9999, Count: 2, Satoshis: 10999},
+	}, summary.Histogram)
+}
+
+func TestGetAllUtxos(t *testing.T) {
+	t.Parallel()
+
+	// The first page is full, the second page is the last one
+	params := make([]map[string]interface{}, 0, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		params = append(params, body["params"].(map[string]interface{}))
+		utxos := make([]*bux.Utxo, maxPageSize)
+		if len(params) > 1 {
+			utxos = utxos[:3]
+		}
+		for index := range utxos {
+			utxos[index] = &bux.Utxo{Satoshis: 1}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(utxos))
+	}))
+	t.Cleanup(server.Close)
+	backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: testXpub}, "test")
+	require.NoError(t, err)
+
+	utxos, err := getAllUtxos(context.Background(), &App{backend: backend}, testXpubID, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, utxos, maxPageSize+3)
+
+	// The pages are ordered by the id (unique), the satoshis are all equal
+	require.Len(t, params, 2)
+	for index, param := range params {
+		assert.Equal(t, map[string]interface{}{
+			"order_by_field": "id", "page": float64(index + 1), "page_size": float64(maxPageSize), "sort_direction": "asc",
+		}, param)
+	}
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux-cli/chalker"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// commands for worker
+const workerCommandName = "worker"
+
+// workerTask is a registered BUX task that is run by the worker
+type workerTask struct {
+	name   string                          // Name of the task (IE: draft_transaction_clean_up)
+	period time.Duration                   // Time between runs (from the BUX task config)
+	run    func(ctx context.Context) error // Runs the task handler (blocking)
+}
+
+// workerCron is a cron service that does not schedule anything
+//
+// The worker replaces the BUX cron so that each task runs in the foreground (timed and logged),
+// instead of being added to the queue in the background
+type workerCron struct{}
+
+// AddFunc will ignore the scheduled function (the worker schedules the tasks)
+func (c *workerCron) AddFunc(_ string, _ func()) (int, error) { return 0, nil }
+
+// New is not used by the worker cron
+func (c *workerCron) New() {}
+
+// Start is not used by the worker cron
+func (c *workerCron) Start() {}
+
+// Stop is not used by the worker cron
+func (c *workerCron) Stop() {}
+
+// returnWorkerCmd returns the worker command
+func returnWorkerCmd(app *App) (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
+		Use:   workerCommandName,
+		Short: "runs the BUX tasks continuously (database mode only)",
+		Long: color.GreenString(`
+ __      __  ________    __________   ____  __. ___________ __________
+/  \    /  \ \_____  \   \______   \ |    |/ _| \_   _____/ \______   \
+\   \/\/   /  /   |   \   |       _/ |      <    |    __)_   |       _/
+ \        /  /    |    \  |    |   \ |    |  \   |        \  |    |   \
+  \__/\  /   \_______  /  |____|_  / |____|__ \ /_______  /  |____|_  /
+       \/            \/          \/          \/         \/          \/`) + `
+` + color.YellowString(`
+This command runs the BUX tasks (IE: draft clean up, incoming transactions, broadcasting and syncing)
+on their schedule until it is stopped (SIGINT or SIGTERM), then shuts down BUX gracefully.
+
+Each task execution is logged (stderr) with the duration. The BUX tasks log their own errors and do not return
+them, so a task is only reported as failed if it panics or returns an error (check the BUX logs for the rest).
+Use --once to run the tasks a single time and exit, and --tasks to select the tasks to run.
+`),
+		Aliases: []string{"daemon"},
+		Example: applicationName + " " + workerCommandName + " --" + flagTasks + "=sync_transaction_sync",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// The worker schedules the tasks (instead of the BUX cron)
+			app.cronService = &workerCron{}
+
+			// Initialize the BUX client
+			deferFunc, err := app.InitializeBUX()
+			if err != nil {
+				return err
+			}
+			defer deferFunc()
+
+			// Tasks are only available with a local BUX engine
+			if app.bux == nil {
+				return ErrNotSupportedInServerMode
+			} else if app.bux.Taskmanager() == nil {
+				return bux.ErrTaskManagerNotLoaded
+			}
+
+			// Select the tasks
+			var tasks []*workerTask
+			if tasks, err = selectWorkerTasks(registeredWorkerTasks(app.bux), workerTaskNames); err != nil {
+				return err
+			}
+
+			// Run the tasks a single time
+			if workerOnce {
+				chalker.LogTo(os.Stderr, chalker.INFO, fmt.Sprintf("Running %d task(s)...", len(tasks)))
+				return runWorkerTasksOnce(context.Background(), tasks)
+			}
+
+			// Run the tasks until the worker is stopped
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			for _, task := range tasks {
+				chalker.LogTo(os.Stderr, chalker.INFO,
+					fmt.Sprintf("Scheduled task %s (every %s)", task.name, task.period))
+			}
+			chalker.LogTo(os.Stderr, chalker.SUCCESS,
+				fmt.Sprintf("Worker started with %d task(s), press Ctrl+C to stop", len(tasks)))
+			runWorker(ctx, tasks)
+			chalker.LogTo(os.Stderr, chalker.INFO, "Worker stopped, closing BUX...")
+			return nil
+		},
+	}
+
+	// Set the worker flags
+	newCmd.Flags().BoolVar(&workerOnce, flagOnce, false, "Run the tasks a single time and exit")
+	newCmd.Flags().StringSliceVar(&workerTaskNames, flagTasks, nil, "Tasks to run (default: all the BUX tasks)")
+
+	return
+}
+
+// registeredWorkerTasks will return the tasks registered in BUX that run on a schedule (sorted by name)
+func registeredWorkerTasks(client bux.ClientInterface) []*workerTask {
+	tasks := make([]*workerTask, 0)
+	for name, task := range client.Taskmanager().Tasks() {
+		period := client.GetTaskPeriod(name)
+		if period <= 0 {
+			continue
+		}
+		task := task
+		tasks = append(tasks, &workerTask{
+			name:   name,
+			period: period,
+			run: func(ctx context.Context) error {
+				return task.HandleMessage(task.WithArgs(ctx, client))
+			},
+		})
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].name < tasks[j].name
+	})
+	return tasks
+}
+
+// selectWorkerTasks will return the tasks by name (all the tasks if no names are given)
+func selectWorkerTasks(tasks []*workerTask, names []string) ([]*workerTask, error) {
+	if len(names) == 0 {
+		if len(tasks) == 0 {
+			return nil, ErrNoTasksFound
+		}
+		return tasks, nil
+	}
+
+	// Find each task by name
+	available := make([]string, 0, len(tasks))
+	byName := make(map[string]*workerTask, len(tasks))
+	for _, task := range tasks {
+		available = append(available, task.name)
+		byName[task.name] = task
+	}
+	selected := make([]*workerTask, 0, len(names))
+	for _, name := range names {
+		task, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s (available: %s)", ErrUnknownTask, name, strings.Join(available, ", "))
+		}
+		selected = append(selected, task)
+	}
+	return selected, nil
+}
+
+// runWorker will run each task on its period until the context is canceled
+//
+// Running tasks are not interrupted, the worker waits for them to finish before returning
+func runWorker(ctx context.Context, tasks []*workerTask) {
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task *workerTask) {
+			defer wg.Done()
+			ticker := time.NewTicker(task.period)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					_ = runWorkerTask(context.Background(), task)
+				}
+			}
+		}(task)
+	}
+	wg.Wait()
+}
+
+// runWorkerTasksOnce will run each task a single time (in order), returns an error if any task failed
+func runWorkerTasksOnce(ctx context.Context, tasks []*workerTask) error {
+	var failed []string
+	for _, task := range tasks {
+		if err := runWorkerTask(ctx, task); err != nil {
+			failed = append(failed, task.name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrTaskFailed, strings.Join(failed, ", "))
+	}
+	chalker.LogTo(os.Stderr, chalker.SUCCESS, fmt.Sprintf("All %d task(s) complete", len(tasks)))
+	return nil
+}
+
+// runWorkerTask will run the task and log the duration and the result (a panic is returned as an error)
+func runWorkerTask(ctx context.Context, task *workerTask) (err error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+		duration := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			chalker.LogTo(os.Stderr, chalker.ERROR, fmt.Sprintf("%s task %s failed after %s: %s",
+				start.Format(time.RFC3339), task.name, duration, err.Error()))
+		} else {
+			chalker.LogTo(os.Stderr, chalker.SUCCESS, fmt.Sprintf("%s task %s completed in %s",
+				start.Format(time.RFC3339), task.name, duration))
+		}
+	}()
+	return task.run(ctx)
+}
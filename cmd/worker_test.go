@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWorkerTask will return a task that counts the runs and returns the error
+func newTestWorkerTask(name string, period time.Duration, runs *int32, err error) *workerTask {
+	return &workerTask{
+		name:   name,
+		period: period,
+		run: func(_ context.Context) error {
+			atomic.AddInt32(runs, 1)
+			return err
+		},
+	}
+}
+
+func TestSelectWorkerTasks(t *testing.T) {
+	t.Parallel()
+
+	var runs int32
+	tasks := []*workerTask{
+		newTestWorkerTask("draft_transaction_clean_up", time.Minute, &runs, nil),
+		newTestWorkerTask("sync_transaction_sync", time.Minute, &runs, nil),
+	}
+
+	t.Run("all tasks", func(t *testing.T) {
+		selected, err := selectWorkerTasks(tasks, nil)
+		require.NoError(t, err)
+		assert.Len(t, selected, 2)
+	})
+
+	t.Run("subset of tasks", func(t *testing.T) {
+		selected, err := selectWorkerTasks(tasks, []string{" sync_transaction_sync"})
+		require.NoError(t, err)
+		require.Len(t, selected, 1)
+		assert.Equal(t, "sync_transaction_sync", selected[0].name)
+	})
+
+	t.Run("unknown task", func(t *testing.T) {
+		_, err := selectWorkerTasks(tasks, []string{"unknown_task"})
+		require.ErrorIs(t, err, ErrUnknownTask)
+		assert.Contains(t, err.Error(), "draft_transaction_clean_up, sync_transaction_sync")
+	})
+
+	t.Run("no tasks registered", func(t *testing.T) {
+		_, err := selectWorkerTasks(nil, nil)
+		require.ErrorIs(t, err, ErrNoTasksFound)
+	})
+}
+
+func TestRunWorkerTasksOnce(t *testing.T) {
+	t.Parallel()
+
+	t.Run("all tasks succeed", func(t *testing.T) {
+		var runs int32
+		err := runWorkerTasksOnce(context.Background(), []*workerTask{
+			newTestWorkerTask("task_one", time.Minute, &runs, nil),
+			newTestWorkerTask("task_two", time.Minute, &runs, nil),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), runs)
+	})
+
+	t.Run("failed tasks are reported, the other tasks still run", func(t *testing.T) {
+		var runs int32
+		err := runWorkerTasksOnce(context.Background(), []*workerTask{
+			newTestWorkerTask("task_one", time.Minute, &runs, errors.New("failed")),
+			newTestWorkerTask("task_two", time.Minute, &runs, nil),
+		})
+		require.ErrorIs(t, err, ErrTaskFailed)
+		assert.Contains(t, err.Error(), "task_one")
+		assert.Equal(t, int32(2), runs)
+	})
+
+	t.Run("a panic is returned as an error", func(t *testing.T) {
+		err := runWorkerTasksOnce(context.Background(), []*workerTask{{
+			name:   "task_panic",
+			period: time.Minute,
+			run: func(_ context.Context) error {
+				panic("boom")
+			},
+		}})
+		require.ErrorIs(t, err, ErrTaskFailed)
+	})
+}
+
+func TestRunWorker(t *testing.T) {
+	t.Parallel()
+
+	var runs int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runWorker(ctx, []*workerTask{newTestWorkerTask("task_one", 10*time.Millisecond, &runs, nil)})
+		close(done)
+	}()
+
+	// The task runs on its period until the worker is stopped
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&runs) >= 2
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop")
+	}
+}
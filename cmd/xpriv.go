@@ -1,25 +1,35 @@
 package cmd
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
-	"errors"
+	"fmt"
+	"strings"
 
-	"github.com/BuxOrg/bux-cli/chalker"
 	"github.com/bitcoinschema/go-bitcoin/v2"
 	"github.com/fatih/color"
 	"github.com/libsv/go-bk/bec"
+	"github.com/libsv/go-bk/bip32"
+	"github.com/libsv/go-bk/bip39"
+	"github.com/libsv/go-bk/chaincfg"
 	"github.com/libsv/go-bk/wif"
 	"github.com/spf13/cobra"
+	"golang.org/x/text/unicode/norm"
 )
 
 // commands for xpriv
 const xprivCommandName = "xpriv"
 const xprivCommandNew = "new"
 const xprivCommandInfo = "info"
+const xprivCommandRestore = "restore"
+const xprivCommandDerive = "derive"
+
+// mnemonicDefaultWords is the default number of words for a new mnemonic
+const mnemonicDefaultWords = 12
 
 // returnXprivCmd returns the xpriv command
-func returnXprivCmd() *cobra.Command {
-	return &cobra.Command{
+func returnXprivCmd() (newCmd *cobra.Command) {
+	newCmd = &cobra.Command{
 		Use:   xprivCommandName,
 		Short: "create new xpriv keys and see additional info",
 		Long: color.GreenString(`
@@ -33,84 +43,293 @@ ____  _______________________._______   ____
 This command is for xpriv key related commands. These commands are read-only and no data is stored on the BUX servers.
 
 new: creates a new xpriv key (`+xprivCommandName+` `+xprivCommandNew+`)
-info: gets the xpub, WIF and other info from the xpriv key (`+xprivCommandName+` `+xprivCommandInfo+` <xpriv>)
+info: gets the xpub, WIF and other info from the xpriv key (`+xprivCommandName+` `+xprivCommandInfo+` <xpriv> | --`+flagKey+`=<name>)
+restore: restores the xpriv key from a BIP39 mnemonic (`+xprivCommandName+` `+xprivCommandRestore+` "<mnemonic>")
+derive: derives the child keys, address and WIF (`+xprivCommandName+` `+xprivCommandDerive+` <xpriv> --`+flagPath+`=m/0/5)
+
+Use --mnemonic with new to create the xpriv from a BIP39 mnemonic (--words 12 or 24), keep the words safe!
+The optional --passphrase is added to the mnemonic seed, the same passphrase is required to restore the xpriv.
 `),
 		// Aliases: []string{"priv"},
-		Example: applicationName + " " + xprivCommandName + " " + xprivCommandNew,
+		Example: applicationName + " " + xprivCommandName + " " + xprivCommandNew + `
+` + applicationName + " " + xprivCommandName + " " + xprivCommandNew + " --" + flagMnemonic + " --" + flagWords + `=24
+` + applicationName + " " + xprivCommandName + " " + xprivCommandRestore + ` "<mnemonic>" --` +
+			flagPassphrase + `=<passphrase>
+` + applicationName + " " + xprivCommandName + " " + xprivCommandDerive + " <xpriv> --" + flagPath + "=m/0 --" +
+			flagRange + "=0-99",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) < 1 {
-				return chalker.Error(xprivCommandName + " requires a subcommand, IE: " + xprivCommandNew + ", " + xprivCommandInfo + ", etc.")
+				return fmt.Errorf("%s %w, IE: %s, etc.", xprivCommandName, ErrSubcommandIsRequired,
+					xprivCommandNew+", "+xprivCommandInfo+", "+xprivCommandRestore+", "+xprivCommandDerive)
 			}
 			return nil
 		},
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 
-			keys := new(Keys)
+			var keys *Keys
 
 			// Switch on the subcommand
 			if args[0] == xprivCommandNew { // Create a new xpriv key
 
+				// Create a new xpriv key from a mnemonic (setting the words or passphrase implies a mnemonic)
+				if mnemonicEnabled || cmd.Flags().Changed(flagWords) || cmd.Flags().Changed(flagPassphrase) {
+					keys, err := newMnemonicKeys(mnemonicWords, mnemonicPassphrase)
+					if err != nil {
+						return err
+					}
+
+					// Display the model (the same keys as restore, with the mnemonic)
+					return displayModel(keys)
+				}
+
 				// Create a new xpriv key
 				key, err := bitcoin.GenerateHDKey(bitcoin.SecureSeedLength)
 				if err != nil {
-					displayError(errors.New("error generating new xpriv: " + err.Error()))
-					return
+					return fmt.Errorf("error generating new xpriv: %w", err)
 				}
 
-				// Set the xpriv in the returned struct
-				keys.Xpriv = key.String()
+				// Get the keys from the hd key
+				if keys, err = newKeys(key); err != nil {
+					return err
+				}
 
 				// Display the model
-				displayModel(keys)
+				return displayModel(keys)
 			} else if args[0] == xprivCommandInfo { // Get the xpub, WIF and other info from the xpriv key
 
-				// Check if xpriv is provided
-				if len(args) < 2 {
-					displayError(ErrXprivIsRequired)
-					return
+				// Get the xpriv from the args or the keystore (--key)
+				var xprivKey string
+				if len(args) > 1 {
+					xprivKey = args[1]
+				}
+				keys, err := xprivInfo(xprivKey)
+				if err != nil {
+					return err
 				}
 
-				// Set the xpriv from the args
-				keys.Xpriv = args[1]
+				// Display the model
+				return displayModel(keys)
+			} else if args[0] == xprivCommandRestore { // Restore the xpriv key from a mnemonic
 
-				// Get the hd key from the xpriv
-				key, err := bitcoin.GenerateHDKeyFromString(keys.Xpriv)
-				if err != nil {
-					displayError(errors.New("error generating HD key from xpriv: " + err.Error()))
-					return
+				// Check if the mnemonic is provided (quoted, or as separate words)
+				if len(args) < 2 {
+					return ErrMnemonicIsRequired
 				}
 
-				// Get the public key from the hd key
-				if keys.Xpub, err = bitcoin.GetExtendedPublicKey(key); err != nil {
-					displayError(errors.New("error generating xpub from xpriv: " + err.Error()))
-					return
+				// Get the master key from the mnemonic
+				key, err := mnemonicToKey(strings.Join(args[1:], " "), mnemonicPassphrase)
+				if err != nil {
+					return err
 				}
 
-				// Get the private key from the hd key
-				var privateKey *bec.PrivateKey
-				if privateKey, err = bitcoin.GetPrivateKeyFromHDKey(key); err != nil {
-					displayError(errors.New("error generating private key from xpriv: " + err.Error()))
-					return
+				// Get the keys from the hd key
+				if keys, err = newKeys(key); err != nil {
+					return err
 				}
 
-				// Get the private key as a hex string
-				keys.PrivateKey = hex.EncodeToString(privateKey.Serialise())
+				// Display the model
+				return displayModel(keys)
+			} else if args[0] == xprivCommandDerive { // Derive the child keys from the xpriv key
+
+				// Get the xpriv from the args or the keystore (--key)
+				var xprivKey string
+				if len(args) > 1 {
+					xprivKey = args[1]
+				}
+				xprivKey, err := getXpriv(xprivKey)
+				if err != nil {
+					return err
+				} else if len(xprivKey) == 0 {
+					return ErrXprivIsRequired
+				}
 
-				// Get the WIF from the private key
-				var wifKey *wif.WIF
-				if wifKey, err = bitcoin.PrivateKeyToWif(keys.PrivateKey); err != nil {
-					displayError(errors.New("error generating WIF from xpriv: " + err.Error()))
-					return
+				// Get the hd key from the xpriv
+				var key *bip32.ExtendedKey
+				if key, err = bitcoin.GenerateHDKeyFromString(xprivKey); err != nil {
+					return fmt.Errorf("%w: %s", ErrInvalidXpriv, err.Error())
 				}
 
-				// Set the WIF in the returned struct
-				keys.WIF = wifKey.String()
+				// Derive the keys for the path(s)
+				var paths [][]uint32
+				if paths, err = getDerivationPaths(cmd); err != nil {
+					return err
+				}
+				var derived []*DerivedKey
+				if derived, err = deriveKeys(key, paths); err != nil {
+					return err
+				}
 
-				// Display the model
-				displayModel(keys)
-			} else {
-				displayError(ErrUnknownSubcommand)
+				// Display the derived key(s)
+				return displayDerivedKeys(derived)
 			}
+
+			return ErrUnknownSubcommand
 		},
 	}
+
+	// Set the derivation flags
+	addDeriveFlags(newCmd)
+	newCmd.Flags().StringVar(&keyName, flagKey, "", "Name of the keystore key (instead of the xpriv argument)")
+
+	// Set the mnemonic flags
+	newCmd.Flags().BoolVar(&mnemonicEnabled, flagMnemonic, false, "Create the new xpriv from a BIP39 mnemonic")
+	newCmd.Flags().IntVar(&mnemonicWords, flagWords, mnemonicDefaultWords, "Number of words for the mnemonic: 12 or 24")
+	newCmd.Flags().StringVar(&mnemonicPassphrase, flagPassphrase, "", "Optional BIP39 passphrase for the mnemonic")
+
+	return
+}
+
+// xprivInfo will return the keys (see: newKeys) of the xpriv, or of the keystore key if --key is set
+func xprivInfo(xprivKey string) (*Keys, error) {
+	xprivKey, err := getXpriv(xprivKey)
+	if err != nil {
+		return nil, err
+	} else if len(xprivKey) == 0 {
+		return nil, ErrXprivIsRequired
+	}
+
+	// Get the hd key from the xpriv
+	var key *bip32.ExtendedKey
+	if key, err = bitcoin.GenerateHDKeyFromString(xprivKey); err != nil {
+		return nil, fmt.Errorf("error generating HD key from xpriv: %w", err)
+	}
+	return newKeys(key)
+}
+
+// newKeys will return the xpriv, xpub, private key and WIF from the hd key
+func newKeys(key *bip32.ExtendedKey) (*Keys, error) {
+
+	// Set the xpriv from the hd key
+	keys := &Keys{Xpriv: key.String()}
+
+	// Get the public key from the hd key
+	var err error
+	if keys.Xpub, err = bitcoin.GetExtendedPublicKey(key); err != nil {
+		return nil, fmt.Errorf("error generating xpub from xpriv: %w", err)
+	}
+
+	// Get the private key from the hd key
+	var privateKey *bec.PrivateKey
+	if privateKey, err = bitcoin.GetPrivateKeyFromHDKey(key); err != nil {
+		return nil, fmt.Errorf("error generating private key from xpriv: %w", err)
+	}
+
+	// Get the private key as a hex string
+	keys.PrivateKey = hex.EncodeToString(privateKey.Serialise())
+
+	// Get the WIF from the private key
+	var wifKey *wif.WIF
+	if wifKey, err = bitcoin.PrivateKeyToWif(keys.PrivateKey); err != nil {
+		return nil, fmt.Errorf("error generating WIF from xpriv: %w", err)
+	}
+
+	// Set the WIF in the returned struct
+	keys.WIF = wifKey.String()
+
+	return keys, nil
+}
+
+// newMnemonicKeys will return the keys (see: newKeys) of a new xpriv from a new BIP39 mnemonic
+func newMnemonicKeys(words int, passphrase string) (*Keys, error) {
+	mnemonic, seed, err := newMnemonic(words, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the master key from the seed
+	var key *bip32.ExtendedKey
+	if key, err = bip32.NewMaster(seed, &chaincfg.MainNet); err != nil {
+		return nil, fmt.Errorf("error generating xpriv from mnemonic: %w", err)
+	}
+
+	var keys *Keys
+	if keys, err = newKeys(key); err != nil {
+		return nil, err
+	}
+	keys.Mnemonic = mnemonic
+	return keys, nil
+}
+
+// newMnemonic will return a new BIP39 mnemonic with the number of words, and the seed for the passphrase
+func newMnemonic(words int, passphrase string) (string, []byte, error) {
+	if !validMnemonicWords(words) {
+		return "", nil, fmt.Errorf("%w: %d", ErrInvalidMnemonicWords, words)
+	}
+
+	// Every 3 words are 32 bits of entropy (12 words = 128 bits, 24 words = 256 bits)
+	entropy, err := bip39.GenerateEntropy(bip39.Entropy(words / 3 * 32))
+	if err != nil {
+		return "", nil, fmt.Errorf("error generating entropy for the mnemonic: %w", err)
+	}
+	return bip39.Mnemonic(entropy, norm.NFKD.String(passphrase))
+}
+
+// mnemonicToKey will validate the BIP39 mnemonic and return the master key for the passphrase
+func mnemonicToKey(mnemonic, passphrase string) (*bip32.ExtendedKey, error) {
+	seed, err := mnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *bip32.ExtendedKey
+	if key, err = bip32.NewMaster(seed, &chaincfg.MainNet); err != nil {
+		return nil, fmt.Errorf("error generating xpriv from mnemonic: %w", err)
+	}
+	return key, nil
+}
+
+// mnemonicToSeed will validate the BIP39 mnemonic (words & checksum) and return the seed for the passphrase
+//
+// The mnemonic is normalized (lowercase, single spaces) so that copy & paste differences give the same seed
+func mnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(norm.NFKD.String(mnemonic)))
+	if !validMnemonicWords(len(words)) {
+		return nil, fmt.Errorf("%w: %d words", ErrInvalidMnemonicWords, len(words))
+	}
+
+	// Convert the words to bits (11 bits per word)
+	bits := make([]byte, (len(words)*11+7)/8)
+	for i, word := range words {
+		index, ok := mnemonicWordIndex(word)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMnemonic, word)
+		}
+		for b := 0; b < 11; b++ {
+			if index&(1<<(10-b)) != 0 {
+				position := i*11 + b
+				bits[position/8] |= 1 << (7 - position%8)
+			}
+		}
+	}
+
+	// The last bits are the checksum: the first (entropy bits / 32) bits of the sha256 of the entropy
+	entropyBytes := len(words) * 4 / 3
+	checksumBits := len(words) / 3
+	hash := sha256.Sum256(bits[:entropyBytes])
+	if hash[0]>>(8-checksumBits) != bits[entropyBytes]>>(8-checksumBits) {
+		return nil, fmt.Errorf("%w: checksum does not match", ErrInvalidMnemonic)
+	}
+
+	return bip39.MnemonicToSeed(strings.Join(words, " "), norm.NFKD.String(passphrase))
+}
+
+// mnemonicWordIndex will return the index of the word in the BIP39 english word list
+func mnemonicWordIndex(word string) (int, bool) {
+	low, high := 0, len(bip39.English)-1
+	for low <= high {
+		middle := (low + high) / 2
+		if bip39.English[middle] == word {
+			return middle, true
+		} else if bip39.English[middle] < word {
+			low = middle + 1
+		} else {
+			high = middle - 1
+		}
+	}
+	return 0, false
+}
+
+// validMnemonicWords will return true if the number of words is allowed by BIP39 (12, 15, 18, 21 or 24)
+func validMnemonicWords(words int) bool {
+	return words >= 12 && words <= 24 && words%3 == 0
 }
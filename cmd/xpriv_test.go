@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/libsv/go-bk/bip32"
+	"github.com/libsv/go-bk/bip39"
+	"github.com/libsv/go-bk/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMnemonicPassphrase is the passphrase used by the BIP39 test vectors
+const testMnemonicPassphrase = "TREZOR"
+
+// testMnemonicVectors are the english test vectors from the BIP39 spec
+// (https://github.com/trezor/python-mnemonic/blob/master/vectors.json)
+var testMnemonicVectors = []struct {
+	entropy  string
+	mnemonic string
+	seed     string
+	xpriv    string
+}{
+	{
+		entropy:  "00000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		seed: "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf14" +
+			"1630c7a3c4ab7c81b2f001698e7463b04",
+		xpriv: "xprv9s21ZrQH143K3h3fDYiay8mocZ3afhfULfb5GX8kCBdno77K4Hi" +
+			"A15Tg23wpbeF1pLfs1c5SPmYHrEpTuuRhxMwvKDwqdKiGJS9XFKzUsAF",
+	},
+	{
+		entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+		seed: "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be" +
+			"3a3a5bd381ee6260e8d9739fce1f607",
+		xpriv: "xprv9s21ZrQH143K2gA81bYFHqU68xz1cX2APaSq5tt6MFSLeXnCKV1" +
+			"RVUJt9FWNTbrrryem4ZckN8k4Ls1H6nwdvDTvnV7zEXs2HgPezuVccsq",
+	},
+	{
+		entropy:  "80808080808080808080808080808080",
+		mnemonic: "letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		seed: "d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d" +
+			"18d69fe4f985ec81778c1b370b652a8",
+		xpriv: "xprv9s21ZrQH143K2shfP28KM3nr5Ap1SXjz8gc2rAqqMEynmjt6o1q" +
+			"boCDpxckqXavCwdnYds6yBHZGKHv7ef2eTXy461PXUjBFQg6PrwY4Gzq",
+	},
+	{
+		entropy:  "ffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		seed: "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61" +
+			"dee327651a14c34e18231052e48c069",
+		xpriv: "xprv9s21ZrQH143K2V4oox4M8Zmhi2Fjx5XK4Lf7GKRvPSgydU3mjZu" +
+			"KGCTg7UPiBUD7ydVPvSLtg9hjp7MQTYsW67rZHAXeccqYqrsx8LcXnyd",
+	},
+	{
+		entropy:  "9e885d952ad362caeb4efe34a8e91bd2",
+		mnemonic: "ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+		seed: "274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c9e5476c91689f9c8a54fd55bd38606aa6a8595" +
+			"ad213d4c9c9f9aca3fb217069a41028",
+		xpriv: "xprv9s21ZrQH143K2oZ9stBYpoaZ2ktHj7jLz7iMqpgg1En8kKFTXJH" +
+			"sjxry1JbKH19YrDTicVwKPehFKTbmaxgVEc5TpHdS1aYhB2s9aFJBeJH",
+	},
+	{
+		entropy: "0000000000000000000000000000000000000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+		seed: "bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3d" +
+			"e6f5d4a10be8ed2a5e608d68f92fcc8",
+		xpriv: "xprv9s21ZrQH143K32qBagUJAMU2LsHg3ka7jqMcV98Y7gVeVyNStwY" +
+			"S3U7yVVoDZ4btbRNf4h6ibWpY22iRmXq35qgLs79f312g2kj5539ebPM",
+	},
+	{
+		entropy:  "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		seed: "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c" +
+			"456cdf60f5d4564b8ba3f05a69890ad",
+		xpriv: "xprv9s21ZrQH143K2WFF16X85T2QCpndrGwx6GueB72Zf3AHwHJaknR" +
+			"XNF37ZmDrtHrrLSHvbuRejXcnYxoZKvRquTPyp2JiNG3XcjQyzSEgqCB",
+	},
+}
+
+func TestMnemonicVectors(t *testing.T) {
+	t.Parallel()
+
+	for _, vector := range testMnemonicVectors {
+		vector := vector
+		t.Run(vector.entropy, func(t *testing.T) {
+			t.Parallel()
+
+			// Entropy -> mnemonic & seed
+			entropy, err := hex.DecodeString(vector.entropy)
+			require.NoError(t, err)
+			mnemonic, seed, err := bip39.Mnemonic(entropy, testMnemonicPassphrase)
+			require.NoError(t, err)
+			assert.Equal(t, vector.mnemonic, mnemonic)
+			assert.Equal(t, vector.seed, hex.EncodeToString(seed))
+
+			// Mnemonic -> seed
+			seed, err = mnemonicToSeed(vector.mnemonic, testMnemonicPassphrase)
+			require.NoError(t, err)
+			assert.Equal(t, vector.seed, hex.EncodeToString(seed))
+
+			// Mnemonic -> xpriv
+			var key *bip32.ExtendedKey
+			key, err = mnemonicToKey(vector.mnemonic, testMnemonicPassphrase)
+			require.NoError(t, err)
+			assert.Equal(t, vector.xpriv, key.String())
+		})
+	}
+}
+
+func TestMnemonicToSeed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("normalized mnemonic", func(t *testing.T) {
+		vector := testMnemonicVectors[4]
+		seed, err := mnemonicToSeed("  "+strings.ToUpper(vector.mnemonic)+"\n", testMnemonicPassphrase)
+		require.NoError(t, err)
+		assert.Equal(t, vector.seed, hex.EncodeToString(seed))
+	})
+
+	t.Run("invalid number of words", func(t *testing.T) {
+		_, err := mnemonicToSeed("abandon abandon abandon", "")
+		require.ErrorIs(t, err, ErrInvalidMnemonicWords)
+	})
+
+	t.Run("unknown word", func(t *testing.T) {
+		_, err := mnemonicToSeed(strings.Replace(testMnemonicVectors[1].mnemonic, "legal", "zzz", 1), "")
+		require.ErrorIs(t, err, ErrInvalidMnemonic)
+		assert.Contains(t, err.Error(), "zzz")
+	})
+
+	t.Run("invalid checksum", func(t *testing.T) {
+		_, err := mnemonicToSeed(strings.Repeat("abandon ", 12), "")
+		require.ErrorIs(t, err, ErrInvalidMnemonic)
+	})
+}
+
+func TestNewMnemonic(t *testing.T) {
+	t.Parallel()
+
+	for _, words := range []int{12, 24} {
+		mnemonic, seed, err := newMnemonic(words, "secret")
+		require.NoError(t, err)
+		assert.Len(t, strings.Fields(mnemonic), words)
+
+		// Restoring the mnemonic gives the same xpriv
+		var restored []byte
+		restored, err = mnemonicToSeed(mnemonic, "secret")
+		require.NoError(t, err)
+		assert.Equal(t, seed, restored)
+	}
+
+	t.Run("invalid number of words", func(t *testing.T) {
+		_, _, err := newMnemonic(13, "")
+		require.ErrorIs(t, err, ErrInvalidMnemonicWords)
+	})
+}
+
+func TestNewMnemonicKeys(t *testing.T) {
+	t.Parallel()
+
+	keys, err := newMnemonicKeys(12, "secret")
+	require.NoError(t, err)
+	assert.Len(t, strings.Fields(keys.Mnemonic), 12)
+	assert.NotEmpty(t, keys.Xpub)
+	assert.NotEmpty(t, keys.WIF)
+
+	// Restoring the mnemonic gives the same keys
+	key, err := mnemonicToKey(keys.Mnemonic, "secret")
+	require.NoError(t, err)
+	var restored *Keys
+	restored, err = newKeys(key)
+	require.NoError(t, err)
+	restored.Mnemonic = keys.Mnemonic
+	assert.Equal(t, restored, keys)
+
+	t.Run("invalid number of words", func(t *testing.T) {
+		_, err = newMnemonicKeys(13, "")
+		require.ErrorIs(t, err, ErrInvalidMnemonicWords)
+	})
+}
+
+func TestNewKeys(t *testing.T) {
+	t.Parallel()
+
+	seed, err := hex.DecodeString(testMnemonicVectors[0].seed)
+	require.NoError(t, err)
+	var key *bip32.ExtendedKey
+	key, err = bip32.NewMaster(seed, &chaincfg.MainNet)
+	require.NoError(t, err)
+
+	var keys *Keys
+	keys, err = newKeys(key)
+	require.NoError(t, err)
+	assert.Equal(t, testMnemonicVectors[0].xpriv, keys.Xpriv)
+	assert.True(t, strings.HasPrefix(keys.Xpub, "xpub"))
+	assert.Len(t, keys.PrivateKey, 64)
+	assert.NotEmpty(t, keys.WIF)
+	assert.Empty(t, keys.Mnemonic)
+}
+
+// TestXprivInfo is not parallel, it sets the keystore passphrase environment variable and the --key flag
+func TestXprivInfo(t *testing.T) {
+	xprivKey := testMnemonicVectors[0].xpriv
+
+	t.Run("xpriv argument", func(t *testing.T) {
+		keys, err := xprivInfo(xprivKey)
+		require.NoError(t, err)
+		assert.Equal(t, xprivKey, keys.Xpriv)
+		assert.NotEmpty(t, keys.WIF)
+	})
+
+	t.Run("xpriv is required", func(t *testing.T) {
+		_, err := xprivInfo("")
+		require.ErrorIs(t, err, ErrXprivIsRequired)
+	})
+
+	t.Run("keystore key", func(t *testing.T) {
+		previousDirectory, previousKeyName := applicationDirectory, keyName
+		t.Cleanup(func() {
+			applicationDirectory, keyName = previousDirectory, previousKeyName
+		})
+		applicationDirectory = t.TempDir()
+		t.Setenv(keyPassphraseEnv, "passphrase")
+
+		// Store the xpriv in the keystore
+		entry, err := newKeystoreEntry("main", xprivKey, "passphrase")
+		require.NoError(t, err)
+		require.NoError(t, saveKeystore(
+			filepath.Join(applicationDirectory, keystoreFileName), &keystore{Keys: []*keystoreEntry{entry}},
+		))
+
+		// Same keys as the xpriv argument
+		var keys, expected *Keys
+		expected, err = xprivInfo(xprivKey)
+		require.NoError(t, err)
+		keyName = "main"
+		keys, err = xprivInfo("")
+		require.NoError(t, err)
+		assert.Equal(t, expected, keys)
+
+		// Not both
+		_, err = xprivInfo(xprivKey)
+		require.ErrorIs(t, err, ErrInvalidFlag)
+
+		// Unknown key
+		keyName = "unknown"
+		_, err = xprivInfo("")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	})
+}
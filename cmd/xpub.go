@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"fmt"
+	"os"
 
 	"github.com/BuxOrg/bux"
 	"github.com/BuxOrg/bux-cli/chalker"
@@ -11,13 +11,18 @@ import (
 	"github.com/bitcoinschema/go-bitcoin/v2"
 	"github.com/fatih/color"
 	"github.com/libsv/go-bk/bip32"
+	"github.com/mrz1836/go-datastore"
+	"github.com/mrz1836/go-whatsonchain"
 	"github.com/spf13/cobra"
 )
 
 // commands for xpub
+const xpubCommandDerive = "derive"
 const xpubCommandGet = "get"
 const xpubCommandName = "xpub"
 const xpubCommandNew = "new"
+const xpubCommandSummary = "summary"
+const xpubCommandUpdateMetadata = "update-metadata"
 
 // returnXpubCmd returns the xpub command
 func returnXpubCmd(app *App) (newCmd *cobra.Command) {
@@ -34,54 +39,92 @@ ____  _____________ ____ _____________
 ` + color.YellowString(`
 This command is for xpub (HD-Key) related commands.
 
-new: creates a new xpub in BUX (`+xpubCommandName+` new <xpriv>)
+new: creates a new xpub in BUX (`+xpubCommandName+` new <xpriv> | --`+flagKey+`=<name>)
 get: get a xpub from BUX (`+xpubCommandName+` get <xpub> | <xpub_id> -m=<metadata_json>)
+summary: balances, destinations, utxos and the last transaction of a xpub (`+xpubCommandName+` `+xpubCommandSummary+` <xpub> | <xpub_id> --`+flagWoc+`)
+update-metadata: updates (merges) the metadata of a xpub (`+xpubCommandName+` `+xpubCommandUpdateMetadata+` <xpub> | <xpub_id> -m=<metadata_json> --`+flagReplace+` --`+flagDeleteKey+`=<key>)
+derive: derives the child keys and addresses, the same as BUX destinations (`+xpubCommandName+` `+xpubCommandDerive+` <xpub> --`+flagChain+`=0 --`+flagIndex+`=5)
 `),
 		// Aliases: []string{"hdkey"},
 		Example: applicationName + " " + xpubCommandName + " " + xpubCommandNew + " <xpriv>",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) < 1 {
-				return chalker.Error(xpubCommandName + " requires a subcommand, IE: " + xpubCommandNew + ", etc.")
+				return fmt.Errorf("%s %w, IE: %s, etc.", xpubCommandName, ErrSubcommandIsRequired, xpubCommandNew)
 			}
 			return nil
 		},
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Derive the child keys (does not require BUX)
+			if args[0] == xpubCommandDerive {
+
+				// Check if xpub is provided
+				if len(args) < 2 {
+					return ErrXpubIsRequired
+				}
+
+				// Get the hd key from the xpub
+				hdKey, err := utils.ValidateXPub(args[1])
+				if err != nil {
+					return err
+				} else if hdKey.IsPrivate() {
+					return fmt.Errorf("%w: use %s %s for an xpriv", ErrXpubIsRequired, xprivCommandName, xprivCommandDerive)
+				}
+
+				// Derive the keys for the path(s)
+				var paths [][]uint32
+				if paths, err = getDerivationPaths(cmd); err != nil {
+					return err
+				}
+				var derived []*DerivedKey
+				if derived, err = deriveKeys(hdKey, paths); err != nil {
+					return err
+				}
+
+				// Display the derived key(s)
+				return displayDerivedKeys(derived)
+			}
 
 			// Initialize the BUX client
-			deferFunc := app.InitializeBUX()
+			deferFunc, err := app.InitializeBUX()
+			if err != nil {
+				return err
+			}
 			defer deferFunc()
 
 			// Parse Metadata
-			var err error
-			if metadata, err = cmd.Flags().GetString(flagMetadata); err != nil {
-				displayError(errors.New("error parsing metadata: " + err.Error()))
-				return
+			var metaData bux.Metadata
+			if metaData, err = getMetadataFlag(cmd); err != nil {
+				return err
 			}
 
 			// Switch on the subcommand
 			if args[0] == xpubCommandNew { // Create a new xpub
 
-				// Check if xpriv is provided
-				if len(args) < 2 {
-					displayError(ErrXprivIsRequired)
-					return
+				// Get the xpriv from the args or the keystore (--key)
+				var xprivKey string
+				if len(args) > 1 {
+					xprivKey = args[1]
+				}
+				if xprivKey, err = getXpriv(xprivKey); err != nil {
+					return err
+				} else if len(xprivKey) == 0 {
+					return ErrXprivIsRequired
 				}
 
 				// Create a new xpub
 				xpub := new(XpubExtended)
-				xpub.Xpub, xpub.FullKey, err = newXpub(context.Background(), app, args[1], metadata)
+				xpub.Xpub, xpub.FullKey, err = newXpub(context.Background(), app, xprivKey, metaData)
 				if err != nil {
-					displayError(errors.New("error creating xpub: " + err.Error()))
-					return
+					return fmt.Errorf("error creating xpub: %w", err)
 				}
 
-				displayModel(xpub)
+				return displayModel(xpub)
 			} else if args[0] == xpubCommandGet { // Get a xpub from BUX
 
 				// Check if xpub or xpub id is provided
 				if len(args) < 2 {
-					displayError(ErrXpubOrXpubIDIsRequired)
-					return
+					return ErrXpubOrXpubIDIsRequired
 				}
 
 				// Get the xpub from BUX
@@ -89,62 +132,99 @@ get: get a xpub from BUX (`+xpubCommandName+` get <xpub> | <xpub_id> -m=<metadat
 				if _, err = utils.ValidateXPub(args[1]); err == nil {
 
 					// Get the xpub by xpub
-					if xpub, err = app.bux.GetXpub(context.Background(), args[1]); err != nil {
-						displayError(errors.New("error getting xpub: " + err.Error()))
-						return
+					if xpub, err = app.backend.GetXpub(context.Background(), args[1]); err != nil {
+						return fmt.Errorf("error getting xpub: %w", err)
 					}
 
 					// Display the xpub
-					displayModel(xpub)
-				} else if len(metadata) > 0 {
-
-					// Unmarshal the metadata
-					metaData := new(bux.Metadata)
-					if err = json.Unmarshal([]byte(metadata), &metaData); err != nil {
-						displayError(errors.New("error unmarshalling metadata: " + err.Error()))
-						return
-					}
+					return displayModel(xpub)
+				} else if len(metaData) > 0 {
 
 					// Get the xpubs from BUX
 					var xpubs []*bux.Xpub
-					if xpubs, err = app.bux.GetXPubs(context.Background(), metaData, nil, nil); err != nil {
-						displayError(errors.New("error getting xpubs: " + err.Error()))
-						return
+					if xpubs, err = app.backend.GetXpubs(context.Background(), &metaData); err != nil {
+						return fmt.Errorf("error getting xpubs: %w", err)
 					} else if len(xpubs) == 0 {
-						displayError(ErrNoXpubsFound)
-						return
+						return ErrNoXpubsFound
 					}
 
 					// Display the xpubs
-					for _, xpub = range xpubs {
-						displayModel(xpub)
-					}
+					return displayModel(xpubs)
 				} else {
 
 					// Get the xpub from BUX by id
-					if xpub, err = app.bux.GetXpubByID(context.Background(), args[1]); err != nil {
-						displayError(errors.New("error getting xpub by id: " + err.Error()))
-						return
+					if xpub, err = app.backend.GetXpubByID(context.Background(), args[1]); err != nil {
+						return fmt.Errorf("error getting xpub by id: %w", err)
 					}
 
 					// Display the xpub
-					displayModel(xpub)
+					return displayModel(xpub)
+				}
+			} else if args[0] == xpubCommandUpdateMetadata { // Update the metadata of a xpub
+
+				// Check if xpub or xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubOrXpubIDIsRequired
+				}
+
+				// Update the metadata
+				var xpub *bux.Xpub
+				if xpub, err = updateXpubMetadata(
+					context.Background(), app, args[1], metaData, metadataDelete, metadataReplace,
+				); err != nil {
+					return fmt.Errorf("error updating xpub metadata: %w", err)
 				}
-			} else {
-				displayError(ErrUnknownSubcommand)
+
+				// Display the xpub
+				return displayModel(xpub)
+			} else if args[0] == xpubCommandSummary { // Summarize a xpub
+
+				// Check if xpub or xpub id is provided
+				if len(args) < 2 {
+					return ErrXpubOrXpubIDIsRequired
+				}
+
+				// Get the woc flag
+				if wocEnabled, err = cmd.Flags().GetBool(flagWoc); err != nil {
+					return err
+				}
+
+				// Summarize the xpub
+				var summary *XpubSummary
+				if summary, err = summarizeXpub(context.Background(), app, args[1], wocEnabled); err != nil {
+					return fmt.Errorf("error summarizing xpub: %w", err)
+				}
+
+				// Display the summary
+				return displayModel(summary)
 			}
+
+			return ErrUnknownSubcommand
 		},
 	}
 
-	// Set the metadata flag
-	newCmd.Flags().StringVarP(&metadata, flagMetadata, flagMetadataShort, "", "Model Metadata")
+	// Set the metadata flags
+	addMetadataFlag(newCmd)
+	addMetadataUpdateFlags(newCmd)
+
+	// Set the keystore key flag
+	newCmd.Flags().StringVar(&keyName, flagKey, "", "Name of the keystore key (instead of the xpriv argument)")
+
+	// Set the derivation flags
+	addDeriveFlags(newCmd)
+
+	// Set the woc flag
+	newCmd.Flags().BoolP(
+		flagWoc, flagWocShort, wocEnabled,
+		"Optional flag to cross-check the summary balance with WhatsOnChain",
+	)
 
 	return
 }
 
 // newXpub creates a new xpub in BUX
 func newXpub(ctx context.Context, app *App,
-	xpriv, metadata string) (xpub *bux.Xpub, fullXpubKey string, err error) {
+	xpriv string, metaData bux.Metadata) (xpub *bux.Xpub, fullXpubKey string, err error) {
 
 	// Generate the HDKey from the xpriv
 	var hdKey *bip32.ExtendedKey
@@ -159,13 +239,236 @@ func newXpub(ctx context.Context, app *App,
 		return
 	}
 
-	// Get the metadata if provided
-	modelOps := app.bux.DefaultModelOptions()
-	if len(metadata) > 0 {
-		modelOps = append(modelOps, bux.WithMetadataFromJSON([]byte(metadata)))
+	// Create the xpub in BUX
+	xpub, err = app.backend.NewXpub(ctx, fullXpubKey, metaData)
+	return
+}
+
+// updateXpubMetadata updates the metadata of a xpub (by xpub or xpub id)
+//
+// The metadata is merged, unless replace is set (the current metadata is needed to remove the other keys)
+func updateXpubMetadata(ctx context.Context, app *App, xpubOrID string, metaData bux.Metadata,
+	deleteKeys []string, replace bool) (xpub *bux.Xpub, err error) {
+
+	// The xpub is used by the server (authentication), the xpub id by the database
+	var xpubKey string
+	xpubID := xpubOrID
+	if _, err = utils.ValidateXPub(xpubOrID); err == nil {
+		xpubKey, xpubID = xpubOrID, utils.Hash(xpubOrID)
 	}
 
-	// Create the xpub in BUX
-	xpub, err = app.bux.NewXpub(ctx, fullXpubKey, modelOps...)
+	// Get the current metadata (to replace it)
+	var current bux.Metadata
+	if replace {
+		if len(xpubKey) > 0 {
+			xpub, err = app.backend.GetXpub(ctx, xpubKey)
+		} else {
+			xpub, err = app.backend.GetXpubByID(ctx, xpubID)
+		}
+		if err != nil {
+			return
+		} else if xpub == nil {
+			return nil, ErrXpubNotFound
+		}
+		current = xpub.Metadata
+	}
+
+	// Update the metadata
+	var update bux.Metadata
+	if update, err = metadataUpdate(current, metaData, deleteKeys, replace); err != nil {
+		return
+	}
+	return app.backend.UpdateXpubMetadata(ctx, xpubKey, xpubID, update)
+}
+
+// summarizeXpub will summarize a xpub (by xpub or xpub id), optionally cross-checked with WhatsOnChain
+func summarizeXpub(ctx context.Context, app *App, xpubOrID string,
+	wocEnabled bool) (summary *XpubSummary, err error) {
+
+	// Get the xpub from BUX
+	var xpub *bux.Xpub
+	if _, err = utils.ValidateXPub(xpubOrID); err == nil {
+		xpub, err = app.backend.GetXpub(ctx, xpubOrID)
+	} else {
+		xpub, err = app.backend.GetXpubByID(ctx, xpubOrID)
+	}
+	if err != nil {
+		return
+	} else if xpub == nil {
+		return nil, ErrXpubNotFound
+	}
+
+	// Get all the destinations and unspent utxos
+	var destinations []*bux.Destination
+	if destinations, err = getAllDestinations(ctx, app, xpub.ID); err != nil {
+		return
+	}
+	var conditions map[string]interface{}
+	if conditions, err = utxoConditions(utxoStatusUnspent, "", 0, 0); err != nil {
+		return
+	}
+	var utxos []*bux.Utxo
+	if utxos, err = getAllUtxos(ctx, app, xpub.ID, nil, conditions); err != nil {
+		return
+	}
+	summary = newXpubSummary(xpub, destinations, utxos)
+
+	// Count the transactions, get the most recent one and sum the incoming and outgoing satoshis
+	if summary.TransactionCount, err = app.backend.GetTransactionsCount(ctx, xpub.ID, nil, nil); err != nil {
+		return nil, err
+	} else if summary.TransactionCount > 0 {
+		var latest []*bux.Transaction
+		if latest, err = app.backend.GetTransactions(ctx, xpub.ID, nil, nil, &datastore.QueryParams{
+			OrderByField:  "created_at",
+			Page:          1,
+			PageSize:      1,
+			SortDirection: datastore.SortDesc,
+		}); err != nil {
+			return nil, err
+		} else if len(latest) > 0 {
+			createdAt := latest[0].CreatedAt
+			summary.LastTransactionAt = &createdAt
+		}
+		if err = sumTransactionValues(ctx, app, summary); err != nil {
+			return nil, err
+		}
+	}
+
+	// Cross-check the balance with WhatsOnChain (the discrepancies are highlighted)
+	if !wocEnabled {
+		return
+	}
+	if summary.WOC, err = wocBalance(ctx, app.WhatsOnChain(), destinations, utxos); err != nil {
+		return
+	}
+	for _, discrepancy := range summary.WOC.Discrepancies {
+		chalker.LogTo(os.Stderr, chalker.WARN, fmt.Sprintf(
+			"balance discrepancy for %s: %d satoshis in BUX, %d satoshis on WhatsOnChain",
+			discrepancy.Address, discrepancy.BuxSatoshis, discrepancy.WOCSatoshis,
+		))
+	}
+	if wocTotal := summary.WOC.Confirmed + summary.WOC.Unconfirmed; wocTotal != int64(summary.CurrentBalance) {
+		chalker.LogTo(os.Stderr, chalker.WARN, fmt.Sprintf(
+			"balance discrepancy for the xpub: %d satoshis in BUX, %d satoshis on WhatsOnChain",
+			summary.CurrentBalance, wocTotal,
+		))
+	}
 	return
 }
+
+// newXpubSummary will summarize the xpub from its destinations and unspent utxos (see: summarizeXpub)
+func newXpubSummary(xpub *bux.Xpub, destinations []*bux.Destination, utxos []*bux.Utxo) *XpubSummary {
+	summary := &XpubSummary{
+		CurrentBalance:  xpub.CurrentBalance,
+		NextExternalNum: xpub.NextExternalNum,
+		NextInternalNum: xpub.NextInternalNum,
+		UtxoCount:       int64(len(utxos)),
+		XpubID:          xpub.ID,
+	}
+	for _, destination := range destinations {
+		if destination.Chain == utils.ChainInternal {
+			summary.InternalDestinations++
+		} else {
+			summary.ExternalDestinations++
+		}
+	}
+	for _, utxo := range utxos {
+		summary.UtxoSatoshis += utxo.Satoshis
+	}
+	return summary
+}
+
+// sumTransactionValues will add the output values of all the transactions of the xpub to the summary (page by page)
+//
+// The incoming and outgoing satoshis are the totals of the transaction output values for the xpub
+// (outgoing includes the fees), BUX cannot sum them in the datastore
+func sumTransactionValues(ctx context.Context, app *App, summary *XpubSummary) error {
+	for currentPage := 1; ; currentPage++ {
+		transactions, err := app.backend.GetTransactions(
+			ctx, summary.XpubID, nil, nil, allPagesQueryParams(currentPage),
+		)
+		if err != nil {
+			return err
+		}
+		addTransactionValues(summary, transactions)
+		if len(transactions) < maxPageSize {
+			return nil
+		}
+	}
+}
+
+// addTransactionValues will add the output values of the transactions to the incoming and outgoing satoshis
+func addTransactionValues(summary *XpubSummary, transactions []*bux.Transaction) {
+	for _, tx := range transactions {
+		if value := transactionOutputValue(tx, summary.XpubID); value > 0 {
+			summary.IncomingSatoshis += uint64(value)
+		} else {
+			summary.OutgoingSatoshis += uint64(-value)
+		}
+	}
+}
+
+// wocBalance will get the balance of the destination addresses on WhatsOnChain and compare it with the
+// unspent utxos in BUX (per address)
+//
+// The balances are looked up in batches (the bulk balance endpoint), to stay within the WhatsOnChain rate limits
+func wocBalance(ctx context.Context, client whatsonchain.ClientInterface, destinations []*bux.Destination,
+	utxos []*bux.Utxo) (*XpubWOCBalance, error) {
+
+	// The BUX balance of each locking script
+	buxBalances := make(map[string]uint64, len(destinations))
+	for _, utxo := range utxos {
+		buxBalances[utxo.ScriptPubKey] += utxo.Satoshis
+	}
+
+	// Get the WhatsOnChain balance of each address
+	addresses := make([]string, 0, len(destinations))
+	for _, destination := range destinations {
+		if len(destination.Address) > 0 {
+			addresses = append(addresses, destination.Address)
+		}
+	}
+	wocBalances := make(map[string]*whatsonchain.AddressBalance, len(addresses))
+	for start := 0; start < len(addresses); start += whatsonchain.MaxAddressesForLookup {
+		end := start + whatsonchain.MaxAddressesForLookup
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		records, err := client.BulkBalance(ctx, &whatsonchain.AddressList{Addresses: addresses[start:end]})
+		if err != nil {
+			return nil, fmt.Errorf("error getting the balances from WhatsOnChain: %w", err)
+		}
+		for _, record := range records {
+			if record == nil {
+				continue
+			} else if len(record.Error) > 0 {
+				return nil, fmt.Errorf("error getting the balance of %s from WhatsOnChain: %s",
+					record.Address, record.Error)
+			}
+			wocBalances[record.Address] = record.Balance
+		}
+	}
+
+	balance := &XpubWOCBalance{Discrepancies: []*BalanceDiscrepancy{}}
+	for _, destination := range destinations {
+		if len(destination.Address) == 0 {
+			continue
+		}
+		addressBalance := wocBalances[destination.Address]
+		if addressBalance == nil {
+			addressBalance = &whatsonchain.AddressBalance{}
+		}
+		balance.Confirmed += addressBalance.Confirmed
+		balance.Unconfirmed += addressBalance.Unconfirmed
+		buxSatoshis := buxBalances[destination.LockingScript]
+		if wocSatoshis := addressBalance.Confirmed + addressBalance.Unconfirmed; wocSatoshis != int64(buxSatoshis) {
+			balance.Discrepancies = append(balance.Discrepancies, &BalanceDiscrepancy{
+				Address:     destination.Address,
+				BuxSatoshis: buxSatoshis,
+				WOCSatoshis: wocSatoshis,
+			})
+		}
+	}
+	balance.Matches = len(balance.Discrepancies) == 0
+	return balance, nil
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BuxOrg/bux"
+	"github.com/BuxOrg/bux/utils"
+	"github.com/mrz1836/go-whatsonchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bulkBalanceStub is a WhatsOnChain client stub that returns 1050 satoshis for every address (bulk balance)
+type bulkBalanceStub struct {
+	whatsonchain.ClientInterface
+	batches [][]string
+}
+
+// BulkBalance returns a fixed balance for each address
+func (b *bulkBalanceStub) BulkBalance(_ context.Context,
+	list *whatsonchain.AddressList) (whatsonchain.AddressBalances, error) {
+	b.batches = append(b.batches, list.Addresses)
+	balances := make(whatsonchain.AddressBalances, 0, len(list.Addresses))
+	for _, address := range list.Addresses {
+		balances = append(balances, &whatsonchain.AddressBalanceRecord{
+			Address: address, Balance: &whatsonchain.AddressBalance{Confirmed: 1000, Unconfirmed: 50},
+		})
+	}
+	return balances, nil
+}
+
+func TestNewXpubSummary(t *testing.T) {
+	t.Parallel()
+
+	xpub := &bux.Xpub{ID: "xpub-id", CurrentBalance: 1500, NextExternalNum: 3, NextInternalNum: 2}
+	destinations := []*bux.Destination{
+		{Chain: utils.ChainExternal}, {Chain: utils.ChainExternal}, {Chain: utils.ChainExternal},
+		{Chain: utils.ChainInternal},
+	}
+	utxos := []*bux.Utxo{{Satoshis: 1000}, {Satoshis: 500}}
+
+	assert.Equal(t, &XpubSummary{
+		CurrentBalance:       1500,
+		ExternalDestinations: 3,
+		InternalDestinations: 1,
+		NextExternalNum:      3,
+		NextInternalNum:      2,
+		UtxoCount:            2,
+		UtxoSatoshis:         1500,
+		XpubID:               "xpub-id",
+	}, newXpubSummary(xpub, destinations, utxos))
+}
+
+func TestAddTransactionValues(t *testing.T) {
+	t.Parallel()
+
+	summary := &XpubSummary{XpubID: "xpub-id"}
+	addTransactionValues(summary, []*bux.Transaction{
+		{XpubOutputValue: bux.XpubOutputValue{"xpub-id": -600}},
+		{XpubOutputValue: bux.XpubOutputValue{"xpub-id": 2000}},
+	})
+	addTransactionValues(summary, []*bux.Transaction{
+		{OutputValue: 100}, // server (authenticated xpub)
+	})
+	assert.Equal(t, uint64(2100), summary.IncomingSatoshis)
+	assert.Equal(t, uint64(600), summary.OutgoingSatoshis)
+}
+
+func TestSummarizeXpub(t *testing.T) {
+	t.Parallel()
+
+	// The server returns the xpub, the counts and the pages of the search routes
+	xPubKey := newTestXpub(t, testMnemonicVectors[0].xpriv)
+	lastTx := time.Date(2022, 10, 2, 10, 0, 0, 0, time.UTC)
+	searches := make(map[string][]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body := map[string]interface{}{}
+		if req.ContentLength > 0 {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		}
+		var response interface{}
+		switch req.URL.Path {
+		case serverRouteXpub:
+			response = &bux.Xpub{ID: utils.Hash(xPubKey), CurrentBalance: 1400}
+		case serverRouteTransactionCount:
+			response = 2
+		case serverRouteTransactionSearch:
+			searches[req.URL.Path] = append(searches[req.URL.Path], body["params"].(map[string]interface{}))
+			response = []*bux.Transaction{
+				{Model: bux.Model{CreatedAt: lastTx}, OutputValue: -600},
+				{Model: bux.Model{CreatedAt: lastTx.Add(-time.Hour)}, OutputValue: 2000},
+			}
+		default:
+			response = []interface{}{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(server.Close)
+	backend, err := newServerBackend(&ServerConfig{URL: server.URL, Xpub: xPubKey}, "test")
+	require.NoError(t, err)
+
+	summary, err := summarizeXpub(context.Background(), &App{backend: backend}, xPubKey, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), summary.TransactionCount)
+	require.NotNil(t, summary.LastTransactionAt)
+	assert.True(t, lastTx.Equal(*summary.LastTransactionAt))
+	assert.Equal(t, uint64(2000), summary.IncomingSatoshis)
+	assert.Equal(t, uint64(600), summary.OutgoingSatoshis)
+
+	// The most recent transaction is a single row, the values are summed page by page
+	assert.Equal(t, []map[string]interface{}{
+		{"order_by_field": "created_at", "page": float64(1), "page_size": float64(1), "sort_direction": "desc"},
+		{"order_by_field": "id", "page": float64(1), "page_size": float64(maxPageSize), "sort_direction": "asc"},
+	}, searches[serverRouteTransactionSearch])
+}
+
+func TestWocBalance(t *testing.T) {
+	t.Parallel()
+
+	// The stub returns 1050 satoshis for every address
+	stub := &bulkBalanceStub{}
+	destinations := []*bux.Destination{
+		{Address: "1matches", LockingScript: "script-1"},
+		{Address: "1differs", LockingScript: "script-2"},
+		{LockingScript: "script-3"}, // no address, not checked
+	}
+	utxos := []*bux.Utxo{
+		{ScriptPubKey: "script-1", Satoshis: 1000},
+		{ScriptPubKey: "script-1", Satoshis: 50},
+		{ScriptPubKey: "script-2", Satoshis: 700},
+	}
+
+	balance, err := wocBalance(context.Background(), stub, destinations, utxos)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"1matches", "1differs"}}, stub.batches)
+	assert.Equal(t, &XpubWOCBalance{
+		Confirmed:     2000,
+		Discrepancies: []*BalanceDiscrepancy{{Address: "1differs", BuxSatoshis: 700, WOCSatoshis: 1050}},
+		Unconfirmed:   100,
+	}, balance)
+
+	// All the addresses match
+	balance, err = wocBalance(context.Background(), stub, destinations[:1], utxos)
+	require.NoError(t, err)
+	assert.True(t, balance.Matches)
+	assert.Empty(t, balance.Discrepancies)
+
+	// The addresses are looked up in batches
+	stub = &bulkBalanceStub{}
+	destinations = make([]*bux.Destination, 0, whatsonchain.MaxAddressesForLookup+5)
+	for index := 0; index < whatsonchain.MaxAddressesForLookup+5; index++ {
+		destinations = append(destinations, &bux.Destination{Address: fmt.Sprintf("1address%d", index)})
+	}
+	_, err = wocBalance(context.Background(), stub, destinations, nil)
+	require.NoError(t, err)
+	require.Len(t, stub.batches, 2)
+	assert.Len(t, stub.batches[0], whatsonchain.MaxAddressesForLookup)
+	assert.Len(t, stub.batches[1], 5)
+}